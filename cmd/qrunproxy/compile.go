@@ -0,0 +1,451 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"qrun/lib/qlab"
+)
+
+// CompiledCue is a cue the compiler wants in Qlab. Key identifies it within
+// the compilation and Target holds the key of the cue a Start, Stop or Fade
+// acts on.
+//
+// The cue list holds, in order:
+//
+//   - a GO-able Group per cue block, starting its GO actions in sequence
+//   - a disarmed "Qrun Blocks" Group holding a Group per other block: the
+//     block's START actions, its content (a Memo, or a Wait for delays), and
+//     for blocks with defined timing the END actions, auto-following it
+//   - a disarmed "Qrun Tracks" Group holding a Memo per track
+//
+// A trigger on a FADE_OUT or END hook becomes a Fade or Stop cue in the
+// source's action chain, followed by the target's own FADE_OUT or END
+// actions, since Qlab has no event for a cue being faded or stopped.
+type CompiledCue struct {
+	Key                string
+	Type               string
+	Name               string
+	Notes              string
+	Target             string
+	Continue           qlab.ContinueMode
+	Mode               qlab.GroupMode
+	StopTargetWhenDone bool
+	Disarmed           bool
+	Children           []*CompiledCue
+}
+
+const (
+	tracksKey = "tracks"
+	blocksKey = "blocks"
+)
+
+type signalKey struct {
+	block  string
+	signal string
+}
+
+type compiler struct {
+	show      *Show
+	blocks    map[string]*Block
+	index     map[string]int
+	triggers  map[signalKey]*Trigger
+	expanding map[signalKey]bool
+}
+
+// Compile turns a validated show into the cue tree that represents it.
+func Compile(show *Show) ([]*CompiledCue, error) {
+	c := &compiler{
+		show:      show,
+		blocks:    map[string]*Block{},
+		index:     map[string]int{},
+		triggers:  map[signalKey]*Trigger{},
+		expanding: map[signalKey]bool{},
+	}
+	for i, b := range show.Blocks {
+		c.blocks[b.ID] = b
+		c.index[b.ID] = i
+	}
+	for _, t := range show.Triggers {
+		c.triggers[signalKey{t.Source.Block, t.Source.Signal}] = t
+	}
+
+	var root []*CompiledCue
+	blocks := &CompiledCue{Key: blocksKey, Type: "group", Name: "Qrun Blocks", Mode: qlab.GroupStartAll, Disarmed: true}
+	for _, b := range show.Blocks {
+		cue, err := c.compileBlock(b)
+		if err != nil {
+			return nil, err
+		}
+		if b.Type == "cue" {
+			root = append(root, cue)
+		} else {
+			blocks.Children = append(blocks.Children, cue)
+		}
+	}
+	root = append(root, blocks)
+
+	tracks := &CompiledCue{Key: tracksKey, Type: "group", Name: "Qrun Tracks", Mode: qlab.GroupStartAll, Disarmed: true}
+	for _, t := range show.Tracks {
+		notes, err := formatNotes(cueRecord{Kind: recordTrack, ID: t.ID})
+		if err != nil {
+			return nil, err
+		}
+		tracks.Children = append(tracks.Children, &CompiledCue{
+			Key:   "track:" + t.ID,
+			Type:  "memo",
+			Name:  t.Name,
+			Notes: notes,
+		})
+	}
+	root = append(root, tracks)
+	return root, nil
+}
+
+func blockKey(id string) string {
+	return "block:" + id
+}
+
+func (c *compiler) record(b *Block) cueRecord {
+	rec := cueRecord{Kind: recordBlock, ID: b.ID, Index: c.index[b.ID], Type: b.Type, Track: b.Track, Loop: b.Loop}
+	if b.Type == "cue" {
+		// BuildTimeline puts cue blocks on its own cue track.
+		rec.Track = ""
+	}
+	for _, t := range c.show.Triggers {
+		if t.Source.Block == b.ID {
+			rec.Triggers = append(rec.Triggers, triggerRecord{Signal: t.Source.Signal, Targets: t.Targets})
+		}
+	}
+	return rec
+}
+
+func (c *compiler) compileBlock(b *Block) (*CompiledCue, error) {
+	notes, err := formatNotes(c.record(b))
+	if err != nil {
+		return nil, err
+	}
+	key := blockKey(b.ID)
+	group := &CompiledCue{Key: key, Type: "group", Name: b.Name, Notes: notes, Mode: qlab.GroupStartFirst}
+
+	if b.Type == "cue" {
+		chain, err := c.actions(signalKey{b.ID, "GO"})
+		if err != nil {
+			return nil, err
+		}
+		group.Children = link(key, chain)
+		return group, nil
+	}
+
+	chain, err := c.actions(signalKey{b.ID, "START"})
+	if err != nil {
+		return nil, err
+	}
+	content := &CompiledCue{Type: "memo", Name: b.Name}
+	if b.Type == "delay" {
+		content.Type = "wait"
+	}
+	chain = append(chain, content)
+	if b.hasDefinedTiming() {
+		end, err := c.actions(signalKey{b.ID, "END"})
+		if err != nil {
+			return nil, err
+		}
+		if len(end) > 0 {
+			content.Continue = qlab.AutoFollow
+		}
+		chain = append(chain, end...)
+	}
+	group.Children = link(key, chain)
+	return group, nil
+}
+
+// actions expands the targets of the trigger on src into the cues that
+// carry them out, in order.
+func (c *compiler) actions(src signalKey) ([]*CompiledCue, error) {
+	t := c.triggers[src]
+	if t == nil {
+		return nil, nil
+	}
+	if c.expanding[src] {
+		return nil, fmt.Errorf("trigger cycle through %s/%s", src.block, src.signal)
+	}
+	c.expanding[src] = true
+	defer delete(c.expanding, src)
+
+	var chain []*CompiledCue
+	for _, target := range t.Targets {
+		b := c.blocks[target.Block]
+		if b == nil {
+			return nil, fmt.Errorf("trigger target block %q not found", target.Block)
+		}
+		switch target.Hook {
+		case "START":
+			chain = append(chain, &CompiledCue{Type: "start", Name: "Start " + b.Name, Target: blockKey(b.ID)})
+		case "FADE_OUT":
+			fading, err := c.actions(signalKey{b.ID, "FADE_OUT"})
+			if err != nil {
+				return nil, err
+			}
+			end, err := c.actions(signalKey{b.ID, "END"})
+			if err != nil {
+				return nil, err
+			}
+			fade := &CompiledCue{Type: "fade", Name: "Fade " + b.Name, Target: blockKey(b.ID), StopTargetWhenDone: true}
+			if len(end) > 0 {
+				fade.Continue = qlab.AutoFollow
+			}
+			chain = append(chain, fading...)
+			chain = append(chain, fade)
+			chain = append(chain, end...)
+		case "END":
+			end, err := c.actions(signalKey{b.ID, "END"})
+			if err != nil {
+				return nil, err
+			}
+			chain = append(chain, &CompiledCue{Type: "stop", Name: "Stop " + b.Name, Target: blockKey(b.ID)})
+			chain = append(chain, end...)
+		default:
+			return nil, fmt.Errorf("trigger target hook %q cannot be compiled", target.Hook)
+		}
+	}
+	return chain, nil
+}
+
+// link keys a chain of cues under parent and sets their continue modes so
+// each fires the next. Cues that must complete first are already set to
+// auto-follow.
+func link(parent string, chain []*CompiledCue) []*CompiledCue {
+	for i, cue := range chain {
+		if cue.Key == "" {
+			cue.Key = fmt.Sprintf("%s/%d", parent, i)
+		}
+		switch {
+		case i == len(chain)-1:
+			cue.Continue = qlab.NoContinue
+		case cue.Continue == qlab.NoContinue:
+			cue.Continue = qlab.AutoContinue
+		}
+	}
+	return chain
+}
+
+// CueOp is one step of a compiled plan. "new" creates a cue of CueType as
+// child Index of Parent ("" for the cue list); "set" writes Value to
+// Property; "target" points a cue at the cue whose key is in Value.
+// Plans that update a live tree also use "existing", which gives Key to the
+// live cue whose unique ID is in Value, "move", which moves a cue like "new"
+// places it, and "delete", which deletes the cue whose unique ID is in Value.
+type CueOp struct {
+	Op       string
+	Key      string
+	Parent   string
+	Index    int
+	CueType  string
+	Property string
+	Value    any
+}
+
+func (op CueOp) String() string {
+	switch op.Op {
+	case "new":
+		parent := op.Parent
+		if parent == "" {
+			parent = "(list)"
+		}
+		return fmt.Sprintf("new %s %s in %s at %d", op.CueType, op.Key, parent, op.Index)
+	case "move":
+		parent := op.Parent
+		if parent == "" {
+			parent = "(list)"
+		}
+		return fmt.Sprintf("move %s to %s at %d", op.Key, parent, op.Index)
+	case "existing":
+		return fmt.Sprintf("existing %s = %s", op.Key, op.Value)
+	case "delete":
+		return fmt.Sprintf("delete %s", op.Value)
+	case "target":
+		return fmt.Sprintf("target %s -> %s", op.Key, op.Value)
+	default:
+		return fmt.Sprintf("set %s %s = %v", op.Key, op.Property, op.Value)
+	}
+}
+
+// Plan flattens a cue tree into the operations that build it: every cue is
+// created parent first, then properties are set, then targets are linked
+// once every cue exists. The order depends only on the tree.
+func Plan(root []*CompiledCue) []CueOp {
+	var creates, sets, targets []CueOp
+	var walk func(parent string, cues []*CompiledCue)
+	walk = func(parent string, cues []*CompiledCue) {
+		for i, cue := range cues {
+			creates = append(creates, CueOp{Op: "new", Key: cue.Key, Parent: parent, Index: i, CueType: cue.Type})
+			sets = append(sets, newCueSets(cue)...)
+			if cue.Target != "" {
+				targets = append(targets, CueOp{Op: "target", Key: cue.Key, Value: cue.Target})
+			}
+			walk(cue.Key, cue.Children)
+		}
+	}
+	walk("", root)
+	return append(append(creates, sets...), targets...)
+}
+
+// newCueSets are the property writes that turn a fresh cue of the right type
+// into cue.
+func newCueSets(cue *CompiledCue) []CueOp {
+	var sets []CueOp
+	set := func(prop string, v any) {
+		sets = append(sets, CueOp{Op: "set", Key: cue.Key, Property: prop, Value: v})
+	}
+	set("name", cue.Name)
+	if cue.Notes != "" {
+		set("notes", cue.Notes)
+	}
+	if cue.Type == "group" {
+		set("mode", cue.Mode)
+	}
+	if cue.Continue != qlab.NoContinue {
+		set("continueMode", cue.Continue)
+	}
+	if cue.StopTargetWhenDone {
+		set("stopTargetWhenDone", true)
+	}
+	if cue.Disarmed {
+		set("armed", false)
+	}
+	return sets
+}
+
+// applyBatch bounds the property writes sent in one bundle, keeping bundles
+// well inside a UDP datagram.
+const applyBatch = 64
+
+// ApplyPlan builds the plan in a cue list and returns the unique ID of each
+// cue it names by key. It returns once Qlab has applied the whole plan.
+func ApplyPlan(ctx context.Context, ws *qlab.WorkspaceHandle, listID string, plan []CueOp) (map[string]string, error) {
+	ids := map[string]string{"": listID}
+	lookup := func(key string) (string, error) {
+		id, ok := ids[key]
+		if !ok {
+			return "", fmt.Errorf("plan refers to unknown cue %q", key)
+		}
+		return id, nil
+	}
+
+	var changes []qlab.Change
+	flush := func() error {
+		err := qlab.SetCueProperties(ctx, ws.Client(), ws.ID, changes...)
+		changes = changes[:0]
+		return err
+	}
+	for _, op := range plan {
+		switch op.Op {
+		case "existing":
+			ids[op.Key] = fmt.Sprint(op.Value)
+		case "new", "move":
+			parent, err := lookup(op.Parent)
+			if err != nil {
+				return nil, err
+			}
+			id, ok := ids[op.Key]
+			if op.Op == "new" {
+				if id, err = ws.NewCue(ctx, op.CueType, ""); err != nil {
+					return nil, fmt.Errorf("%s: %w", op, err)
+				}
+			} else if !ok {
+				return nil, fmt.Errorf("plan refers to unknown cue %q", op.Key)
+			}
+			if err := ws.MoveCue(ctx, id, parent, op.Index); err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			ids[op.Key] = id
+		case "set", "target":
+			ch, err := opChange(op, lookup)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, ch)
+			if len(changes) == applyBatch {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+			}
+		case "delete":
+			// Deletes come last, so flush first: nothing is deleted until
+			// everything the plan keeps is in place.
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			if err := ws.DeleteCue(ctx, fmt.Sprint(op.Value)); err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+		default:
+			return nil, fmt.Errorf("unknown plan op %q", op.Op)
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	// Property writes get no reply. Qlab answers in order, so once a thump
+	// sent after them is answered they have all been applied.
+	if err := ws.Thump(ctx); err != nil {
+		return nil, err
+	}
+	delete(ids, "")
+	return ids, nil
+}
+
+func opChange(op CueOp, lookup func(string) (string, error)) (qlab.Change, error) {
+	id, err := lookup(op.Key)
+	if err != nil {
+		return qlab.Change{}, err
+	}
+	if op.Op == "target" {
+		target, err := lookup(fmt.Sprint(op.Value))
+		if err != nil {
+			return qlab.Change{}, err
+		}
+		return qlab.PropCueTargetID.Change(id, target), nil
+	}
+	switch v := op.Value.(type) {
+	case string:
+		switch op.Property {
+		case "name":
+			return qlab.PropName.Change(id, v), nil
+		case "notes":
+			return qlab.PropNotes.Change(id, v), nil
+		}
+	case bool:
+		switch op.Property {
+		case "stopTargetWhenDone":
+			return qlab.PropStopTargetWhenDone.Change(id, v), nil
+		case "armed":
+			return qlab.PropArmed.Change(id, v), nil
+		}
+	case qlab.GroupMode:
+		return qlab.PropGroupMode.Change(id, v), nil
+	case qlab.ContinueMode:
+		return qlab.PropContinueMode.Change(id, v), nil
+	case time.Duration:
+		switch op.Property {
+		case "preWait":
+			return qlab.PropPreWait.Change(id, v), nil
+		case "postWait":
+			return qlab.PropPostWait.Change(id, v), nil
+		}
+	}
+	return qlab.Change{}, fmt.Errorf("%s: cannot encode %T", op, op.Value)
+}
+
+// formatPlan renders a plan one operation per line, for diffs and tests.
+func formatPlan(plan []CueOp) string {
+	var sb strings.Builder
+	for _, op := range plan {
+		sb.WriteString(op.String())
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"qrun/lib/qlab"
+)
+
+// smallShow is one cue that starts a light and a delay; when the delay ends
+// it fades the light, and a second cue stops a looping video.
+func smallShow() *Show {
+	return &Show{
+		Tracks: []*Track{{ID: "t0", Name: "Lighting"}, {ID: "t1", Name: "Video"}},
+		Blocks: []*Block{
+			{ID: "q1", Type: "cue", Name: "Q1"},
+			{ID: "wash", Type: "light", Track: "t0", Name: "Wash"},
+			{ID: "hold", Type: "delay", Track: "t1", Name: "Hold"},
+			{ID: "loop", Type: "media", Track: "t1", Name: "Loop", Loop: true},
+			{ID: "q2", Type: "cue", Name: "Q2"},
+		},
+		Triggers: []*Trigger{
+			{Source: TriggerSource{Block: "q1", Signal: "GO"}, Targets: []TriggerTarget{
+				{Block: "wash", Hook: "START"},
+				{Block: "hold", Hook: "START"},
+			}},
+			{Source: TriggerSource{Block: "hold", Signal: "END"}, Targets: []TriggerTarget{
+				{Block: "wash", Hook: "FADE_OUT"},
+				{Block: "loop", Hook: "START"},
+			}},
+			{Source: TriggerSource{Block: "q2", Signal: "GO"}, Targets: []TriggerTarget{
+				{Block: "loop", Hook: "END"},
+			}},
+		},
+	}
+}
+
+func TestCompilePlan(t *testing.T) {
+	show := smallShow()
+	if err := show.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	cues, err := Compile(show)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := formatPlan(Plan(cues))
+
+	var got []string
+	for _, line := range strings.Split(plan, "\n") {
+		if strings.HasPrefix(line, "new ") || strings.HasPrefix(line, "target ") || strings.Contains(line, " continueMode ") {
+			got = append(got, line)
+		}
+	}
+	want := []string{
+		"new group block:q1 in (list) at 0",
+		"new start block:q1/0 in block:q1 at 0",
+		"new start block:q1/1 in block:q1 at 1",
+		"new group block:q2 in (list) at 1",
+		"new stop block:q2/0 in block:q2 at 0",
+		"new group blocks in (list) at 2",
+		"new group block:wash in blocks at 0",
+		"new memo block:wash/0 in block:wash at 0",
+		"new group block:hold in blocks at 1",
+		"new wait block:hold/0 in block:hold at 0",
+		"new fade block:hold/1 in block:hold at 1",
+		"new start block:hold/2 in block:hold at 2",
+		"new group block:loop in blocks at 2",
+		"new memo block:loop/0 in block:loop at 0",
+		"new group tracks in (list) at 3",
+		"new memo track:t0 in tracks at 0",
+		"new memo track:t1 in tracks at 1",
+		"set block:q1/0 continueMode = 1",
+		"set block:hold/0 continueMode = 2",
+		"set block:hold/1 continueMode = 1",
+		"target block:q1/0 -> block:wash",
+		"target block:q1/1 -> block:hold",
+		"target block:q2/0 -> block:loop",
+		"target block:hold/1 -> block:wash",
+		"target block:hold/2 -> block:loop",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("plan:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	again, err := Compile(smallShow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if formatPlan(Plan(again)) != plan {
+		t.Error("compiling the same show twice gave different plans")
+	}
+}
+
+func TestCompileCycle(t *testing.T) {
+	show := &Show{
+		Tracks: []*Track{{ID: "t0"}, {ID: "t1"}},
+		Blocks: []*Block{
+			{ID: "q1", Type: "cue"},
+			{ID: "a", Type: "light", Track: "t0"},
+			{ID: "b", Type: "light", Track: "t1"},
+		},
+		Triggers: []*Trigger{
+			{Source: TriggerSource{Block: "q1", Signal: "GO"}, Targets: []TriggerTarget{{Block: "a", Hook: "FADE_OUT"}}},
+			{Source: TriggerSource{Block: "a", Signal: "FADE_OUT"}, Targets: []TriggerTarget{{Block: "b", Hook: "END"}}},
+			{Source: TriggerSource{Block: "b", Signal: "END"}, Targets: []TriggerTarget{{Block: "a", Hook: "FADE_OUT"}}},
+		},
+	}
+	if _, err := Compile(show); err == nil || !strings.Contains(err.Error(), "trigger cycle") {
+		t.Errorf("got %v, want trigger cycle error", err)
+	}
+}
+
+func TestCompileRoundTrip(t *testing.T) {
+	mock, link := setupDecompileTest(t)
+	mock.CueLists["ws-1"] = []qlab.Cue{{UniqueID: "list-1", Name: "Main", Type: "Cue List"}}
+	want := GenerateMockShow(42, 5, 20, 4, 5)
+
+	cues, err := Compile(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws, _ := link.workspace()
+	ids, err := ApplyPlan(t.Context(), ws, "list-1", Plan(cues))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q1, ok := mock.FindCue("ws-1", ids[blockKey("S1 Q1")])
+	if !ok || q1.Type != "Group" || q1.Name != "S1 Q1" {
+		t.Errorf("got %+v, want group S1 Q1", q1)
+	}
+	start, _ := mock.FindCue("ws-1", ids[blockKey("S1 Q1")+"/0"])
+	target, err := qlab.GetCueProperty(t.Context(), ws.Client(), ws.ID, start.UniqueID, qlab.PropCueTargetID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := mock.FindCue("ws-1", target); !ok {
+		t.Errorf("action cue %s targets missing cue %q", start.UniqueID, target)
+	}
+
+	got, err := Decompile(t.Context(), ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Tracks, want.Tracks) {
+		t.Errorf("tracks differ")
+	}
+	if !reflect.DeepEqual(got.Blocks, want.Blocks) {
+		t.Errorf("blocks differ")
+	}
+	if !slices.Equal(sortedTriggers(got), sortedTriggers(want)) {
+		t.Errorf("triggers differ")
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"qrun/lib/qlab"
+)
+
+// notesPrefix marks the first line of a cue's Notes as a Qrun record. The
+// rest of that line is the record as JSON; anything after it is left to the
+// operator.
+const notesPrefix = "qrun "
+
+// cueRecord is the structured data Qrun stores in the Notes of the cues it
+// authors. Tracks are Memo cues; blocks of type "cue" are Groups, since they
+// are what the operator GOes; other blocks are whatever cue carries their
+// media. Triggers are stored on their source block. Index is a block's
+// place in the show, which the cue list does not keep: cue blocks are at
+// its root, ahead of the others.
+type cueRecord struct {
+	Kind     string          `json:"kind"`
+	ID       string          `json:"id"`
+	Index    int             `json:"index,omitempty"`
+	Type     string          `json:"type,omitempty"`
+	Track    string          `json:"track,omitempty"`
+	Loop     bool            `json:"loop,omitempty"`
+	Triggers []triggerRecord `json:"triggers,omitempty"`
+}
+
+type triggerRecord struct {
+	Signal  string          `json:"signal"`
+	Targets []TriggerTarget `json:"targets"`
+}
+
+const (
+	recordTrack = "track"
+	recordBlock = "block"
+)
+
+func formatNotes(rec cueRecord) (string, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return "", err
+	}
+	return notesPrefix + string(data), nil
+}
+
+// parseNotes returns the record in notes, or false if the cue was not
+// authored by Qrun.
+func parseNotes(notes string) (cueRecord, bool, error) {
+	line, _, _ := strings.Cut(notes, "\n")
+	data, ok := strings.CutPrefix(line, notesPrefix)
+	if !ok {
+		return cueRecord{}, false, nil
+	}
+	var rec cueRecord
+	if err := json.Unmarshal([]byte(data), &rec); err != nil {
+		return cueRecord{}, true, err
+	}
+	return rec, true, nil
+}
+
+// Decompile reads the cue list holding the show and rebuilds the Show from
+// the cues Qrun authored. Blocks are put back in the order of their recorded
+// index, everything else in cue list order. Cues without a Qrun record are
+// ignored. The result is not validated.
+func Decompile(ctx context.Context, ws *qlab.WorkspaceHandle) (*Show, error) {
+	list, err := showList(ctx, ws)
+	if err != nil {
+		return nil, err
+	}
+	d := &decompiler{
+		show:   &Show{},
+		tracks: map[string]bool{},
+		blocks: map[string]bool{},
+		index:  map[*Block]int{},
+	}
+	var walk func(cues []qlab.Cue) error
+	walk = func(cues []qlab.Cue) error {
+		for _, cue := range cues {
+			notes, err := qlab.GetCueProperty(ctx, ws.Client(), ws.ID, cue.UniqueID, qlab.PropNotes)
+			if err != nil {
+				return err
+			}
+			if err := d.add(cue, notes); err != nil {
+				return err
+			}
+			if err := walk(cue.Cues); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(list.Cues); err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(d.show.Blocks, func(a, b *Block) int {
+		return cmp.Compare(d.index[a], d.index[b])
+	})
+	return d.show, nil
+}
+
+type decompiler struct {
+	show   *Show
+	tracks map[string]bool
+	blocks map[string]bool
+	index  map[*Block]int
+}
+
+func (d *decompiler) add(cue qlab.Cue, notes string) error {
+	rec, ok, err := parseNotes(notes)
+	if !ok {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cue %s (%s): bad qrun record: %w", cue.Number, cue.UniqueID, err)
+	}
+
+	switch rec.Kind {
+	case recordTrack:
+		if d.tracks[rec.ID] {
+			return fmt.Errorf("cue %s (%s): duplicate track id %q", cue.Number, cue.UniqueID, rec.ID)
+		}
+		d.tracks[rec.ID] = true
+		d.show.Tracks = append(d.show.Tracks, &Track{ID: rec.ID, Name: cue.Name})
+	case recordBlock:
+		if d.blocks[rec.ID] {
+			return fmt.Errorf("cue %s (%s): duplicate block id %q", cue.Number, cue.UniqueID, rec.ID)
+		}
+		d.blocks[rec.ID] = true
+		b := &Block{
+			ID:    rec.ID,
+			Type:  rec.Type,
+			Track: rec.Track,
+			Name:  cue.Name,
+			Loop:  rec.Loop,
+		}
+		d.index[b] = rec.Index
+		d.show.Blocks = append(d.show.Blocks, b)
+		for _, tr := range rec.Triggers {
+			d.show.Triggers = append(d.show.Triggers, &Trigger{
+				Source:  TriggerSource{Block: rec.ID, Signal: tr.Signal},
+				Targets: tr.Targets,
+			})
+		}
+	default:
+		return fmt.Errorf("cue %s (%s): unknown qrun record kind %q", cue.Number, cue.UniqueID, rec.Kind)
+	}
+	return nil
+}
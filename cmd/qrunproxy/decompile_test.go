@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"qrun/lib/qlab"
+)
+
+// loadCueTree stores show in the mock as Qrun would author it: track Memos,
+// then a Group per cue block holding the blocks that follow it.
+func loadCueTree(t *testing.T, mock *qlab.MockServer, show *Show) {
+	t.Helper()
+	triggers := map[string][]triggerRecord{}
+	for _, tr := range show.Triggers {
+		triggers[tr.Source.Block] = append(triggers[tr.Source.Block], triggerRecord{Signal: tr.Source.Signal, Targets: tr.Targets})
+	}
+
+	n := 0
+	newCue := func(cueType, name string, rec cueRecord) qlab.Cue {
+		n++
+		cue := qlab.Cue{UniqueID: fmt.Sprintf("q-%d", n), Number: fmt.Sprint(n), Name: name, Type: cueType}
+		notes, err := formatNotes(rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mock.SetCueProperty(cue.UniqueID, "notes", notes+"\noperator notes")
+		return cue
+	}
+
+	var cues []qlab.Cue
+	for _, track := range show.Tracks {
+		cues = append(cues, newCue("Memo", track.Name, cueRecord{Kind: recordTrack, ID: track.ID}))
+	}
+	for i, b := range show.Blocks {
+		rec := cueRecord{Kind: recordBlock, ID: b.ID, Index: i, Type: b.Type, Track: b.Track, Loop: b.Loop, Triggers: triggers[b.ID]}
+		if b.Type == "cue" {
+			cues = append(cues, newCue("Group", b.Name, rec))
+			continue
+		}
+		group := &cues[len(cues)-1]
+		group.Cues = append(group.Cues, newCue("Memo", b.Name, rec))
+	}
+	cues = append(cues, qlab.Cue{UniqueID: "unrelated", Number: "99", Name: "House lights", Type: "Light"})
+	mock.CueLists["ws-1"] = []qlab.Cue{{UniqueID: "list-1", Name: "Main", Type: "Cue List", Cues: cues}}
+}
+
+func setupDecompileTest(t *testing.T) (*qlab.MockServer, *qlabLink) {
+	t.Helper()
+	mock, err := qlab.NewMockServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mock.Close() })
+
+	link, err := dialQlab(t.Context(), fmt.Sprintf("127.0.0.1:%d", mock.Port()), "ws-1", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { link.Close() })
+	return mock, link
+}
+
+func sortedTriggers(show *Show) []string {
+	var out []string
+	for _, tr := range show.Triggers {
+		data, _ := json.Marshal(tr)
+		out = append(out, string(data))
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestDecompile(t *testing.T) {
+	mock, link := setupDecompileTest(t)
+	want := GenerateMockShow(42, 5, 20, 4, 5)
+	loadCueTree(t, mock, want)
+
+	ws, _ := link.workspace()
+	got, err := Decompile(t.Context(), ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := got.Validate(); err != nil {
+		t.Fatalf("decompiled show failed validation: %v", err)
+	}
+	if !reflect.DeepEqual(got.Tracks, want.Tracks) {
+		t.Errorf("tracks differ")
+	}
+	if !reflect.DeepEqual(got.Blocks, want.Blocks) {
+		t.Errorf("blocks differ")
+	}
+	if !slices.Equal(sortedTriggers(got), sortedTriggers(want)) {
+		t.Errorf("triggers differ")
+	}
+	if _, err := BuildTimeline(got); err != nil {
+		t.Errorf("BuildTimeline: %v", err)
+	}
+}
+
+func TestDecompileErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		notes []string
+		want  string
+	}{
+		{"bad json", []string{`qrun {"kind":`}, "bad qrun record"},
+		{"unknown kind", []string{`qrun {"kind":"scene","id":"x"}`}, "unknown qrun record kind"},
+		{"duplicate block", []string{
+			`qrun {"kind":"block","id":"a","type":"cue"}`,
+			`qrun {"kind":"block","id":"a","type":"cue"}`,
+		}, `duplicate block id "a"`},
+		{"duplicate track", []string{
+			`qrun {"kind":"track","id":"t"}`,
+			`qrun {"kind":"track","id":"t"}`,
+		}, `duplicate track id "t"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, link := setupDecompileTest(t)
+			var cues []qlab.Cue
+			for i, notes := range tt.notes {
+				id := fmt.Sprintf("q-%d", i)
+				cues = append(cues, qlab.Cue{UniqueID: id, Number: fmt.Sprint(i), Type: "Memo"})
+				mock.SetCueProperty(id, "notes", notes)
+			}
+			mock.CueLists["ws-1"] = []qlab.Cue{{UniqueID: "list-1", Type: "Cue List", Cues: cues}}
+
+			ws, _ := link.workspace()
+			_, err := Decompile(t.Context(), ws)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecompileIgnoresForeignCues(t *testing.T) {
+	mock, link := setupDecompileTest(t)
+	mock.CueLists["ws-1"] = []qlab.Cue{{UniqueID: "list-1", Type: "Cue List", Cues: []qlab.Cue{
+		{UniqueID: "a", Number: "1", Type: "Audio"},
+		{UniqueID: "b", Number: "2", Type: "Memo"},
+	}}}
+	mock.SetCueProperty("b", "notes", "quick run, not qrun")
+
+	ws, _ := link.workspace()
+	show, err := Decompile(t.Context(), ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(show.Tracks) != 0 || len(show.Blocks) != 0 || len(show.Triggers) != 0 {
+		t.Errorf("got %+v, want an empty show", show)
+	}
+}
+
+func TestDecompileReadsShowList(t *testing.T) {
+	mock, link := setupDecompileTest(t)
+	mock.CueLists["ws-1"] = []qlab.Cue{
+		{UniqueID: "list-1", Type: "Cue List", Cues: []qlab.Cue{{UniqueID: "a", Number: "1", Type: "Audio"}}},
+		{UniqueID: "list-2", Type: "Cue List", Cues: []qlab.Cue{{UniqueID: "b", Number: "2", Type: "Group"}}},
+		{UniqueID: "list-3", Type: "Cue List", Cues: []qlab.Cue{{UniqueID: "c", Number: "3", Type: "Group"}}},
+	}
+	mock.SetCueProperty("b", "notes", `qrun {"kind":"block","id":"q1","type":"cue"}`)
+	mock.SetCueProperty("c", "notes", `qrun {"kind":"block","id":"q2","type":"cue"}`)
+
+	ws, _ := link.workspace()
+	show, err := Decompile(t.Context(), ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(show.Blocks) != 1 || show.Blocks[0].ID != "q1" {
+		t.Errorf("got blocks %+v, want only q1 from the first list with a record", show.Blocks)
+	}
+}
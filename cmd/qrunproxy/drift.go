@@ -0,0 +1,592 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"qrun/lib/qlab"
+)
+
+// DriftKind classifies a difference between the compiled show and Qlab.
+// "added" cues are in the show but missing from Qlab; "removed" cues are in
+// Qlab but not the show.
+type DriftKind string
+
+const (
+	DriftAdded      DriftKind = "added"
+	DriftRemoved    DriftKind = "removed"
+	DriftRenamed    DriftKind = "renamed"
+	DriftRetargeted DriftKind = "retargeted"
+	DriftRetimed    DriftKind = "retimed"
+	DriftChanged    DriftKind = "changed"
+)
+
+// Drift is one difference. Key is the compiled cue's key and CueID the Qlab
+// cue's unique ID; either is empty when the cue exists on one side only.
+type Drift struct {
+	Kind  DriftKind `json:"kind"`
+	Key   string    `json:"key,omitempty"`
+	CueID string    `json:"cue_id,omitempty"`
+	Name  string    `json:"name,omitempty"`
+	Field string    `json:"field,omitempty"`
+	Show  any       `json:"show,omitempty"`
+	Qlab  any       `json:"qlab,omitempty"`
+}
+
+func (d Drift) String() string {
+	id := d.Key
+	if id == "" {
+		id = d.CueID
+	}
+	if d.Field == "" {
+		return fmt.Sprintf("%s %s", d.Kind, id)
+	}
+	return fmt.Sprintf("%s %s %s: show %v, qlab %v", d.Kind, id, d.Field, d.Show, d.Qlab)
+}
+
+// liveCue is a cue read from Qlab with the properties the compiler sets.
+type liveCue struct {
+	qlab.Cue
+	notes    string
+	target   string
+	cont     qlab.ContinueMode
+	mode     qlab.GroupMode
+	preWait  time.Duration
+	postWait time.Duration
+	children []*liveCue
+}
+
+// readConcurrency bounds the property reads readLive keeps in flight.
+const readConcurrency = 16
+
+// readLive reads the cue tree of a cue list along with the properties the
+// compiler manages. Reads are pipelined, since a show has thousands of them.
+func readLive(ctx context.Context, ws *qlab.WorkspaceHandle, cues []qlab.Cue) ([]*liveCue, error) {
+	var all []*liveCue
+	var build func(cues []qlab.Cue) []*liveCue
+	build = func(cues []qlab.Cue) []*liveCue {
+		var out []*liveCue
+		for _, cue := range cues {
+			lc := &liveCue{Cue: cue}
+			all = append(all, lc)
+			lc.children = build(cue.Cues)
+			out = append(out, lc)
+		}
+		return out
+	}
+	root := build(cues)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	sem := make(chan struct{}, readConcurrency)
+	for _, lc := range all {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			if err := readLiveCue(ctx, ws, lc); err != nil {
+				errOnce.Do(func() { firstErr = err; cancel() })
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return root, nil
+}
+
+func readLiveCue(ctx context.Context, ws *qlab.WorkspaceHandle, lc *liveCue) error {
+	c, id, cueID := ws.Client(), ws.ID, lc.UniqueID
+	var err error
+	if lc.notes, err = qlab.GetCueProperty(ctx, c, id, cueID, qlab.PropNotes); err != nil {
+		return err
+	}
+	if lc.cont, err = qlab.GetCueProperty(ctx, c, id, cueID, qlab.PropContinueMode); err != nil {
+		return err
+	}
+	if lc.preWait, err = qlab.GetCueProperty(ctx, c, id, cueID, qlab.PropPreWait); err != nil {
+		return err
+	}
+	if lc.postWait, err = qlab.GetCueProperty(ctx, c, id, cueID, qlab.PropPostWait); err != nil {
+		return err
+	}
+	switch strings.ToLower(lc.Type) {
+	case "group":
+		lc.mode, err = qlab.GetCueProperty(ctx, c, id, cueID, qlab.PropGroupMode)
+	case "start", "stop", "fade":
+		lc.target, err = qlab.GetCueProperty(ctx, c, id, cueID, qlab.PropCueTargetID)
+	}
+	return err
+}
+
+// showList picks the cue list holding the show: the first with a Qrun record
+// anywhere in it, else the first list.
+func showList(ctx context.Context, ws *qlab.WorkspaceHandle) (qlab.Cue, error) {
+	lists, err := ws.CueLists(ctx)
+	if err != nil {
+		return qlab.Cue{}, err
+	}
+	if len(lists) == 0 {
+		return qlab.Cue{}, errors.New("workspace has no cue lists")
+	}
+	for _, list := range lists {
+		found, err := hasRecord(ctx, ws, list.Cues)
+		if err != nil {
+			return qlab.Cue{}, err
+		}
+		if found {
+			return list, nil
+		}
+	}
+	return lists[0], nil
+}
+
+func hasRecord(ctx context.Context, ws *qlab.WorkspaceHandle, cues []qlab.Cue) (bool, error) {
+	for _, cue := range cues {
+		notes, err := qlab.GetCueProperty(ctx, ws.Client(), ws.ID, cue.UniqueID, qlab.PropNotes)
+		if err != nil {
+			return false, err
+		}
+		if _, ok, _ := parseNotes(notes); ok {
+			return true, nil
+		}
+		if found, err := hasRecord(ctx, ws, cue.Cues); found || err != nil {
+			return found, err
+		}
+	}
+	return false, nil
+}
+
+// recordKey is the compiled key of a cue carrying a Qrun record.
+func recordKey(notes string) (string, bool) {
+	rec, ok, err := parseNotes(notes)
+	if !ok || err != nil {
+		return "", false
+	}
+	switch rec.Kind {
+	case recordTrack:
+		return "track:" + rec.ID, true
+	case recordBlock:
+		return blockKey(rec.ID), true
+	}
+	return "", false
+}
+
+var containerNames = map[string]string{
+	"Qrun Blocks": blocksKey,
+	"Qrun Tracks": tracksKey,
+}
+
+type differ struct {
+	root    []*liveCue
+	byID    map[string]*liveCue
+	byKey   map[string]*liveCue
+	keyOf   map[*liveCue]string
+	managed map[*liveCue]bool
+	drift   []Drift
+}
+
+// Diff compares a compiled cue tree with the live one. Cues carrying Qrun
+// records and the Qrun containers are matched by identity wherever they are;
+// other cues are matched by position within their matched parent. Live cues
+// outside anything Qrun manages are the operator's and are ignored.
+func Diff(want []*CompiledCue, live []*liveCue) []Drift {
+	d := matchCues(want, live)
+	var removed func(cues []*liveCue, inManaged bool)
+	removed = func(cues []*liveCue, inManaged bool) {
+		for _, lc := range cues {
+			if _, ok := d.keyOf[lc]; !ok && (inManaged || d.managed[lc]) {
+				d.drift = append(d.drift, Drift{Kind: DriftRemoved, CueID: lc.UniqueID, Name: lc.Name})
+				continue
+			}
+			removed(lc.children, inManaged || d.managed[lc])
+		}
+	}
+	removed(live, false)
+
+	d.compareTargets(want)
+	return d.drift
+}
+
+// matchCues pairs each compiled cue with its live cue, as Diff describes,
+// recording drift found along the way.
+func matchCues(want []*CompiledCue, live []*liveCue) *differ {
+	d := &differ{
+		root:    live,
+		byID:    map[string]*liveCue{},
+		byKey:   map[string]*liveCue{},
+		keyOf:   map[*liveCue]string{},
+		managed: map[*liveCue]bool{},
+	}
+	var index func(cues []*liveCue, root bool)
+	index = func(cues []*liveCue, root bool) {
+		for _, lc := range cues {
+			d.byID[lc.UniqueID] = lc
+			key, ok := recordKey(lc.notes)
+			if !ok && root && strings.EqualFold(lc.Type, "group") {
+				key, ok = containerNames[lc.Name]
+			}
+			if ok {
+				if _, dup := d.byKey[key]; !dup {
+					d.byKey[key] = lc
+					d.keyOf[lc] = key
+				}
+				d.managed[lc] = true
+			}
+			index(lc.children, false)
+		}
+	}
+	index(live, true)
+
+	d.match(want, "", nil, live)
+	return d
+}
+
+func (d *differ) match(want []*CompiledCue, parentKey string, parent *liveCue, siblings []*liveCue) {
+	for i, cue := range want {
+		lc := d.byKey[cue.Key]
+		if lc != nil && parent != nil {
+			if pos := slices.Index(siblings, lc); pos != i {
+				d.add(DriftChanged, cue, lc, "position", fmt.Sprintf("%s#%d", parentKey, i), d.position(lc))
+			}
+		}
+		if lc == nil && parent != nil && i < len(siblings) {
+			if _, claimed := d.keyOf[siblings[i]]; !claimed && !d.managed[siblings[i]] {
+				lc = siblings[i]
+				d.byKey[cue.Key] = lc
+				d.keyOf[lc] = cue.Key
+			}
+		}
+		if lc == nil {
+			d.drift = append(d.drift, Drift{Kind: DriftAdded, Key: cue.Key, Name: cue.Name})
+			continue
+		}
+		d.compare(cue, lc)
+		d.match(cue.Children, cue.Key, lc, lc.children)
+	}
+}
+
+// position describes where a live cue is, as parent key and index.
+func (d *differ) position(lc *liveCue) string {
+	if i := slices.Index(d.root, lc); i >= 0 {
+		return fmt.Sprintf("(list)#%d", i)
+	}
+	for parent := range d.keyOf {
+		if i := slices.Index(parent.children, lc); i >= 0 {
+			return fmt.Sprintf("%s#%d", d.keyOf[parent], i)
+		}
+	}
+	return "elsewhere"
+}
+
+func (d *differ) add(kind DriftKind, cue *CompiledCue, lc *liveCue, field string, show, live any) {
+	d.drift = append(d.drift, Drift{Kind: kind, Key: cue.Key, CueID: lc.UniqueID, Name: cue.Name, Field: field, Show: show, Qlab: live})
+}
+
+func (d *differ) compare(cue *CompiledCue, lc *liveCue) {
+	if !strings.EqualFold(cue.Type, lc.Type) {
+		d.add(DriftChanged, cue, lc, "type", cue.Type, lc.Type)
+	}
+	if cue.Name != lc.Name {
+		d.add(DriftRenamed, cue, lc, "name", cue.Name, lc.Name)
+	}
+	if line, _, _ := strings.Cut(lc.notes, "\n"); cue.Notes != "" && line != cue.Notes {
+		d.add(DriftChanged, cue, lc, "notes", cue.Notes, line)
+	}
+	if cue.Type == "group" && cue.Mode != lc.mode {
+		d.add(DriftChanged, cue, lc, "mode", cue.Mode, lc.mode)
+	}
+	if cue.Continue != lc.cont {
+		d.add(DriftRetimed, cue, lc, "continueMode", cue.Continue, lc.cont)
+	}
+	if lc.preWait != 0 {
+		d.add(DriftRetimed, cue, lc, "preWait", time.Duration(0).String(), lc.preWait.String())
+	}
+	if lc.postWait != 0 {
+		d.add(DriftRetimed, cue, lc, "postWait", time.Duration(0).String(), lc.postWait.String())
+	}
+}
+
+// compareTargets runs once every cue is matched, since a target may come
+// later in the tree than the cue pointing at it.
+func (d *differ) compareTargets(want []*CompiledCue) {
+	for _, cue := range want {
+		if lc := d.byKey[cue.Key]; lc != nil && cue.Target != "" {
+			if liveTarget := d.liveTarget(lc); liveTarget != cue.Target {
+				d.add(DriftRetargeted, cue, lc, "target", cue.Target, liveTarget)
+			}
+		}
+		d.compareTargets(cue.Children)
+	}
+}
+
+// liveTarget is the key of the cue lc targets, or its unique ID if it is
+// not matched.
+func (d *differ) liveTarget(lc *liveCue) string {
+	if key, ok := d.keyOf[d.byID[lc.target]]; ok {
+		return key
+	}
+	return lc.target
+}
+
+// DriftReport is the diff between the show and a workspace's show cue list.
+type DriftReport struct {
+	ListID  string  `json:"list_id"`
+	InSync  bool    `json:"in_sync"`
+	Changes []Drift `json:"changes"`
+}
+
+func diffWorkspace(ctx context.Context, ws *qlab.WorkspaceHandle, show *Show) (DriftReport, []*liveCue, error) {
+	want, err := Compile(show)
+	if err != nil {
+		return DriftReport{}, nil, err
+	}
+	list, err := showList(ctx, ws)
+	if err != nil {
+		return DriftReport{}, nil, err
+	}
+	live, err := readLive(ctx, ws, list.Cues)
+	if err != nil {
+		return DriftReport{}, nil, err
+	}
+	changes := Diff(want, live)
+	if changes == nil {
+		changes = []Drift{}
+	}
+	return DriftReport{ListID: list.UniqueID, InSync: len(changes) == 0, Changes: changes}, live, nil
+}
+
+// pushShow brings the Qrun-managed cues in the show cue list in line with a
+// compilation of show. The operator's own cues are left alone.
+func pushShow(ctx context.Context, ws *qlab.WorkspaceHandle, show *Show) error {
+	want, err := Compile(show)
+	if err != nil {
+		return err
+	}
+	list, err := showList(ctx, ws)
+	if err != nil {
+		return err
+	}
+	live, err := readLive(ctx, ws, list.Cues)
+	if err != nil {
+		return err
+	}
+	_, err = ApplyPlan(ctx, ws, list.UniqueID, syncPlan(want, live))
+	return err
+}
+
+// syncPlan is the plan that turns the live cue tree into want, changing only
+// what Diff reports. Matched cues keep their identity and are moved and
+// rewritten where they differ; missing cues are created. Qrun's cues that no
+// longer belong are deleted last, once everything kept has moved out of them.
+func syncPlan(want []*CompiledCue, live []*liveCue) []CueOp {
+	d := matchCues(want, live)
+	wantByKey := map[string]*CompiledCue{}
+	var index func(cues []*CompiledCue)
+	index = func(cues []*CompiledCue) {
+		for _, cue := range cues {
+			wantByKey[cue.Key] = cue
+			index(cue.Children)
+		}
+	}
+	index(want)
+	// A cue cannot change type, so one matched with the wrong type is
+	// replaced.
+	for lc, key := range d.keyOf {
+		if cue := wantByKey[key]; cue == nil || !strings.EqualFold(cue.Type, lc.Type) {
+			delete(d.byKey, key)
+			delete(d.keyOf, lc)
+		}
+	}
+
+	// children tracks each parent's children as the plan moves them, by key
+	// for matched cues and by unique ID for the rest.
+	node := func(lc *liveCue) string {
+		if key, ok := d.keyOf[lc]; ok {
+			return key
+		}
+		return lc.UniqueID
+	}
+	children := map[string][]string{}
+	var existing []CueOp
+	var walkLive func(parent string, cues []*liveCue)
+	walkLive = func(parent string, cues []*liveCue) {
+		for _, lc := range cues {
+			if key, ok := d.keyOf[lc]; ok {
+				existing = append(existing, CueOp{Op: "existing", Key: key, Value: lc.UniqueID})
+			}
+			children[parent] = append(children[parent], node(lc))
+			walkLive(node(lc), lc.children)
+		}
+	}
+	walkLive("", live)
+	place := func(parent, key string, i int) {
+		for p, kids := range children {
+			if j := slices.Index(kids, key); j >= 0 {
+				children[p] = slices.Delete(kids, j, j+1)
+				break
+			}
+		}
+		kids := children[parent]
+		children[parent] = slices.Insert(kids, min(i, len(kids)), key)
+	}
+
+	var creates, sets, targets []CueOp
+	var walk func(parent string, cues []*CompiledCue)
+	walk = func(parent string, cues []*CompiledCue) {
+		for i, cue := range cues {
+			lc := d.byKey[cue.Key]
+			switch {
+			case lc == nil:
+				creates = append(creates, CueOp{Op: "new", Key: cue.Key, Parent: parent, Index: i, CueType: cue.Type})
+				place(parent, cue.Key, i)
+				sets = append(sets, newCueSets(cue)...)
+			default:
+				// Like Diff, leave alone where the operator put top-level cues.
+				if kids := children[parent]; parent != "" && (i >= len(kids) || kids[i] != cue.Key) {
+					creates = append(creates, CueOp{Op: "move", Key: cue.Key, Parent: parent, Index: i})
+					place(parent, cue.Key, i)
+				}
+				sets = append(sets, changedSets(cue, lc)...)
+			}
+			if cue.Target != "" && (lc == nil || d.liveTarget(lc) != cue.Target) {
+				targets = append(targets, CueOp{Op: "target", Key: cue.Key, Value: cue.Target})
+			}
+			walk(cue.Key, cue.Children)
+		}
+	}
+	walk("", want)
+
+	// Children go before their parents, so no delete takes a cue with it.
+	var deletes []CueOp
+	var removed func(cues []*liveCue, inManaged bool)
+	removed = func(cues []*liveCue, inManaged bool) {
+		for _, lc := range cues {
+			managed := inManaged || d.managed[lc]
+			removed(lc.children, managed)
+			if _, ok := d.keyOf[lc]; !ok && managed {
+				deletes = append(deletes, CueOp{Op: "delete", Value: lc.UniqueID})
+			}
+		}
+	}
+	removed(live, false)
+
+	plan := append(existing, creates...)
+	plan = append(plan, sets...)
+	plan = append(plan, targets...)
+	return append(plan, deletes...)
+}
+
+// changedSets are the property writes that bring a matched cue in line with
+// cue, one for each difference compare reports.
+func changedSets(cue *CompiledCue, lc *liveCue) []CueOp {
+	var sets []CueOp
+	set := func(prop string, v any) {
+		sets = append(sets, CueOp{Op: "set", Key: cue.Key, Property: prop, Value: v})
+	}
+	if cue.Name != lc.Name {
+		set("name", cue.Name)
+	}
+	if line, _, _ := strings.Cut(lc.notes, "\n"); cue.Notes != "" && line != cue.Notes {
+		set("notes", cue.Notes)
+	}
+	if cue.Type == "group" && cue.Mode != lc.mode {
+		set("mode", cue.Mode)
+	}
+	if cue.Continue != lc.cont {
+		set("continueMode", cue.Continue)
+	}
+	if lc.preWait != 0 {
+		set("preWait", time.Duration(0))
+	}
+	if lc.postWait != 0 {
+		set("postWait", time.Duration(0))
+	}
+	return sets
+}
+
+func (s *server) handleDiff(w http.ResponseWriter, r *http.Request) {
+	ws, err := s.link.workspace()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	show, _ := s.state.get()
+	report, _, err := diffWorkspace(r.Context(), ws, show)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	s.publishDrift(report)
+	writeJSON(w, report)
+}
+
+// handleApply resolves drift in one direction: "qlab" rewrites Qlab from the
+// show, "show" reloads the show from Qlab and then rewrites the cues derived
+// from it, such as action cues named after their targets. It responds with
+// the new diff.
+func (s *server) handleApply(w http.ResponseWriter, r *http.Request) {
+	ws, err := s.link.workspace()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	var req struct {
+		Direction string `json:"direction"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch req.Direction {
+	case "qlab":
+		show, _ := s.state.get()
+		if err := pushShow(r.Context(), ws, show); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	case "show":
+		show, err := Decompile(r.Context(), ws)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		// Qlab holds neither the title nor templates.
+		current, _ := s.state.get()
+		show.Title, show.Templates = current.Title, current.Templates
+		if err := s.state.set("load show from Qlab", show); err != nil {
+			http.Error(w, fmt.Sprintf("show in Qlab is invalid: %v", err), http.StatusUnprocessableEntity)
+			return
+		}
+		if err := pushShow(r.Context(), ws, show); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	default:
+		http.Error(w, `direction must be "qlab" or "show"`, http.StatusBadRequest)
+		return
+	}
+
+	show, _ := s.state.get()
+	report, _, err := diffWorkspace(r.Context(), ws, show)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	s.publishDrift(report)
+	writeJSON(w, report)
+}
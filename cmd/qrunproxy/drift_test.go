@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"qrun/lib/qlab"
+)
+
+// setupDriftTest compiles smallShow into the mock's cue list next to an
+// operator cue, and returns the unique ID of each compiled cue by key.
+func setupDriftTest(t *testing.T) (*qlab.MockServer, *server, map[string]string) {
+	t.Helper()
+	mock, link := setupDecompileTest(t)
+	mock.CueLists["ws-1"] = []qlab.Cue{{UniqueID: "list-1", Name: "Main", Type: "Cue List", Cues: []qlab.Cue{
+		{UniqueID: "house", Number: "99", Name: "House lights", Type: "Light"},
+	}}}
+	state, err := newShowState(smallShow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cues, err := Compile(smallShow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws, _ := link.workspace()
+	ids, err := ApplyPlan(t.Context(), ws, "list-1", Plan(cues))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mock, &server{link: link, state: state}, ids
+}
+
+func driftSummary(changes []Drift) []string {
+	var out []string
+	for _, d := range changes {
+		id := d.Key
+		if id == "" {
+			id = d.Name
+		}
+		out = append(out, strings.TrimSpace(fmt.Sprintf("%s %s %s", d.Kind, id, d.Field)))
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestDrift(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(ctx context.Context, ws *qlab.WorkspaceHandle, ids map[string]string) error
+		want []string
+	}{
+		{"in sync", nil, nil},
+		{"renamed", func(ctx context.Context, ws *qlab.WorkspaceHandle, ids map[string]string) error {
+			return ws.CueSet(ctx, ids["block:wash"], "name", "Big Wash")
+		}, []string{"renamed block:wash name"}},
+		{"retargeted", func(ctx context.Context, ws *qlab.WorkspaceHandle, ids map[string]string) error {
+			return ws.CueSet(ctx, ids["block:q1/0"], "cueTargetID", ids["block:loop"])
+		}, []string{"retargeted block:q1/0 target"}},
+		{"retimed", func(ctx context.Context, ws *qlab.WorkspaceHandle, ids map[string]string) error {
+			if err := ws.CueSet(ctx, ids["block:hold/0"], "preWait", 2.0); err != nil {
+				return err
+			}
+			return ws.CueSet(ctx, ids["block:q1/0"], "continueMode", int32(qlab.NoContinue))
+		}, []string{"retimed block:hold/0 preWait", "retimed block:q1/0 continueMode"}},
+		{"deleted", func(ctx context.Context, ws *qlab.WorkspaceHandle, ids map[string]string) error {
+			return ws.DeleteCue(ctx, ids["block:q2/0"])
+		}, []string{"added block:q2/0"}},
+		{"extra cue", func(ctx context.Context, ws *qlab.WorkspaceHandle, ids map[string]string) error {
+			id, err := ws.NewCue(ctx, "memo", "")
+			if err != nil {
+				return err
+			}
+			if err := ws.CueSet(ctx, id, "name", "Extra"); err != nil {
+				return err
+			}
+			return ws.MoveCue(ctx, id, ids["block:wash"], 1)
+		}, []string{"removed Extra"}},
+		{"moved", func(ctx context.Context, ws *qlab.WorkspaceHandle, ids map[string]string) error {
+			return ws.MoveCue(ctx, ids["block:loop"], "list-1", 0)
+		}, []string{"changed block:loop position"}},
+		{"operator cue", func(ctx context.Context, ws *qlab.WorkspaceHandle, ids map[string]string) error {
+			id, err := ws.NewCue(ctx, "audio", "")
+			if err != nil {
+				return err
+			}
+			return ws.MoveCue(ctx, id, "list-1", 0)
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, srv, ids := setupDriftTest(t)
+			ws, _ := srv.link.workspace()
+			if tt.edit != nil {
+				if err := tt.edit(t.Context(), ws, ids); err != nil {
+					t.Fatal(err)
+				}
+			}
+			show, _ := srv.state.get()
+			report, _, err := diffWorkspace(t.Context(), ws, show)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := driftSummary(report.Changes); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if report.InSync != (len(tt.want) == 0) || report.ListID != "list-1" {
+				t.Errorf("got %+v", report)
+			}
+		})
+	}
+}
+
+func postApply(t *testing.T, srv *server, body string) (int, DriftReport) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	srv.handleApply(rec, httptest.NewRequest("POST", "/api/qlab/apply", strings.NewReader(body)))
+	var report DriftReport
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rec.Code, report
+}
+
+func TestDriftApplyToQlab(t *testing.T) {
+	mock, srv, ids := setupDriftTest(t)
+	ws, _ := srv.link.workspace()
+	ctx := t.Context()
+	if err := ws.CueSet(ctx, ids["block:wash"], "name", "Big Wash"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.CueSet(ctx, ids["block:hold/0"], "preWait", 2.0); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.MoveCue(ctx, ids["block:loop"], "list-1", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.DeleteCue(ctx, ids["block:q2/0"]); err != nil {
+		t.Fatal(err)
+	}
+	extra, err := ws.NewCue(ctx, "memo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.MoveCue(ctx, extra, ids["block:wash"], 1); err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ResetRequests()
+	code, report := postApply(t, srv, `{"direction":"qlab"}`)
+	if code != http.StatusOK || !report.InSync {
+		t.Fatalf("got %d %+v, want in sync", code, report)
+	}
+	if _, ok := mock.FindCue("ws-1", "house"); !ok {
+		t.Error("operator cue was deleted")
+	}
+	if _, ok := mock.FindCue("ws-1", extra); ok {
+		t.Error("extra cue in a block was kept")
+	}
+	for key, id := range ids {
+		if key == "block:q2/0" {
+			continue
+		}
+		if _, ok := mock.FindCue("ws-1", id); !ok {
+			t.Errorf("%s was replaced, want it updated in place", key)
+		}
+	}
+	var news int
+	for _, req := range mock.Requests() {
+		if strings.HasSuffix(req.Address, "/new") {
+			news++
+		}
+	}
+	if news != 1 {
+		t.Errorf("created %d cues, want only the deleted one", news)
+	}
+}
+
+func TestDriftApplyToShow(t *testing.T) {
+	_, srv, ids := setupDriftTest(t)
+	ws, _ := srv.link.workspace()
+	if err := ws.CueSet(t.Context(), ids["block:wash"], "name", "Big Wash"); err != nil {
+		t.Fatal(err)
+	}
+
+	code, report := postApply(t, srv, `{"direction":"show"}`)
+	if code != http.StatusOK || !report.InSync {
+		t.Fatalf("got %d %+v, want in sync", code, report)
+	}
+	show, _ := srv.state.get()
+	if i := slices.IndexFunc(show.Blocks, func(b *Block) bool { return b.ID == "wash" }); i < 0 || show.Blocks[i].Name != "Big Wash" {
+		t.Errorf("show was not reloaded from Qlab: %+v", show.Blocks)
+	}
+
+	if code, _ := postApply(t, srv, `{"direction":"sideways"}`); code != http.StatusBadRequest {
+		t.Errorf("got %d, want 400", code)
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// editError is an edit the show can't take, with the HTTP status to
+// report it with.
+type editError struct {
+	status  int
+	message string
+}
+
+func (e *editError) Error() string {
+	return e.message
+}
+
+func notFound(format string, args ...any) error {
+	return &editError{http.StatusNotFound, fmt.Sprintf(format, args...)}
+}
+
+func badEdit(format string, args ...any) error {
+	return &editError{http.StatusBadRequest, fmt.Sprintf(format, args...)}
+}
+
+func (show *Show) addTrack(t Track) error {
+	if t.ID == "" {
+		return badEdit("track id is required")
+	}
+	show.Tracks = append(show.Tracks, &t)
+	return nil
+}
+
+func (show *Show) updateTrack(id string, t Track) error {
+	i := slices.IndexFunc(show.Tracks, func(t *Track) bool { return t.ID == id })
+	if i < 0 {
+		return notFound("track %q not found", id)
+	}
+	if t.ID != "" && t.ID != id {
+		return badEdit("track id %q does not match %q; ids can't be changed", t.ID, id)
+	}
+	t.ID = id
+	show.Tracks[i] = &t
+	return nil
+}
+
+func (show *Show) deleteTrack(id string) error {
+	i := slices.IndexFunc(show.Tracks, func(t *Track) bool { return t.ID == id })
+	if i < 0 {
+		return notFound("track %q not found", id)
+	}
+	show.Tracks = slices.Delete(show.Tracks, i, i+1)
+	return nil
+}
+
+func (show *Show) moveTrack(id string, to int) error {
+	i := slices.IndexFunc(show.Tracks, func(t *Track) bool { return t.ID == id })
+	if i < 0 {
+		return notFound("track %q not found", id)
+	}
+	return move(show.Tracks, i, to)
+}
+
+// newBlock is a block to add. Every block but a cue needs its START
+// triggered, so Start names the signal that should, saving a separate
+// trigger edit that could not be made before the block exists.
+type newBlock struct {
+	Block
+	Start *TriggerSource `json:"start,omitempty"`
+}
+
+func (show *Show) addBlock(nb newBlock) error {
+	b := nb.Block
+	if b.ID == "" {
+		return badEdit("block id is required")
+	}
+	show.Blocks = append(show.Blocks, &b)
+	if nb.Start == nil {
+		return nil
+	}
+	target := TriggerTarget{Block: b.ID, Hook: "START"}
+	if i := show.findTrigger(*nb.Start); i >= 0 {
+		show.Triggers[i].Targets = append(show.Triggers[i].Targets, target)
+		return nil
+	}
+	return show.addTrigger(Trigger{
+		Source:  TriggerSource{Block: nb.Start.Block, Signal: nb.Start.Signal},
+		Targets: []TriggerTarget{target},
+	})
+}
+
+func (show *Show) updateBlock(id string, b Block) error {
+	i := slices.IndexFunc(show.Blocks, func(b *Block) bool { return b.ID == id })
+	if i < 0 {
+		return notFound("block %q not found", id)
+	}
+	if b.ID != "" && b.ID != id {
+		return badEdit("block id %q does not match %q; ids can't be changed", b.ID, id)
+	}
+	b.ID = id
+	show.Blocks[i] = &b
+	return nil
+}
+
+// deleteBlock removes a block along with the triggers it is the source of
+// and its place in other triggers' targets. Triggers left with no targets
+// are removed too.
+func (show *Show) deleteBlock(id string) error {
+	i := slices.IndexFunc(show.Blocks, func(b *Block) bool { return b.ID == id })
+	if i < 0 {
+		return notFound("block %q not found", id)
+	}
+	show.Blocks = slices.Delete(show.Blocks, i, i+1)
+	show.Triggers = slices.DeleteFunc(show.Triggers, func(t *Trigger) bool {
+		if t.Source.Block == id {
+			return true
+		}
+		t.Targets = slices.DeleteFunc(t.Targets, func(target TriggerTarget) bool { return target.Block == id })
+		return len(t.Targets) == 0
+	})
+	return nil
+}
+
+func (show *Show) moveBlock(id string, to int) error {
+	i := slices.IndexFunc(show.Blocks, func(b *Block) bool { return b.ID == id })
+	if i < 0 {
+		return notFound("block %q not found", id)
+	}
+	return move(show.Blocks, i, to)
+}
+
+func (show *Show) findTrigger(src TriggerSource) int {
+	return slices.IndexFunc(show.Triggers, func(t *Trigger) bool {
+		return t.Source.Block == src.Block && t.Source.Signal == src.Signal
+	})
+}
+
+func (show *Show) findBlock(id string) *Block {
+	for _, b := range show.Blocks {
+		if b.ID == id {
+			return b
+		}
+	}
+	return nil
+}
+
+func (show *Show) addTrigger(t Trigger) error {
+	if t.Source.Block == "" || t.Source.Signal == "" {
+		return badEdit("trigger source block and signal are required")
+	}
+	show.Triggers = append(show.Triggers, &t)
+	return nil
+}
+
+// updateTrigger replaces the trigger from src. The new trigger may have a
+// different source; an empty one keeps src.
+func (show *Show) updateTrigger(src TriggerSource, t Trigger) error {
+	i := show.findTrigger(src)
+	if i < 0 {
+		return notFound("trigger from %s/%s not found", src.Block, src.Signal)
+	}
+	if t.Source.Block == "" && t.Source.Signal == "" {
+		t.Source = src
+	}
+	show.Triggers[i] = &t
+	return nil
+}
+
+func (show *Show) deleteTrigger(src TriggerSource) error {
+	i := show.findTrigger(src)
+	if i < 0 {
+		return notFound("trigger from %s/%s not found", src.Block, src.Signal)
+	}
+	show.Triggers = slices.Delete(show.Triggers, i, i+1)
+	return nil
+}
+
+func (show *Show) moveTrigger(src TriggerSource, to int) error {
+	i := show.findTrigger(src)
+	if i < 0 {
+		return notFound("trigger from %s/%s not found", src.Block, src.Signal)
+	}
+	return move(show.Triggers, i, to)
+}
+
+// move moves s[from] to index to, shifting the elements between.
+func move[T any](s []T, from, to int) error {
+	if to < 0 || to >= len(s) {
+		return badEdit("index %d out of range", to)
+	}
+	v := s[from]
+	if from < to {
+		copy(s[from:to], s[from+1:to+1])
+	} else {
+		copy(s[to+1:from+1], s[to:from])
+	}
+	s[to] = v
+	return nil
+}
+
+func etag(rev int) string {
+	return `"` + strconv.Itoa(rev) + `"`
+}
+
+func (s *server) handleGetShow(w http.ResponseWriter, r *http.Request) {
+	show, rev := s.state.revision()
+	w.Header().Set("ETag", etag(rev))
+	writeJSON(w, show)
+}
+
+// handleDiagnostics serves the warnings for the current show, tagged with
+// its ETag so they can be matched to it. The show is valid, so there are
+// no errors; those come back from the edit that would have caused them.
+func (s *server) handleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	show, rev := s.state.revision()
+	w.Header().Set("ETag", etag(rev))
+	writeJSON(w, show.Diagnose())
+}
+
+// editShow applies f to the show for an editing request, recording the
+// change as op. See changeShow.
+func (s *server) editShow(w http.ResponseWriter, r *http.Request, op string, f func(*Show) error) {
+	s.changeShow(w, r, func(rev int) error { return s.state.edit(op, rev, f) })
+}
+
+// changeShow runs change for a request that changes the show. The request
+// must carry the ETag of the show it was based on in If-Match, so that one
+// operator's edit can't silently undo another's. The reply is the changed
+// show and its new ETag.
+func (s *server) changeShow(w http.ResponseWriter, r *http.Request, change func(rev int) error) {
+	match := r.Header.Get("If-Match")
+	if match == "" {
+		http.Error(w, "If-Match is required; send the ETag of the show being edited", http.StatusPreconditionRequired)
+		return
+	}
+	rev, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(match, "W/"), `"`))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("bad If-Match %q", match), http.StatusBadRequest)
+		return
+	}
+
+	err = change(rev)
+	var showErr *ShowError
+	var editErr *editError
+	switch {
+	case err == nil:
+	case errors.Is(err, errStaleRevision):
+		_, current := s.state.revision()
+		w.Header().Set("ETag", etag(current))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusPreconditionFailed)
+		json.NewEncoder(w).Encode(map[string]any{"error": err.Error(), "revision": current})
+		return
+	case errors.Is(err, errNothingToUndo), errors.Is(err, errNothingToRedo):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.As(err, &showErr):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(showErr)
+		return
+	case errors.As(err, &editErr):
+		http.Error(w, editErr.message, editErr.status)
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.handleGetShow(w, r)
+}
+
+func decodeEdit[T any](w http.ResponseWriter, r *http.Request) (T, bool) {
+	var v T
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return v, false
+	}
+	return v, true
+}
+
+type moveRequest struct {
+	Index *int `json:"index"`
+}
+
+func decodeMove(w http.ResponseWriter, r *http.Request) (int, bool) {
+	req, ok := decodeEdit[moveRequest](w, r)
+	if !ok {
+		return 0, false
+	}
+	if req.Index == nil {
+		http.Error(w, "index is required", http.StatusBadRequest)
+		return 0, false
+	}
+	return *req.Index, true
+}
+
+func triggerSource(r *http.Request) TriggerSource {
+	return TriggerSource{Block: r.PathValue("block"), Signal: r.PathValue("signal")}
+}
+
+func (s *server) editHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/show/tracks", func(w http.ResponseWriter, r *http.Request) {
+		if t, ok := decodeEdit[Track](w, r); ok {
+			s.editShow(w, r, "add track "+t.ID, func(show *Show) error { return show.addTrack(t) })
+		}
+	})
+	mux.HandleFunc("PUT /api/show/tracks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if t, ok := decodeEdit[Track](w, r); ok {
+			s.editShow(w, r, "update track "+r.PathValue("id"), func(show *Show) error { return show.updateTrack(r.PathValue("id"), t) })
+		}
+	})
+	mux.HandleFunc("DELETE /api/show/tracks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		s.editShow(w, r, "delete track "+r.PathValue("id"), func(show *Show) error { return show.deleteTrack(r.PathValue("id")) })
+	})
+	mux.HandleFunc("POST /api/show/tracks/{id}/move", func(w http.ResponseWriter, r *http.Request) {
+		if to, ok := decodeMove(w, r); ok {
+			s.editShow(w, r, fmt.Sprintf("move track %s to %d", r.PathValue("id"), to), func(show *Show) error { return show.moveTrack(r.PathValue("id"), to) })
+		}
+	})
+
+	mux.HandleFunc("POST /api/show/blocks", func(w http.ResponseWriter, r *http.Request) {
+		if b, ok := decodeEdit[newBlock](w, r); ok {
+			s.editShow(w, r, "add block "+b.ID, func(show *Show) error { return show.addBlock(b) })
+		}
+	})
+	mux.HandleFunc("PUT /api/show/blocks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if b, ok := decodeEdit[Block](w, r); ok {
+			s.editShow(w, r, "update block "+r.PathValue("id"), func(show *Show) error { return show.updateBlock(r.PathValue("id"), b) })
+		}
+	})
+	mux.HandleFunc("DELETE /api/show/blocks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		s.editShow(w, r, "delete block "+r.PathValue("id"), func(show *Show) error { return show.deleteBlock(r.PathValue("id")) })
+	})
+	mux.HandleFunc("POST /api/show/blocks/{id}/move", func(w http.ResponseWriter, r *http.Request) {
+		if to, ok := decodeMove(w, r); ok {
+			s.editShow(w, r, fmt.Sprintf("move block %s to %d", r.PathValue("id"), to), func(show *Show) error { return show.moveBlock(r.PathValue("id"), to) })
+		}
+	})
+
+	mux.HandleFunc("POST /api/show/triggers", func(w http.ResponseWriter, r *http.Request) {
+		if t, ok := decodeEdit[Trigger](w, r); ok {
+			s.editShow(w, r, "add trigger from "+t.Source.Block+"/"+t.Source.Signal, func(show *Show) error { return show.addTrigger(t) })
+		}
+	})
+	mux.HandleFunc("PUT /api/show/triggers/{block}/{signal}", func(w http.ResponseWriter, r *http.Request) {
+		if t, ok := decodeEdit[Trigger](w, r); ok {
+			s.editShow(w, r, "update trigger from "+r.PathValue("block")+"/"+r.PathValue("signal"), func(show *Show) error { return show.updateTrigger(triggerSource(r), t) })
+		}
+	})
+	mux.HandleFunc("DELETE /api/show/triggers/{block}/{signal}", func(w http.ResponseWriter, r *http.Request) {
+		s.editShow(w, r, "delete trigger from "+r.PathValue("block")+"/"+r.PathValue("signal"), func(show *Show) error { return show.deleteTrigger(triggerSource(r)) })
+	})
+	mux.HandleFunc("POST /api/show/triggers/{block}/{signal}/move", func(w http.ResponseWriter, r *http.Request) {
+		if to, ok := decodeMove(w, r); ok {
+			s.editShow(w, r, fmt.Sprintf("move trigger from %s/%s to %d", r.PathValue("block"), r.PathValue("signal"), to), func(show *Show) error { return show.moveTrigger(triggerSource(r), to) })
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEditShow(t *testing.T) {
+	state, err := newShowState(smallShow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &server{state: state}
+	ts := httptest.NewServer(srv.handler(fstest.MapFS{}))
+	t.Cleanup(ts.Close)
+
+	do := func(method, path, ifMatch, body string) (*http.Response, string) {
+		t.Helper()
+		req, err := http.NewRequestWithContext(t.Context(), method, ts.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp, string(b)
+	}
+	var etag string
+	edit := func(method, path, body string, wantStatus int) string {
+		t.Helper()
+		resp, got := do(method, path, etag, body)
+		if resp.StatusCode != wantStatus {
+			t.Fatalf("%s %s %s: got status %d, want %d: %s", method, path, body, resp.StatusCode, wantStatus, got)
+		}
+		if resp.StatusCode == http.StatusOK {
+			etag = resp.Header.Get("ETag")
+		}
+		return got
+	}
+	ids := func(f func(*Show) []string) []string {
+		show, _ := state.get()
+		return f(show)
+	}
+	trackIDs := func(show *Show) []string {
+		var out []string
+		for _, t := range show.Tracks {
+			out = append(out, t.ID)
+		}
+		return out
+	}
+	q1Targets := func(show *Show) []string {
+		var out []string
+		for _, target := range show.Triggers[show.findTrigger(TriggerSource{Block: "q1", Signal: "GO"})].Targets {
+			out = append(out, target.Block)
+		}
+		return out
+	}
+	// hasError reports whether a rejected edit's reply lists an error that
+	// match picks out.
+	hasError := func(body string, match func(Diagnostic) bool) bool {
+		t.Helper()
+		var e ShowError
+		if err := json.Unmarshal([]byte(body), &e); err != nil {
+			t.Fatalf("%v: %s", err, body)
+		}
+		return slices.ContainsFunc(e.Diagnostics, func(d Diagnostic) bool {
+			return d.Severity == SeverityError && match(d)
+		})
+	}
+
+	resp, _ := do("GET", "/api/show", "", "")
+	if etag = resp.Header.Get("ETag"); etag != `"1"` {
+		t.Fatalf("ETag %s, want \"1\"", etag)
+	}
+
+	edit("POST", "/api/show/tracks", `{"id":"t2","name":"Sound"}`, http.StatusOK)
+	if etag != `"2"` {
+		t.Errorf("ETag %s after an edit, want \"2\"", etag)
+	}
+	_, timeline := state.get()
+	if len(timeline.Tracks) != 4 {
+		t.Errorf("timeline has %d tracks after adding one, want 4", len(timeline.Tracks))
+	}
+
+	// Another operator still holding revision 1 is turned away.
+	if resp, body := do("POST", "/api/show/tracks", `"1"`, `{"id":"t3"}`); resp.StatusCode != http.StatusPreconditionFailed || resp.Header.Get("ETag") != `"2"` {
+		t.Errorf("stale edit: got %d %s", resp.StatusCode, body)
+	}
+	if resp, _ := do("POST", "/api/show/tracks", "", `{"id":"t3"}`); resp.StatusCode != http.StatusPreconditionRequired {
+		t.Errorf("edit without If-Match: got %d", resp.StatusCode)
+	}
+
+	// Nothing starts a block added on its own.
+	body := edit("POST", "/api/show/blocks", `{"id":"sting","type":"media","track":"t2","name":"Sting"}`, http.StatusUnprocessableEntity)
+	if !hasError(body, func(d Diagnostic) bool { return d.Block == "sting" }) {
+		t.Errorf("got %s, want an error about sting", body)
+	}
+	edit("POST", "/api/show/blocks", `{"id":"sting","type":"media","track":"t2","name":"Sting","start":{"block":"q1","signal":"GO"}}`, http.StatusOK)
+	if got, want := ids(q1Targets), []string{"wash", "hold", "sting"}; !slices.Equal(got, want) {
+		t.Errorf("q1 GO targets %q, want %q", got, want)
+	}
+
+	body = edit("PUT", "/api/show/blocks/sting", `{"type":"media","track":"t9","name":"Sting"}`, http.StatusUnprocessableEntity)
+	if !hasError(body, func(d Diagnostic) bool { return d.Block == "sting" && d.Track == "t9" }) {
+		t.Errorf("got %s, want an error about sting on t9", body)
+	}
+	body = edit("PUT", "/api/show/triggers/q1/GO", `{"targets":[{"block":"wash","hook":"START"},{"block":"gone","hook":"START"}]}`, http.StatusUnprocessableEntity)
+	if !hasError(body, func(d Diagnostic) bool {
+		return d.Trigger != nil && *d.Trigger == (TriggerSource{Block: "q1", Signal: "GO"})
+	}) {
+		t.Errorf("got %s, want an error about the q1 GO trigger", body)
+	}
+
+	edit("POST", "/api/show/tracks/t2/move", `{"index":0}`, http.StatusOK)
+	if got, want := ids(trackIDs), []string{"t2", "t0", "t1"}; !slices.Equal(got, want) {
+		t.Errorf("tracks %q, want %q", got, want)
+	}
+	edit("POST", "/api/show/tracks/t2/move", `{"index":3}`, http.StatusBadRequest)
+
+	edit("DELETE", "/api/show/blocks/sting", "", http.StatusOK)
+	if got, want := ids(q1Targets), []string{"wash", "hold"}; !slices.Equal(got, want) {
+		t.Errorf("q1 GO targets %q after deleting sting, want %q", got, want)
+	}
+	edit("DELETE", "/api/show/tracks/t2", "", http.StatusOK)
+	edit("DELETE", "/api/show/tracks/t1", "", http.StatusUnprocessableEntity)
+	edit("DELETE", "/api/show/triggers/q9/GO", "", http.StatusNotFound)
+	edit("PUT", "/api/show/tracks/t0", `{"id":"t5","name":"Lights"}`, http.StatusBadRequest)
+
+	if got := ids(trackIDs); !slices.Equal(got, []string{"t0", "t1"}) {
+		t.Errorf("tracks %q after failed edits", got)
+	}
+	if etag != `"6"` {
+		t.Errorf("ETag %s, want \"6\" after five edits", etag)
+	}
+
+	// Leaving the loop running forever is allowed, with a warning.
+	edit("DELETE", "/api/show/triggers/q2/GO", "", http.StatusOK)
+	resp, body = do("GET", "/api/show/diagnostics", "", "")
+	var diags []Diagnostic
+	if err := json.Unmarshal([]byte(body), &diags); err != nil {
+		t.Fatalf("%v: %s", err, body)
+	}
+	if len(diags) != 1 || diags[0].Code != "runs-forever" || diags[0].Block != "loop" || resp.Header.Get("ETag") != etag {
+		t.Errorf("got diagnostics %s with ETag %s, want loop running forever at %s", body, resp.Header.Get("ETag"), etag)
+	}
+}
+
+func TestMove(t *testing.T) {
+	tests := []struct {
+		from, to int
+		want     string
+	}{
+		{0, 3, "bcda"},
+		{3, 0, "dabc"},
+		{1, 2, "acbd"},
+		{2, 2, "abcd"},
+	}
+	for _, tt := range tests {
+		s := []string{"a", "b", "c", "d"}
+		if err := move(s, tt.from, tt.to); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(s, ""); got != tt.want {
+			t.Errorf("move(%d, %d) = %s, want %s", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
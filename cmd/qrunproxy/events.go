@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"qrun/lib/qlab"
+)
+
+const (
+	defaultEventHistory   = 256
+	defaultClientBuffer   = 64
+	defaultEventKeepalive = 15 * time.Second
+)
+
+// feedEvent is one message on the SSE feed. Data is JSON.
+type feedEvent struct {
+	id   uint64
+	typ  string
+	data []byte
+}
+
+// eventFeed fans events out to SSE clients. It keeps recent events so a
+// client reconnecting with Last-Event-ID misses nothing, and the latest
+// event of each type so a new client starts from the current state.
+//
+// A client whose buffer fills is dropped rather than allowed to hold up the
+// others; it reconnects and resumes from history.
+type eventFeed struct {
+	History   int
+	Buffer    int
+	Keepalive time.Duration
+
+	mu      sync.Mutex
+	lastID  uint64
+	history []feedEvent
+	latest  map[string]feedEvent
+	clients map[*feedClient]struct{}
+}
+
+type feedClient struct {
+	ch chan feedEvent
+}
+
+func newEventFeed() *eventFeed {
+	return &eventFeed{
+		History:   defaultEventHistory,
+		Buffer:    defaultClientBuffer,
+		Keepalive: defaultEventKeepalive,
+		latest:    map[string]feedEvent{},
+		clients:   map[*feedClient]struct{}{},
+	}
+}
+
+// publish sends v as an event of type typ to every client.
+func (f *eventFeed) publish(typ string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(map[string]string{"error": err.Error()})
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastID++
+	ev := feedEvent{id: f.lastID, typ: typ, data: data}
+	f.history = append(f.history, ev)
+	if over := len(f.history) - f.History; over > 0 {
+		f.history = slices.Delete(f.history, 0, over)
+	}
+	f.latest[typ] = ev
+	for c := range f.clients {
+		select {
+		case c.ch <- ev:
+		default:
+			delete(f.clients, c)
+			close(c.ch)
+		}
+	}
+}
+
+// subscribe registers a client and returns the events it must be sent
+// first: those after lastEventID if history still holds them, otherwise
+// the latest event of each type. resumed reports which, and lastID is the
+// newest event ID at the time; everything later reaches c.
+func (f *eventFeed) subscribe(lastEventID string) (c *feedClient, first []feedEvent, lastID uint64, resumed bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c = &feedClient{ch: make(chan feedEvent, f.Buffer)}
+	f.clients[c] = struct{}{}
+
+	if id, err := strconv.ParseUint(lastEventID, 10, 64); err == nil && id <= f.lastID {
+		oldest := f.lastID + 1
+		if len(f.history) > 0 {
+			oldest = f.history[0].id
+		}
+		if id+1 >= oldest {
+			for _, ev := range f.history {
+				if ev.id > id {
+					first = append(first, ev)
+				}
+			}
+			return c, first, f.lastID, true
+		}
+	}
+	for _, ev := range f.latest {
+		first = append(first, ev)
+	}
+	slices.SortFunc(first, func(a, b feedEvent) int { return cmp.Compare(a.id, b.id) })
+	return c, first, f.lastID, false
+}
+
+func (f *eventFeed) unsubscribe(c *feedClient) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.clients[c]; ok {
+		delete(f.clients, c)
+		close(c.ch)
+	}
+}
+
+func writeEvent(w *bytes.Buffer, ev feedEvent) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.id, ev.typ, ev.data)
+}
+
+// handleEvents streams the feed as Server-Sent Events.
+func (s *server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if s.events == nil {
+		http.NotFound(w, r)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	c, first, lastID, resumed := s.events.subscribe(r.Header.Get("Last-Event-ID"))
+	defer s.events.unsubscribe(c)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	var buf bytes.Buffer
+	for _, ev := range first {
+		writeEvent(&buf, ev)
+	}
+	if !resumed {
+		// The snapshot may end before the newest event; move the client's
+		// last event ID up so a reconnect does not replay what it covers.
+		if len(first) == 0 || first[len(first)-1].id < lastID {
+			fmt.Fprintf(&buf, "id: %d\n\n", lastID)
+		}
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	keepalive := time.NewTicker(s.events.Keepalive)
+	defer keepalive.Stop()
+	for {
+		buf.Reset()
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-c.ch:
+			if !ok {
+				return
+			}
+			writeEvent(&buf, ev)
+		case <-keepalive.C:
+			buf.WriteString(": keepalive\n\n")
+		}
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// showEvent carries the show with its revision, which clients send back in
+// If-Match when they edit it.
+type showEvent struct {
+	Revision int   `json:"revision"`
+	Show     *Show `json:"show"`
+}
+
+// feedEvents publishes show, timeline, history, run state and Qlab
+// connection changes until ctx is done.
+func (s *server) feedEvents(ctx context.Context) {
+	// Watchers run before the next change, so the revision is the show's.
+	publishShow := func(show *Show, timeline Timeline) {
+		_, rev := s.state.revision()
+		s.events.publish("show", showEvent{Revision: rev, Show: show})
+		s.events.publish("timeline", timeline)
+		s.events.publish("history", s.state.historyState())
+	}
+	s.state.watch(publishShow)
+	publishShow(s.state.get())
+
+	if s.run != nil {
+		s.run.watch(func(rs RunState) { s.events.publish("run", rs) })
+	}
+	if s.link == nil {
+		return
+	}
+
+	var last []byte
+	publishQlab := func() {
+		st := s.link.status()
+		if l := st.Liveness; l != nil {
+			// Latency changes on every thump; only transitions matter here.
+			st.Liveness = &qlab.Liveness{WorkspaceID: l.WorkspaceID, Alive: l.Alive}
+		}
+		data, _ := json.Marshal(st)
+		if !bytes.Equal(data, last) {
+			last = data
+			s.events.publish("qlab", json.RawMessage(data))
+		}
+	}
+	publishQlab()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.link.client.StateChanges():
+			case <-s.link.monitor.Events():
+			case <-s.link.selectionChanges():
+			}
+			publishQlab()
+		}
+	}()
+}
+
+// publishDrift reports the result of a comparison with Qlab, so clients can
+// warn while the two disagree.
+func (s *server) publishDrift(report DriftReport) {
+	if s.events != nil {
+		s.events.publish("drift", report)
+	}
+}
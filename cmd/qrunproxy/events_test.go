@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// sseEvent is one message read off an event stream. Comment holds the text
+// of a comment line, which is sent on its own.
+type sseEvent struct {
+	ID      string
+	Type    string
+	Data    string
+	Comment string
+}
+
+type sseStream struct {
+	t      *testing.T
+	resp   *http.Response
+	events chan sseEvent
+}
+
+func openEvents(t *testing.T, ts *httptest.Server, lastEventID string) *sseStream {
+	t.Helper()
+	req, err := http.NewRequestWithContext(t.Context(), "GET", ts.URL+"/api/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type %q", ct)
+	}
+	s := &sseStream{t: t, resp: resp, events: make(chan sseEvent, 64)}
+	go func() {
+		defer close(s.events)
+		sc := bufio.NewScanner(resp.Body)
+		var ev sseEvent
+		for sc.Scan() {
+			line := sc.Text()
+			switch {
+			case line == "":
+				s.events <- ev
+				ev = sseEvent{}
+			case strings.HasPrefix(line, ":"):
+				ev.Comment = strings.TrimSpace(line[1:])
+			case strings.HasPrefix(line, "id: "):
+				ev.ID = line[4:]
+			case strings.HasPrefix(line, "event: "):
+				ev.Type = line[7:]
+			case strings.HasPrefix(line, "data: "):
+				ev.Data = line[6:]
+			}
+		}
+	}()
+	return s
+}
+
+func (s *sseStream) next() sseEvent {
+	s.t.Helper()
+	select {
+	case ev, ok := <-s.events:
+		if !ok {
+			s.t.Fatal("stream closed")
+		}
+		return ev
+	case <-time.After(2 * time.Second):
+		s.t.Fatal("timed out waiting for an event")
+	}
+	return sseEvent{}
+}
+
+// types reads n events and returns their types.
+func (s *sseStream) types(n int) []string {
+	s.t.Helper()
+	var out []string
+	for range n {
+		out = append(out, s.next().Type)
+	}
+	return out
+}
+
+func setupEventsTest(t *testing.T) (*server, *httptest.Server) {
+	t.Helper()
+	state, err := newShowState(smallShow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &server{state: state, events: newEventFeed()}
+	srv.feedEvents(t.Context())
+	ts := httptest.NewServer(srv.handler(fstest.MapFS{}))
+	t.Cleanup(ts.Close)
+	return srv, ts
+}
+
+func renamedShow(name string) *Show {
+	show := smallShow()
+	show.Blocks[1].Name = name
+	return show
+}
+
+func TestEventsSnapshotAndLive(t *testing.T) {
+	srv, ts := setupEventsTest(t)
+	stream := openEvents(t, ts, "")
+
+	if got := strings.Join(stream.types(3), " "); got != "show timeline history" {
+		t.Fatalf("snapshot %q, want show, timeline then history", got)
+	}
+
+	if err := srv.state.set("rename", renamedShow("Dim")); err != nil {
+		t.Fatal(err)
+	}
+	ev := stream.next()
+	var se showEvent
+	if err := json.Unmarshal([]byte(ev.Data), &se); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Type != "show" || ev.ID != "4" || se.Revision != 2 || se.Show.Blocks[1].Name != "Dim" {
+		t.Errorf("got %s %s %s, want show 4 with the rename", ev.ID, ev.Type, ev.Data)
+	}
+	if ev := stream.next(); ev.Type != "timeline" || ev.ID != "5" {
+		t.Errorf("got %s %s, want timeline 5", ev.ID, ev.Type)
+	}
+	if ev := stream.next(); ev.Type != "history" || !strings.Contains(ev.Data, `"op":"rename"`) {
+		t.Errorf("got %s %s, want history with the rename", ev.Type, ev.Data)
+	}
+
+	srv.publishDrift(DriftReport{ListID: "list-1", Changes: []Drift{{Kind: DriftRenamed, Key: "block:wash"}}})
+	if ev := stream.next(); ev.Type != "drift" || !strings.Contains(ev.Data, "block:wash") {
+		t.Errorf("got %s %s, want the drift report", ev.Type, ev.Data)
+	}
+}
+
+func TestEventsResume(t *testing.T) {
+	srv, ts := setupEventsTest(t)
+	for _, name := range []string{"A", "B"} {
+		if err := srv.state.set("rename", renamedShow(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Events 1-9 are out; a client that saw 6 gets only 7 to 9.
+	stream := openEvents(t, ts, "6")
+	if ev := stream.next(); ev.ID != "7" || ev.Type != "show" || !strings.Contains(ev.Data, `"B"`) {
+		t.Errorf("got %s %s %s, want show 7", ev.ID, ev.Type, ev.Data)
+	}
+	if got := strings.Join(stream.types(2), " "); got != "timeline history" {
+		t.Errorf("got %q after show, want timeline then history", got)
+	}
+
+	// Once history has moved past a client it gets the snapshot again,
+	// followed by the current ID.
+	srv.events.History = 2
+	srv.publishDrift(DriftReport{InSync: true})
+	stream = openEvents(t, ts, "2")
+	want := []sseEvent{{ID: "7", Type: "show"}, {ID: "8", Type: "timeline"}, {ID: "9", Type: "history"}, {ID: "10", Type: "drift"}}
+	for _, w := range want {
+		if ev := stream.next(); ev.ID != w.ID || ev.Type != w.Type {
+			t.Errorf("got %s %s, want %s %s", ev.ID, ev.Type, w.ID, w.Type)
+		}
+	}
+
+	// So does one from a previous run of the proxy, whose IDs are ahead.
+	stream = openEvents(t, ts, "900")
+	if got := strings.Join(stream.types(4), " "); got != "show timeline history drift" {
+		t.Errorf("snapshot %q", got)
+	}
+}
+
+func TestEventsKeepalive(t *testing.T) {
+	srv, ts := setupEventsTest(t)
+	srv.events.Keepalive = 10 * time.Millisecond
+	stream := openEvents(t, ts, "")
+	stream.types(3)
+	if ev := stream.next(); ev.Comment != "keepalive" || ev.ID != "" {
+		t.Errorf("got %+v, want a keepalive comment", ev)
+	}
+}
+
+func TestEventsSlowClient(t *testing.T) {
+	feed := newEventFeed()
+	feed.Buffer = 2
+	slow, _, _, _ := feed.subscribe("")
+	fast, _, _, _ := feed.subscribe("")
+
+	// publish never waits for the slow client.
+	for i := range 10 {
+		feed.publish("run", i)
+		<-fast.ch
+	}
+
+	n := 0
+	for range slow.ch {
+		n++
+	}
+	if n != 2 {
+		t.Errorf("slow client got %d events before being dropped, want 2", n)
+	}
+	feed.mu.Lock()
+	_, kept := feed.clients[fast]
+	feed.mu.Unlock()
+	if !kept {
+		t.Error("fast client was dropped")
+	}
+	feed.unsubscribe(fast)
+
+	// A dropped client resumes where it left off.
+	_, first, lastID, resumed := feed.subscribe("2")
+	if !resumed || len(first) != 8 || first[0].id != 3 || lastID != 10 {
+		t.Errorf("resume after drop: resumed %v, %d events up to %d", resumed, len(first), lastID)
+	}
+}
+
+func TestEventsQlab(t *testing.T) {
+	mock, srv, ids := setupDriftTest(t)
+	mock.SetCueProperty(ids["block:wash/0"], "infiniteLoop", true)
+	mock.SetCueProperty(ids["block:hold/0"], "duration", 10.0)
+	srv.events = newEventFeed()
+	srv.run = newRunTracker(srv.link, srv.state)
+	srv.feedEvents(t.Context())
+	srv.run.refresh(t.Context())
+	ts := httptest.NewServer(srv.handler(fstest.MapFS{}))
+	t.Cleanup(ts.Close)
+
+	stream := openEvents(t, ts, "")
+	snapshot := stream.types(5)
+	slices.Sort(snapshot)
+	if got := strings.Join(snapshot, " "); got != "history qlab run show timeline" {
+		t.Fatalf("snapshot %q", got)
+	}
+	// The monitor may report the workspace alive at any point from here.
+	next := func() sseEvent {
+		for {
+			if ev := stream.next(); ev.Type != "qlab" {
+				return ev
+			}
+		}
+	}
+
+	ws, _ := srv.link.workspace()
+	if err := ws.CueStart(t.Context(), ids["block:q1"]); err != nil {
+		t.Fatal(err)
+	}
+	srv.run.refresh(t.Context())
+	ev := next()
+	var rs RunState
+	if err := json.Unmarshal([]byte(ev.Data), &rs); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Type != "run" || strings.Join(blockRuns(rs), " ") != "wash hold" {
+		t.Errorf("got %s %s, want run with wash and hold", ev.Type, ev.Data)
+	}
+
+	// Timers alone do not make an event.
+	mock.Advance(time.Second)
+	srv.run.refresh(t.Context())
+	srv.publishDrift(DriftReport{InSync: true})
+	if ev := next(); ev.Type != "drift" {
+		t.Errorf("got %s %s after a timer change, want nothing before drift", ev.Type, ev.Data)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+)
+
+const (
+	defaultHistorySize = 100
+	historyFileVersion = 1
+)
+
+var (
+	errNothingToUndo = errors.New("nothing to undo")
+	errNothingToRedo = errors.New("nothing to redo")
+)
+
+// historyEntry is one change to the show and the show it led to. The show
+// before it is the previous entry's, so it can be undone and redone.
+type historyEntry struct {
+	ID   int       `json:"id"`
+	Op   string    `json:"op"`
+	Time time.Time `json:"time"`
+	Show *Show     `json:"show"`
+}
+
+// HistoryOp describes a change without the shows.
+type HistoryOp struct {
+	ID   int       `json:"id"`
+	Op   string    `json:"op"`
+	Time time.Time `json:"time"`
+}
+
+// HistoryState is what undo and redo would do: Undo holds the changes that
+// can be undone, the next one last, and Redo those that can be redone, the
+// next one first.
+type HistoryState struct {
+	Undo []HistoryOp `json:"undo"`
+	Redo []HistoryOp `json:"redo"`
+}
+
+// history is a bounded list of changes with a cursor. Changes before the
+// cursor can be undone and those after it redone; recording a new change
+// discards the ones after it. Base is the show before the first change.
+type history struct {
+	Max     int            `json:"max"`
+	Base    *Show          `json:"base,omitempty"`
+	Entries []historyEntry `json:"entries"`
+	Pos     int            `json:"pos"`
+	NextID  int            `json:"next_id"`
+}
+
+func newHistory() *history {
+	return &history{Max: defaultHistorySize, Entries: []historyEntry{}, NextID: 1}
+}
+
+func (h *history) record(op string, before, after *Show) {
+	if h.Pos == 0 {
+		h.Base = before
+	}
+	h.Entries = append(h.Entries[:h.Pos], historyEntry{
+		ID:   h.NextID,
+		Op:   op,
+		Time: time.Now(),
+		Show: after,
+	})
+	h.NextID++
+	if over := len(h.Entries) - h.Max; over > 0 {
+		h.Base = h.Entries[over-1].Show
+		h.Entries = slices.Delete(h.Entries, 0, over)
+	}
+	h.Pos = len(h.Entries)
+}
+
+// before is the show before entry i.
+func (h *history) before(i int) *Show {
+	if i == 0 {
+		return h.Base
+	}
+	return h.Entries[i-1].Show
+}
+
+func (h *history) state() HistoryState {
+	hs := HistoryState{Undo: []HistoryOp{}, Redo: []HistoryOp{}}
+	for i, e := range h.Entries {
+		op := HistoryOp{ID: e.ID, Op: e.Op, Time: e.Time}
+		if i < h.Pos {
+			hs.Undo = append(hs.Undo, op)
+		} else {
+			hs.Redo = append(hs.Redo, op)
+		}
+	}
+	return hs
+}
+
+// current is the show the history leaves off at, or nil if it is empty.
+func (h *history) current() *Show {
+	if len(h.Entries) == 0 {
+		return nil
+	}
+	return h.before(h.Pos)
+}
+
+func (h *history) clone() *history {
+	c := *h
+	c.Entries = slices.Clone(h.Entries)
+	return &c
+}
+
+// historyPath is where the history of the show file at showPath is kept.
+func historyPath(showPath string) string {
+	return showPath + ".history"
+}
+
+type historyFile struct {
+	Version int `json:"version"`
+	*history
+}
+
+func saveHistoryFile(path string, h *history) error {
+	h = h.clone()
+	if h.Base != nil {
+		h.Base = fileShow(h.Base)
+	}
+	for i, e := range h.Entries {
+		h.Entries[i].Show = fileShow(e.Show)
+	}
+	data, err := json.Marshal(historyFile{Version: historyFileVersion, history: h})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'))
+}
+
+// loadHistoryFile reads the history saved for show. A missing history is
+// empty. So is one that doesn't end at show, which has been edited without
+// qrun since; its changes can't be undone from here.
+func loadHistoryFile(path string, show *Show) (*history, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return newHistory(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	hf := historyFile{history: newHistory()}
+	if err := json.Unmarshal(data, &hf); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if hf.Version != historyFileVersion {
+		return nil, fmt.Errorf("%s: unknown history version %d", path, hf.Version)
+	}
+	h := hf.history
+	if h.Max <= 0 || len(h.Entries) > h.Max || h.Pos < 0 || h.Pos > len(h.Entries) ||
+		len(h.Entries) > 0 && h.Base == nil || slices.ContainsFunc(h.Entries, func(e historyEntry) bool { return e.Show == nil }) {
+		return nil, fmt.Errorf("%s: history is corrupt", path)
+	}
+	if cur := h.current(); cur == nil || !sameShow(cur, show) {
+		return newHistory(), nil
+	}
+	return h, nil
+}
+
+func sameShow(a, b *Show) bool {
+	aj, err := json.Marshal(fileShow(a))
+	if err != nil {
+		return false
+	}
+	bj, err := json.Marshal(fileShow(b))
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(aj, bj)
+}
+
+// handleHistory serves the undo and redo lists.
+func (s *server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.state.historyState())
+}
+
+func (s *server) handleUndo(w http.ResponseWriter, r *http.Request) {
+	s.changeShow(w, r, s.state.undo)
+}
+
+func (s *server) handleRedo(w http.ResponseWriter, r *http.Request) {
+	s.changeShow(w, r, s.state.redo)
+}
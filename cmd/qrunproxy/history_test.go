@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func historyOps(ops []HistoryOp) []string {
+	var out []string
+	for _, op := range ops {
+		out = append(out, op.Op)
+	}
+	return out
+}
+
+func TestUndoRedo(t *testing.T) {
+	state, err := newShowState(smallShow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &server{state: state}
+	h := srv.handler(fstest.MapFS{})
+
+	send := func(method, path, body string, wantStatus int) {
+		t.Helper()
+		_, rev := state.revision()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("If-Match", etag(rev))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != wantStatus {
+			t.Fatalf("%s: got status %d, want %d: %s", path, rec.Code, wantStatus, rec.Body)
+		}
+	}
+	check := func(wantTracks int, wantName string, wantUndo, wantRedo []string) {
+		t.Helper()
+		show, _ := state.get()
+		if len(show.Tracks) != wantTracks || show.Blocks[1].Name != wantName {
+			t.Errorf("got %d tracks and wash named %q, want %d and %q", len(show.Tracks), show.Blocks[1].Name, wantTracks, wantName)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/history", nil))
+		var hs HistoryState
+		if err := json.Unmarshal(rec.Body.Bytes(), &hs); err != nil {
+			t.Fatal(err)
+		}
+		if got := historyOps(hs.Undo); !slices.Equal(got, wantUndo) {
+			t.Errorf("undo %q, want %q", got, wantUndo)
+		}
+		if got := historyOps(hs.Redo); !slices.Equal(got, wantRedo) {
+			t.Errorf("redo %q, want %q", got, wantRedo)
+		}
+	}
+
+	send("POST", "/api/show/tracks", `{"id":"t2","name":"Sound"}`, http.StatusOK)
+	send("PUT", "/api/show/blocks/wash", `{"type":"light","track":"t0","name":"Dim"}`, http.StatusOK)
+	check(3, "Dim", []string{"add track t2", "update block wash"}, nil)
+
+	send("POST", "/api/undo", "", http.StatusOK)
+	check(3, "Wash", []string{"add track t2"}, []string{"update block wash"})
+	send("POST", "/api/redo", "", http.StatusOK)
+	check(3, "Dim", []string{"add track t2", "update block wash"}, nil)
+	send("POST", "/api/redo", "", http.StatusConflict)
+
+	send("POST", "/api/undo", "", http.StatusOK)
+	send("POST", "/api/undo", "", http.StatusOK)
+	check(2, "Wash", nil, []string{"add track t2", "update block wash"})
+	send("POST", "/api/undo", "", http.StatusConflict)
+
+	// A new edit drops what could have been redone.
+	send("POST", "/api/show/tracks", `{"id":"t3","name":"Video 2"}`, http.StatusOK)
+	check(3, "Wash", []string{"add track t3"}, nil)
+
+	// Undo is an edit like any other, so it too needs the current revision.
+	req := httptest.NewRequest("POST", "/api/undo", nil)
+	req.Header.Set("If-Match", `"1"`)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("stale undo: got status %d", rec.Code)
+	}
+}
+
+func TestHistoryBounded(t *testing.T) {
+	h := newHistory()
+	h.Max = 3
+	before := smallShow()
+	for _, op := range []string{"a", "b", "c", "d", "e"} {
+		after := renamedShow(op)
+		h.record(op, before, after)
+		before = after
+	}
+	hs := h.state()
+	if got := historyOps(hs.Undo); !slices.Equal(got, []string{"c", "d", "e"}) || hs.Undo[0].ID != 3 {
+		t.Errorf("undo %q starting at id %d, want c, d, e from 3", got, hs.Undo[0].ID)
+	}
+	// Undoing all that is left goes back to the show the oldest kept
+	// change started from.
+	if got := h.before(0).Blocks[1].Name; got != "b" {
+		t.Errorf("oldest change starts from wash named %q, want b", got)
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	state, err := newShowState(smallShow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"A", "B", "C"} {
+		if err := state.set("rename to "+name, renamedShow(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, rev := state.revision()
+	if err := state.undo(rev); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "show.json.history")
+	_, hist := state.snapshot()
+	if err := saveHistoryFile(path, hist); err != nil {
+		t.Fatal(err)
+	}
+
+	// The history picks up where it left off with the show it ended at.
+	show, _ := state.get()
+	h, err := loadHistoryFile(path, show)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := newShowState(show)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded.restoreHistory(h)
+	hs := loaded.historyState()
+	if got, want := historyOps(hs.Undo), []string{"rename to A", "rename to B"}; !slices.Equal(got, want) {
+		t.Errorf("undo %q, want %q", got, want)
+	}
+	if got, want := historyOps(hs.Redo), []string{"rename to C"}; !slices.Equal(got, want) {
+		t.Errorf("redo %q, want %q", got, want)
+	}
+	_, rev = loaded.revision()
+	if err := loaded.undo(rev); err != nil {
+		t.Fatal(err)
+	}
+	if show, _ := loaded.get(); show.Blocks[1].Name != "A" {
+		t.Errorf("undo after loading gave wash named %q, want A", show.Blocks[1].Name)
+	}
+
+	// A show edited elsewhere since starts a new history.
+	if h, err := loadHistoryFile(path, renamedShow("Elsewhere")); err != nil || len(h.Entries) != 0 {
+		t.Errorf("got %d entries, %v; want an empty history", len(h.Entries), err)
+	}
+	if h, err := loadHistoryFile(path+".missing", show); err != nil || len(h.Entries) != 0 {
+		t.Errorf("got %d entries, %v; want an empty history", len(h.Entries), err)
+	}
+
+	// A history longer than its bound is corrupt.
+	hist.Max = 2
+	if err := saveHistoryFile(path, hist); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadHistoryFile(path, show); err == nil {
+		t.Error("loaded a history with more entries than its max")
+	}
+}
+
+func TestShowSaver(t *testing.T) {
+	state, err := newShowState(smallShow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "show.json")
+	saver := newShowSaver(path, state)
+	for _, name := range []string{"A", "B", "C"} {
+		if err := state.set("rename to "+name, renamedShow(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	saver.Close()
+
+	show, err := loadShowFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if show.Blocks[1].Name != "C" {
+		t.Errorf("saved wash named %q, want the latest, C", show.Blocks[1].Name)
+	}
+	h, err := loadHistoryFile(historyPath(path), show)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := historyOps(h.state().Undo), []string{"rename to A", "rename to B", "rename to C"}; !slices.Equal(got, want) {
+		t.Errorf("saved undo %q, want %q", got, want)
+	}
+
+	// Changes after Close are not saved, and don't block.
+	if err := state.set("rename to D", renamedShow("D")); err != nil {
+		t.Fatal(err)
+	}
+}
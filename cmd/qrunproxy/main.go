@@ -11,7 +11,9 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"strings"
+	"syscall"
 )
 
 //go:embed static
@@ -21,26 +23,85 @@ func main() {
 	addr := flag.String("addr", ":8080", "listen address")
 	runAndExitStr := flag.String("run-and-exit", "", "command to run after server starts, then exit")
 	printTimeline := flag.Bool("print-timeline-and-exit", false, "print timeline JSON and exit")
+	printSchema := flag.Bool("print-show-schema-and-exit", false, "print the JSON Schema for show files and exit")
+	showPath := flag.String("show", "", "show file to load, and to save edits to")
+	qlabAddr := flag.String("qlab", "", "Qlab host[:port] to connect to (disabled if empty)")
+	qlabWorkspace := flag.String("qlab-workspace", "", "Qlab workspace ID or name to select at startup (may be picked later via /api/qlab/workspace)")
+	qlabPasscode := flag.String("qlab-passcode", "", "Qlab workspace passcode")
+	qlabUDP := flag.Bool("qlab-udp", false, "Talk to Qlab over UDP instead of TCP")
+	showFromQlab := flag.Bool("show-from-qlab", false, "load the show from the selected Qlab workspace instead of generating a mock show")
+	resetFade := flag.Duration("reset-fade", defaultResetFade, "fade time for blocks stopped by a reset to a row")
+	oscAddr := flag.String("osc", "", "listen address for inbound OSC over UDP and TCP (disabled if empty)")
 	flag.Parse()
 
+	if *printSchema {
+		fmt.Print(showSchema)
+		return
+	}
+	if *showPath != "" && *showFromQlab {
+		fmt.Fprintln(os.Stderr, "Error: -show and -show-from-qlab are exclusive")
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	var runAndExit []string
 	if *runAndExitStr != "" {
 		runAndExit = strings.Fields(*runAndExitStr)
 	}
 
-	show := GenerateMockShow(42, 5, 20, 4, 5)
-	if err := show.Validate(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error validating show: %v\n", err)
-		os.Exit(1)
+	var link *qlabLink
+	if *qlabAddr != "" {
+		var err error
+		link, err = dialQlab(ctx, *qlabAddr, *qlabWorkspace, *qlabPasscode, *qlabUDP)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error connecting to Qlab: %v\n", err)
+			os.Exit(1)
+		}
+		defer link.Close()
 	}
 
-	timeline, err := BuildTimeline(show)
+	var show *Show
+	if *showPath != "" {
+		var err error
+		show, err = loadShowFile(*showPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading show: %v\n", err)
+			os.Exit(1)
+		}
+	} else if *showFromQlab {
+		ws, err := link.workspace()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading show from Qlab: %v\n", err)
+			os.Exit(1)
+		}
+		show, err = Decompile(ctx, ws)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading show from Qlab: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		show = GenerateMockShow(42, 5, 20, 4, 5)
+	}
+	state, err := newShowState(show)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error building timeline: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error loading show: %v\n", err)
 		os.Exit(1)
 	}
 
+	if *showPath != "" {
+		hist, err := loadHistoryFile(historyPath(*showPath), show)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading show history: %v\n", err)
+			os.Exit(1)
+		}
+		state.restoreHistory(hist)
+		defer newShowSaver(*showPath, state).Close()
+	}
+
 	if *printTimeline {
+		_, timeline := state.get()
 		enc := json.NewEncoder(os.Stdout)
 		enc.SetIndent("", "  ")
 		if err := enc.Encode(timeline); err != nil {
@@ -56,14 +117,42 @@ func main() {
 		os.Exit(1)
 	}
 
-	mux := http.NewServeMux()
-	mux.Handle("/", http.FileServer(http.FS(sub)))
-	mux.HandleFunc("/api/show", func(w http.ResponseWriter, r *http.Request) {
-		writeJSON(w, show)
-	})
-	mux.HandleFunc("/api/timeline", func(w http.ResponseWriter, r *http.Request) {
-		writeJSON(w, timeline)
-	})
+	api := &server{link: link, state: state, resetFade: *resetFade, events: newEventFeed()}
+	api.audit = newAuditLog(os.Stdout, api.events)
+	if link != nil {
+		api.run = newRunTracker(link, state)
+		go api.run.run(ctx)
+	}
+	api.feedEvents(ctx)
+
+	if *oscAddr != "" {
+		oscSrv, oscListen, err := serveOSC(*oscAddr, api.newOSCDispatcher(ctx))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listening for OSC: %v\n", err)
+			os.Exit(1)
+		}
+		defer oscSrv.Close()
+		fmt.Printf("Listening for OSC on %s\n", oscListen)
+	}
+
+	if ws, err := link.workspace(); err == nil {
+		show, _ := state.get()
+		report, _, err := diffWorkspace(ctx, ws, show)
+		if err == nil {
+			api.publishDrift(report)
+		}
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Error comparing show with Qlab: %v\n", err)
+		case !report.InSync:
+			fmt.Fprintf(os.Stderr, "Qlab cue list differs from the show in %d places; review GET /api/qlab/diff and resolve with POST /api/qlab/apply before running:\n", len(report.Changes))
+			for _, d := range report.Changes {
+				fmt.Fprintf(os.Stderr, "  %s\n", d)
+			}
+		}
+	}
+
+	mux := api.handler(sub)
 
 	if len(runAndExit) > 0 {
 		ln, err := net.Listen("tcp", *addr)
@@ -96,7 +185,15 @@ func main() {
 		os.Exit(1)
 	}
 	fmt.Printf("Listening on %s\n", ln.Addr())
-	if err := http.Serve(ln, mux); err != nil {
+	srv := &http.Server{
+		Handler:     mux,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+	if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
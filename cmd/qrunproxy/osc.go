@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"qrun/lib/osc"
+	"qrun/lib/qlab"
+)
+
+const oscCommandTimeout = 5 * time.Second
+
+// newOSCDispatcher registers the inbound OSC commands: /qrun/go, and
+// /qrun/reset with the row as an int32 argument. A pattern matches each
+// method at most once, so no message runs more than one reset. Resets fade
+// blocks out over the proxy's default.
+func (s *server) newOSCDispatcher(ctx context.Context) *osc.Dispatcher {
+	d := osc.NewDispatcher()
+	d.HandleFunc("/qrun/go", func(r *osc.Request) {
+		oscCommand(ctx, r, func(ctx context.Context, from string) error {
+			_, err := s.runCommand(ctx, from, "go", "", func(ctx context.Context, ws *qlab.WorkspaceHandle) error {
+				return ws.Go(ctx)
+			})
+			return err
+		})
+	})
+	d.HandleFunc("/qrun/reset", func(r *osc.Request) {
+		oscCommand(ctx, r, func(ctx context.Context, from string) error {
+			if len(r.Args) != 1 {
+				return errors.New("want the row as an int32 argument")
+			}
+			row, ok := r.Args[0].(int32)
+			if !ok {
+				return fmt.Errorf("want the row as an int32, got %T", r.Args[0])
+			}
+			if _, timeline := s.state.get(); row < 0 || int(row) >= timeline.rows() {
+				return fmt.Errorf("row %d out of range", row)
+			}
+			_, err := s.reset(ctx, from, int(row), s.resetFade)
+			return err
+		})
+	})
+	return d
+}
+
+func oscCommand(ctx context.Context, r *osc.Request, f func(ctx context.Context, from string) error) {
+	ctx, cancel := context.WithTimeout(ctx, oscCommandTimeout)
+	defer cancel()
+	from := "osc"
+	if r.RemoteAddr != nil {
+		from += " " + r.RemoteAddr.String()
+	}
+	if err := f(ctx, from); err != nil {
+		fmt.Fprintf(os.Stderr, "OSC %s from %s: %v\n", r.Address, r.RemoteAddr, err)
+	}
+}
+
+// serveOSC listens for OSC over both UDP and TCP on the same port, as Qlab
+// does, and returns the address it bound. For port 0 the free UDP port may
+// be taken for TCP, so it tries a few.
+func serveOSC(addr string, h osc.Handler) (*osc.Server, net.Addr, error) {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	for tries := 1; ; tries++ {
+		pc, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			return nil, nil, err
+		}
+		ln, err := net.Listen("tcp", pc.LocalAddr().String())
+		if err != nil {
+			pc.Close()
+			if port == "0" && tries < 10 {
+				continue
+			}
+			return nil, nil, err
+		}
+		srv := &osc.Server{Handler: h}
+		go srv.ServeUDP(pc)
+		go srv.ServeTCP(ln)
+		return srv, pc.LocalAddr(), nil
+	}
+}
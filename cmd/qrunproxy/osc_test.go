@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"qrun/lib/osc"
+	"qrun/lib/qlab"
+)
+
+// setupOSCTest serves OSC commands for the drift test's show and returns a
+// connection to send them on.
+func setupOSCTest(t *testing.T) (*qlab.MockServer, *server, net.Conn) {
+	t.Helper()
+	mock, api, _ := setupDriftTest(t)
+	api.audit = newAuditLog(nil, nil)
+	srv, addr, err := serveOSC("127.0.0.1:0", api.newOSCDispatcher(t.Context()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+
+	conn, err := net.Dial("udp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return mock, api, conn
+}
+
+func sendOSC(t *testing.T, conn net.Conn, addr string, args ...any) {
+	t.Helper()
+	data, err := osc.Message{Address: addr, Args: args}.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitRequest(t *testing.T, mock *qlab.MockServer, addr string) {
+	t.Helper()
+	deadline := time.Now().Add(oscCommandTimeout)
+	for time.Now().Before(deadline) {
+		if slices.ContainsFunc(mock.Requests(), func(r qlab.MockRequest) bool { return r.Address == addr }) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Qlab never received %s", addr)
+}
+
+// waitAudit waits for the audit log to hold n entries and returns them.
+func waitAudit(t *testing.T, srv *server, n int) []AuditEntry {
+	t.Helper()
+	deadline := time.Now().Add(oscCommandTimeout)
+	for time.Now().Before(deadline) {
+		if entries := srv.audit.list(); len(entries) >= n {
+			return entries
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("audit log never reached %d entries: %+v", n, srv.audit.list())
+	return nil
+}
+
+func TestOSCGo(t *testing.T) {
+	mock, srv, conn := setupOSCTest(t)
+	sendOSC(t, conn, "/qrun/go")
+	waitRequest(t, mock, "/workspace/ws-1/go")
+	if e := waitAudit(t, srv, 1)[0]; e.Command != "go" || e.Error != "" || !strings.HasPrefix(e.From, "osc ") {
+		t.Errorf("got audit entry %+v, want a go from OSC", e)
+	}
+}
+
+func TestOSCReset(t *testing.T) {
+	mock, srv, conn := setupOSCTest(t)
+	sendOSC(t, conn, "/qrun/reset", int32(100000))
+	sendOSC(t, conn, "/qrun/reset", "3")
+	sendOSC(t, conn, "/qrun/reset")
+	// A pattern matches the one reset method, so it resets once.
+	sendOSC(t, conn, "/qrun/rese?", int32(3))
+	waitRequest(t, mock, "/workspace/ws-1/cue_id/list-1/playbackPositionId")
+
+	var resets int
+	for _, r := range mock.Requests() {
+		if r.Address == "/workspace/ws-1/runningCues" {
+			resets++
+		}
+	}
+	if resets != 1 {
+		t.Errorf("got %d resets, want 1 (bad rows are ignored)", resets)
+	}
+	entries := waitAudit(t, srv, 1)
+	if e := entries[0]; len(entries) != 1 || e.Command != "reset to row 3" || e.Error != "" {
+		t.Errorf("got audit log %+v, want just the reset to row 3", entries)
+	}
+}
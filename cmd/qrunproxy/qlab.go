@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"qrun/lib/qlab"
+)
+
+var (
+	errQlabDisabled = errors.New("qlab is not configured")
+	errNoWorkspace  = errors.New("no Qlab workspace selected")
+)
+
+type QlabStatus struct {
+	Enabled     bool              `json:"enabled"`
+	State       qlab.ConnState    `json:"state"`
+	Workspace   string            `json:"workspace,omitempty"`
+	Connected   bool              `json:"connected"`
+	Permissions *qlab.Permissions `json:"permissions,omitempty"`
+	Liveness    *qlab.Liveness    `json:"liveness,omitempty"`
+}
+
+// qlabLink is the proxy's connection to Qlab and the workspace the operator
+// picked as the show.
+type qlabLink struct {
+	client  *qlab.Client
+	monitor *qlab.Monitor
+
+	mu      sync.Mutex
+	ws      *qlab.WorkspaceHandle
+	changes chan struct{}
+}
+
+func dialQlab(ctx context.Context, addr, workspaceID, passcode string, udp bool) (*qlabLink, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	port := qlab.DefaultPort
+	if err != nil {
+		host = addr
+	} else if port, err = strconv.Atoi(portStr); err != nil {
+		return nil, err
+	}
+
+	var client *qlab.Client
+	if udp {
+		client, err = qlab.DialUDP(host, port, qlab.DefaultReplyPort)
+	} else {
+		client, err = qlab.Dial(host, port)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	link := &qlabLink{
+		client:  client,
+		monitor: qlab.NewMonitor(client),
+		changes: make(chan struct{}, 1),
+	}
+	if workspaceID != "" {
+		if workspaceID, err = link.resolveWorkspace(ctx, workspaceID); err != nil {
+			client.Close()
+			return nil, err
+		}
+		if err := link.selectWorkspace(ctx, workspaceID, passcode); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	link.monitor.Start()
+	return link, nil
+}
+
+func (l *qlabLink) Close() error {
+	l.monitor.Stop()
+	return l.client.Close()
+}
+
+// resolveWorkspace maps a workspace display name to its ID. Names that match
+// no open workspace are assumed to be IDs.
+func (l *qlabLink) resolveWorkspace(ctx context.Context, nameOrID string) (string, error) {
+	spaces, err := l.client.Workspaces(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, ws := range spaces {
+		if ws.UniqueID == nameOrID {
+			return nameOrID, nil
+		}
+	}
+	for _, ws := range spaces {
+		if ws.DisplayName == nameOrID {
+			return ws.UniqueID, nil
+		}
+	}
+	return nameOrID, nil
+}
+
+// selectWorkspace connects to a workspace and makes it the show workspace,
+// disconnecting the previous one. The selection is cleared if Qlab closes
+// the workspace. Selecting the show workspace again changes nothing.
+func (l *qlabLink) selectWorkspace(ctx context.Context, workspaceID, passcode string) error {
+	ws, err := l.client.Connect(ctx, workspaceID, passcode)
+	if err != nil {
+		return err
+	}
+	if err := ws.EnableUpdates(ctx, true); err != nil {
+		l.mu.Lock()
+		selected := l.ws == ws
+		l.mu.Unlock()
+		if !selected {
+			ws.Disconnect(ctx)
+		}
+		return err
+	}
+
+	l.mu.Lock()
+	prev := l.ws
+	l.ws = ws
+	l.mu.Unlock()
+	if prev == ws {
+		return nil
+	}
+	l.changed()
+
+	if prev != nil {
+		prev.Disconnect(ctx)
+	}
+	go func() {
+		<-ws.Closed()
+		l.mu.Lock()
+		cleared := l.ws == ws
+		if cleared {
+			l.ws = nil
+		}
+		l.mu.Unlock()
+		if cleared {
+			l.changed()
+		}
+	}()
+	return nil
+}
+
+func (l *qlabLink) changed() {
+	select {
+	case l.changes <- struct{}{}:
+	default:
+	}
+}
+
+// selectionChanges is signalled when the show workspace is picked or closed.
+// Signals coalesce; workspace always reports the current selection.
+func (l *qlabLink) selectionChanges() <-chan struct{} {
+	return l.changes
+}
+
+// workspace returns the show workspace.
+func (l *qlabLink) workspace() (*qlab.WorkspaceHandle, error) {
+	if l == nil {
+		return nil, errQlabDisabled
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.ws == nil {
+		return nil, errNoWorkspace
+	}
+	return l.ws, nil
+}
+
+func (l *qlabLink) status() QlabStatus {
+	if l == nil {
+		return QlabStatus{State: qlab.StateDisconnected}
+	}
+	st := QlabStatus{
+		Enabled: true,
+		State:   l.client.State(),
+	}
+	ws, err := l.workspace()
+	if err != nil {
+		return st
+	}
+	st.Workspace = ws.ID
+	st.Connected = ws.Connected()
+	perms := ws.Permissions()
+	st.Permissions = &perms
+	if liveness, ok := l.monitor.Status(ws.ID); ok {
+		st.Liveness = &liveness
+	}
+	return st
+}
+
+func (l *qlabLink) handleWorkspaces(w http.ResponseWriter, r *http.Request) {
+	if l == nil {
+		http.Error(w, errQlabDisabled.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	spaces, err := l.client.Workspaces(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, spaces)
+}
+
+func (l *qlabLink) handleSelectWorkspace(w http.ResponseWriter, r *http.Request) {
+	if l == nil {
+		http.Error(w, errQlabDisabled.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	var req struct {
+		ID       string `json:"id"`
+		Passcode string `json:"passcode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ID == "" {
+		http.Error(w, "missing workspace id", http.StatusBadRequest)
+		return
+	}
+	if err := l.selectWorkspace(r.Context(), req.ID, req.Passcode); err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, qlab.ErrBadPasscode) {
+			status = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	writeJSON(w, l.status())
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"qrun/lib/qlab"
+)
+
+func setupQlabTest(t *testing.T) (*qlab.MockServer, *qlabLink) {
+	t.Helper()
+	mock, err := qlab.NewMockServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mock.Close() })
+	mock.Workspaces = []qlab.Workspace{
+		{DisplayName: "Show", UniqueID: "ws-1"},
+		{DisplayName: "Rehearsal", UniqueID: "ws-2"},
+	}
+
+	link, err := dialQlab(t.Context(), fmt.Sprintf("127.0.0.1:%d", mock.Port()), "", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { link.Close() })
+	return mock, link
+}
+
+func TestQlabWorkspaces(t *testing.T) {
+	_, link := setupQlabTest(t)
+
+	rec := httptest.NewRecorder()
+	link.handleWorkspaces(rec, httptest.NewRequest("GET", "/api/qlab/workspaces", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+	var spaces []qlab.Workspace
+	if err := json.Unmarshal(rec.Body.Bytes(), &spaces); err != nil {
+		t.Fatal(err)
+	}
+	if len(spaces) != 2 || spaces[1].UniqueID != "ws-2" {
+		t.Errorf("got %+v", spaces)
+	}
+}
+
+func TestQlabSelectWorkspace(t *testing.T) {
+	mock, link := setupQlabTest(t)
+
+	if _, err := link.workspace(); !errors.Is(err, errNoWorkspace) {
+		t.Fatalf("got %v, want errNoWorkspace", err)
+	}
+
+	rec := httptest.NewRecorder()
+	body := strings.NewReader(`{"id":"ws-2"}`)
+	link.handleSelectWorkspace(rec, httptest.NewRequest("POST", "/api/qlab/workspace", body))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+	var st struct {
+		Workspace   string `json:"workspace"`
+		Connected   bool   `json:"connected"`
+		Permissions string `json:"permissions"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Workspace != "ws-2" || !st.Connected || st.Permissions != "view|edit|control" {
+		t.Errorf("got %+v, want ws-2 connected with full access", st)
+	}
+
+	// Selecting it again is no change.
+	<-link.selectionChanges()
+	if err := link.selectWorkspace(t.Context(), "ws-2", ""); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-link.selectionChanges():
+		t.Error("selecting the show workspace again signalled a change")
+	default:
+	}
+
+	mock.SendUpdate("/update/workspace/ws-2/disconnect")
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := link.workspace(); errors.Is(err, errNoWorkspace) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for selection to clear")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestQlabSelectWorkspaceMissingID(t *testing.T) {
+	_, link := setupQlabTest(t)
+
+	rec := httptest.NewRecorder()
+	link.handleSelectWorkspace(rec, httptest.NewRequest("POST", "/api/qlab/workspace", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("got status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestQlabSelectWorkspaceBadPasscode(t *testing.T) {
+	mock, link := setupQlabTest(t)
+	mock.Passcodes["ws-1"] = map[string]qlab.Permissions{"1234": qlab.FullAccess}
+
+	rec := httptest.NewRecorder()
+	body := strings.NewReader(`{"id":"ws-1","passcode":"0000"}`)
+	link.handleSelectWorkspace(rec, httptest.NewRequest("POST", "/api/qlab/workspace", body))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("got status %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestQlabWorkspaceByName(t *testing.T) {
+	mock, _ := setupQlabTest(t)
+
+	link, err := dialQlab(t.Context(), fmt.Sprintf("127.0.0.1:%d", mock.Port()), "Rehearsal", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer link.Close()
+	if st := link.status(); st.Workspace != "ws-2" {
+		t.Errorf("got workspace %q, want ws-2", st.Workspace)
+	}
+}
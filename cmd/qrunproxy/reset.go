@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"time"
+
+	"qrun/lib/qlab"
+)
+
+// defaultResetFade is how long blocks that should not be running at the
+// reset row take to fade out.
+const defaultResetFade = 2 * time.Second
+
+// blockSpan is the rows over which a block runs: it starts on the row of the
+// first signal that starts it and is gone by the first row that fades or
+// ends it, or its own END row if it has defined timing.
+type blockSpan struct {
+	start int
+	end   int
+}
+
+// blockSpans follows the trigger graph to find when each non-cue block runs.
+// A block reached by a same-track chain starts on its predecessor's END row,
+// not on its own START cell, which the layout pushes further down.
+func (tl *Timeline) blockSpans() map[string]blockSpan {
+	spans := map[string]blockSpan{}
+	for _, b := range tl.show.Blocks {
+		if b.Type == "cue" {
+			continue
+		}
+		span := blockSpan{start: math.MaxInt, end: math.MaxInt}
+		if b.hasDefinedTiming() {
+			span.end = tl.findCell(b.ID, "END").row
+		}
+		spans[b.ID] = span
+	}
+	for _, t := range tl.show.Triggers {
+		row := tl.findCell(t.Source.Block, t.Source.Signal).row
+		for _, target := range t.Targets {
+			span, ok := spans[target.Block]
+			if !ok {
+				continue
+			}
+			switch target.Hook {
+			case "START":
+				span.start = min(span.start, row)
+			case "FADE_OUT", "END":
+				span.end = min(span.end, row)
+			}
+			spans[target.Block] = span
+		}
+	}
+	return spans
+}
+
+// activeAt returns, in show order, the blocks that are running just before
+// the events on row fire.
+func (tl *Timeline) activeAt(row int) []string {
+	spans := tl.blockSpans()
+	var active []string
+	for _, b := range tl.show.Blocks {
+		if span, ok := spans[b.ID]; ok && span.start < row && row <= span.end {
+			active = append(active, b.ID)
+		}
+	}
+	return active
+}
+
+// ResetOp is one step of a reset. "stop" fades a running block out over Fade,
+// "start" starts a block's content from the top without its START actions,
+// "load"
+// prepares a block that starts on the reset row and "playhead" moves the
+// playhead to a cue block.
+type ResetOp struct {
+	Op    string
+	Block string
+	Fade  time.Duration
+}
+
+func (op ResetOp) MarshalJSON() ([]byte, error) {
+	out := struct {
+		Op    string `json:"op"`
+		Block string `json:"block,omitempty"`
+		Fade  string `json:"fade,omitempty"`
+	}{Op: op.Op, Block: op.Block}
+	if op.Op == "stop" {
+		out.Fade = op.Fade.String()
+	}
+	return json.Marshal(out)
+}
+
+func (op ResetOp) String() string {
+	switch {
+	case op.Block == "":
+		return op.Op
+	case op.Op == "stop":
+		return fmt.Sprintf("stop %s over %s", op.Block, op.Fade)
+	default:
+		return fmt.Sprintf("%s %s", op.Op, op.Block)
+	}
+}
+
+// ResetPlan computes the fewest operations that take the show from the
+// blocks in running to the state just before row fires, with the playhead
+// on the next cue. Only the show's own blocks are stopped, even when nothing
+// should be left running.
+//
+// Blocks that should be mid-run restart from the top: timeline rows order
+// events but do not time them, so there is no position to load a block at.
+func ResetPlan(tl Timeline, row int, running map[string]bool, fade time.Duration) ([]ResetOp, error) {
+	if row < 0 || row >= tl.rows() {
+		return nil, fmt.Errorf("row %d out of range", row)
+	}
+	active := tl.activeAt(row)
+
+	var plan []ResetOp
+	for _, b := range tl.show.Blocks {
+		if running[b.ID] && !slices.Contains(active, b.ID) {
+			plan = append(plan, ResetOp{Op: "stop", Block: b.ID, Fade: fade})
+		}
+	}
+	for _, id := range active {
+		if !running[id] {
+			plan = append(plan, ResetOp{Op: "start", Block: id})
+		}
+	}
+
+	spans := tl.blockSpans()
+	for _, b := range tl.show.Blocks {
+		if span, ok := spans[b.ID]; ok && span.start == row {
+			plan = append(plan, ResetOp{Op: "load", Block: b.ID})
+		}
+	}
+	for _, b := range tl.show.Blocks {
+		if b.Type == "cue" && tl.findCell(b.ID, "GO").row >= row {
+			plan = append(plan, ResetOp{Op: "playhead", Block: b.ID})
+			break
+		}
+	}
+	return plan, nil
+}
+
+// blockCues are the live cues a block compiled to: its Group, within it the
+// cue carrying its content, and the Fade cues elsewhere that fade it out.
+type blockCues struct {
+	group   string
+	content string
+	fades   []string
+}
+
+// running reports whether the block is playing. Its Group keeps running
+// while END actions follow the content, so the content decides.
+func (bc blockCues) running(isRunning map[string]bool) bool {
+	if bc.content == "" {
+		return isRunning[bc.group]
+	}
+	return isRunning[bc.content]
+}
+
+// liveShow locates a show's blocks in the show cue list.
+type liveShow struct {
+	list   qlab.Cue
+	blocks map[string]blockCues
+}
+
+func locateShow(ctx context.Context, ws *qlab.WorkspaceHandle, show *Show) (*liveShow, error) {
+	want, err := Compile(show)
+	if err != nil {
+		return nil, err
+	}
+	list, err := showList(ctx, ws)
+	if err != nil {
+		return nil, err
+	}
+	live, err := readLive(ctx, ws, list.Cues)
+	if err != nil {
+		return nil, err
+	}
+	d := matchCues(want, live)
+
+	compiled := map[string]*CompiledCue{}
+	var walk func(cues []*CompiledCue)
+	walk = func(cues []*CompiledCue) {
+		for _, cue := range cues {
+			compiled[cue.Key] = cue
+			walk(cue.Children)
+		}
+	}
+	walk(want)
+
+	blocks := map[string]blockCues{}
+	for _, b := range show.Blocks {
+		group := compiled[blockKey(b.ID)]
+		lc := d.byKey[group.Key]
+		if lc == nil {
+			continue
+		}
+		bc := blockCues{group: lc.UniqueID}
+		for _, child := range group.Children {
+			if child.Target == "" {
+				if content := d.byKey[child.Key]; content != nil {
+					bc.content = content.UniqueID
+				}
+				break
+			}
+		}
+		blocks[b.ID] = bc
+	}
+	blockOf := map[string]string{}
+	for _, b := range show.Blocks {
+		blockOf[blockKey(b.ID)] = b.ID
+	}
+	for _, cue := range compiled {
+		id, ok := blockOf[cue.Target]
+		if lc := d.byKey[cue.Key]; cue.Type == "fade" && ok && lc != nil {
+			bc := blocks[id]
+			bc.fades = append(bc.fades, lc.UniqueID)
+			blocks[id] = bc
+		}
+	}
+	return &liveShow{list: list, blocks: blocks}, nil
+}
+
+// resetToRow drives Qlab to the state just before row fires: it stops the
+// blocks that should not be running, starts those that should, and moves
+// the playhead. It returns what it did.
+func resetToRow(ctx context.Context, ws *qlab.WorkspaceHandle, ls *liveShow, tl Timeline, row int, fade time.Duration) ([]ResetOp, error) {
+	if row < 0 || row >= tl.rows() {
+		return nil, fmt.Errorf("row %d out of range", row)
+	}
+	blocks := ls.blocks
+	runningCues, err := ws.RunningCues(ctx)
+	if err != nil {
+		return nil, err
+	}
+	isRunning := map[string]bool{}
+	for _, cue := range runningCues {
+		isRunning[cue.UniqueID] = true
+	}
+	running := map[string]bool{}
+	for id, bc := range blocks {
+		if bc.running(isRunning) {
+			running[id] = true
+		}
+	}
+
+	plan, err := ResetPlan(tl, row, running, fade)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range plan {
+		bc, ok := blocks[op.Block]
+		if op.Block != "" && !ok || (op.Op == "start" || op.Op == "load") && bc.content == "" {
+			return nil, fmt.Errorf("%s: block is not in Qlab", op)
+		}
+		switch op.Op {
+		case "stop":
+			id := bc.content
+			if isRunning[bc.group] {
+				id = bc.group
+			}
+			if op.Fade > 0 {
+				err = ws.CuePanicInTime(ctx, id, op.Fade)
+			} else {
+				err = ws.CueStop(ctx, id)
+			}
+		case "start":
+			err = ws.CueStart(ctx, bc.content)
+		case "load":
+			err = ws.CueLoad(ctx, bc.content)
+		case "playhead":
+			err = qlab.SetCueProperty(ctx, ws.Client(), ws.ID, ls.list.UniqueID, qlab.PropPlaybackPositionID, bc.group)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return plan, nil
+}
+
+// reset runs resetToRow as an audited command on behalf of from.
+func (s *server) reset(ctx context.Context, from string, row int, fade time.Duration) ([]ResetOp, error) {
+	var plan []ResetOp
+	_, err := s.runCommand(ctx, from, fmt.Sprintf("reset to row %d", row), "", func(ctx context.Context, ws *qlab.WorkspaceHandle) error {
+		show, timeline := s.state.get()
+		ls, err := s.locate(ctx, ws, show)
+		if err != nil {
+			return err
+		}
+		plan, err = resetToRow(ctx, ws, ls, timeline, row, fade)
+		return err
+	})
+	return plan, err
+}
+
+// handleReset resets to {"row": n}, fading out over "fade" (a Go duration
+// such as "500ms") or the proxy's default. It responds with the plan run.
+func (s *server) handleReset(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Row  *int   `json:"row"`
+		Fade string `json:"fade"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Row == nil {
+		http.Error(w, "row is required", http.StatusBadRequest)
+		return
+	}
+	fade := s.resetFade
+	if req.Fade != "" {
+		var err error
+		if fade, err = time.ParseDuration(req.Fade); err != nil || fade < 0 {
+			http.Error(w, fmt.Sprintf("bad fade %q", req.Fade), http.StatusBadRequest)
+			return
+		}
+	}
+
+	_, timeline := s.state.get()
+	if *req.Row < 0 || *req.Row >= timeline.rows() {
+		http.Error(w, fmt.Sprintf("row %d out of range", *req.Row), http.StatusBadRequest)
+		return
+	}
+	plan, err := s.reset(r.Context(), r.RemoteAddr, *req.Row, fade)
+	if err != nil {
+		commandError(w, err)
+		return
+	}
+	if plan == nil {
+		plan = []ResetOp{}
+	}
+	writeJSON(w, plan)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smallShow lays out as:
+//
+//	r0  q1 GO     wash START  hold START
+//	r1            wash        hold
+//	r2            ...         hold FADE_OUT
+//	r3            wash FADE_OUT  hold END
+//	r4            wash END    (chain)
+//	r5                        loop START
+//	r6                        loop
+//	r7                        loop FADE_OUT
+//	r8  q2 GO                 loop END
+func TestActiveAt(t *testing.T) {
+	tl, err := BuildTimeline(smallShow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		row  int
+		want []string
+	}{
+		{0, nil},
+		{1, []string{"wash", "hold"}},
+		{3, []string{"wash", "hold"}},
+		{4, []string{"loop"}},
+		{6, []string{"loop"}},
+		{8, []string{"loop"}},
+	}
+	for _, tt := range tests {
+		if got := tl.activeAt(tt.row); !slices.Equal(got, tt.want) {
+			t.Errorf("activeAt(%d) = %q, want %q", tt.row, got, tt.want)
+		}
+	}
+}
+
+func TestResetPlan(t *testing.T) {
+	tl, err := BuildTimeline(smallShow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		row     int
+		running []string
+		want    []string
+	}{
+		{"top", 0, nil, []string{"load wash", "load hold", "playhead q1"}},
+		{"top while running", 0, []string{"loop"}, []string{"stop loop over 2s", "load wash", "load hold", "playhead q1"}},
+		{"mid block", 2, nil, []string{"start wash", "start hold", "playhead q2"}},
+		// hold is a delay; it waits its full time again.
+		{"mid delay", 2, []string{"wash"}, []string{"start hold", "playhead q2"}},
+		{"already there", 3, []string{"wash", "hold"}, []string{"load loop", "playhead q2"}},
+		{"across a chain", 4, []string{"wash", "hold"}, []string{"stop wash over 2s", "stop hold over 2s", "start loop", "playhead q2"}},
+		{"last row", 8, []string{"loop"}, []string{"playhead q2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			running := map[string]bool{}
+			for _, id := range tt.running {
+				running[id] = true
+			}
+			plan, err := ResetPlan(tl, tt.row, running, 2*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, op := range plan {
+				got = append(got, op.String())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ResetPlan(tl, 9, nil, 0); err == nil {
+		t.Error("expected an error for a row past the end")
+	}
+}
+
+func TestResetAPI(t *testing.T) {
+	mock, srv, ids := setupDriftTest(t)
+	srv.resetFade = time.Second
+	for _, key := range []string{"block:wash/0", "block:loop/0"} {
+		mock.SetCueProperty(ids[key], "infiniteLoop", true)
+	}
+	mock.SetCueProperty(ids["block:hold/0"], "duration", 10.0)
+
+	post := func(body string) int {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		srv.handleReset(rec, httptest.NewRequest("POST", "/api/reset", strings.NewReader(body)))
+		return rec.Code
+	}
+	running := func() []string {
+		var keys []string
+		for _, id := range mock.RunningCueIDs("ws-1") {
+			for key, cueID := range ids {
+				if cueID == id {
+					keys = append(keys, key)
+				}
+			}
+		}
+		slices.Sort(keys)
+		return keys
+	}
+
+	if code := post(`{"row":4}`); code != http.StatusOK {
+		t.Fatalf("got status %d", code)
+	}
+	if got, want := running(), []string{"block:loop/0"}; !slices.Equal(got, want) {
+		t.Errorf("running %q, want %q", got, want)
+	}
+	if got := mock.Playhead("ws-1"); got != ids["block:q2"] {
+		t.Errorf("playhead on %q, want q2", got)
+	}
+
+	if code := post(`{"row":2}`); code != http.StatusOK {
+		t.Fatalf("got status %d", code)
+	}
+	if got, want := running(), []string{"block:hold/0", "block:loop/0", "block:wash/0"}; !slices.Equal(got, want) {
+		t.Errorf("running %q, want %q while loop fades", got, want)
+	}
+	mock.Advance(time.Second)
+	if got, want := running(), []string{"block:hold/0", "block:wash/0"}; !slices.Equal(got, want) {
+		t.Errorf("running %q, want %q", got, want)
+	}
+
+	if code := post(`{"row":0,"fade":"0s"}`); code != http.StatusOK {
+		t.Fatalf("got status %d", code)
+	}
+	if got := running(); len(got) != 0 {
+		t.Errorf("running %q after reset to the top", got)
+	}
+	if got := mock.Playhead("ws-1"); got != ids["block:q1"] {
+		t.Errorf("playhead on %q, want q1", got)
+	}
+
+	for _, body := range []string{`{}`, `{"row":99}`, `{"row":1,"fade":"soon"}`} {
+		if code := post(body); code != http.StatusBadRequest {
+			t.Errorf("%s: got status %d, want 400", body, code)
+		}
+	}
+}
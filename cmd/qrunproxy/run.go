@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"qrun/lib/qlab"
+)
+
+// runPollInterval is how often the tracker re-reads Qlab while blocks are
+// running, so their timers advance between updates.
+const runPollInterval = 250 * time.Millisecond
+
+// RunState is what Qlab is running, mapped onto the timeline. Row is the
+// current row, the last one whose events have fired, and is always set.
+// Playhead is the cue block the next GO fires. Times are in seconds as of
+// Updated, so clients can keep timers running between states.
+type RunState struct {
+	Connected bool       `json:"connected"`
+	Row       int        `json:"row"`
+	Playhead  string     `json:"playhead,omitempty"`
+	Blocks    []BlockRun `json:"blocks"`
+	Updated   time.Time  `json:"updated"`
+}
+
+// BlockRun is a running block. Row is the row of the cell it is in: its
+// title, or its FADE_OUT while a Fade cue is taking it out. Remaining is
+// omitted for blocks that run until something stops them.
+type BlockRun struct {
+	Block     string   `json:"block"`
+	Row       int      `json:"row"`
+	Fading    bool     `json:"fading,omitempty"`
+	Elapsed   float64  `json:"elapsed"`
+	Remaining *float64 `json:"remaining,omitempty"`
+}
+
+// currentRow is the last row whose events have fired, given the cue block
+// at the playhead ("" past the last cue) and the running blocks: the GO of
+// the cue before the playhead, or a later row on which a running block
+// started.
+func (tl *Timeline) currentRow(playhead string, running []string) int {
+	row := 0
+	next := tl.rows()
+	if b := tl.Blocks[playhead]; b != nil && b.Type == "cue" {
+		next = tl.findCell(b.ID, "GO").row
+	}
+	for _, b := range tl.show.Blocks {
+		if b.Type != "cue" {
+			continue
+		}
+		if goRow := tl.findCell(b.ID, "GO").row; goRow < next {
+			row = max(row, goRow)
+		}
+	}
+	spans := tl.blockSpans()
+	for _, id := range running {
+		if span, ok := spans[id]; ok && span.start != math.MaxInt {
+			row = max(row, span.start)
+		}
+	}
+	return row
+}
+
+// titleRow is the row of a block's title cell.
+func (tl *Timeline) titleRow(blockID string) int {
+	b := tl.Blocks[blockID]
+	for _, c := range tl.trackIdx[b.Track].Cells {
+		if c.Type == CellTitle && c.BlockID == blockID {
+			return c.row
+		}
+	}
+	return tl.findCell(blockID, "START").row
+}
+
+// runTracker follows what Qlab is running and keeps the RunState current.
+type runTracker struct {
+	link  *qlabLink
+	state *showState
+
+	mu       sync.Mutex
+	current  RunState
+	location cachedLocation
+	// generation counts invalidations, so a location computed across one
+	// is not cached.
+	generation uint64
+	watchers   []func(RunState)
+}
+
+// cachedLocation is a located show along with the show and workspace it
+// was located for.
+type cachedLocation struct {
+	ls   *liveShow
+	show *Show
+	ws   *qlab.WorkspaceHandle
+}
+
+func newRunTracker(link *qlabLink, state *showState) *runTracker {
+	t := &runTracker{
+		link:    link,
+		state:   state,
+		current: RunState{Blocks: []BlockRun{}},
+	}
+	state.watch(func(*Show, Timeline) { t.invalidate() })
+	return t
+}
+
+func (t *runTracker) get() RunState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.current
+}
+
+// watch calls f when the run state changes in a way a client would show:
+// the connection, row, playhead, or which blocks are running or fading.
+// Timer changes alone do not count.
+func (t *runTracker) watch(f func(RunState)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.watchers = append(t.watchers, f)
+}
+
+// sameAs reports whether two states differ only in their timers.
+func (rs RunState) sameAs(o RunState) bool {
+	if rs.Connected != o.Connected || rs.Row != o.Row || rs.Playhead != o.Playhead || len(rs.Blocks) != len(o.Blocks) {
+		return false
+	}
+	for i, b := range rs.Blocks {
+		if ob := o.Blocks[i]; b.Block != ob.Block || b.Row != ob.Row || b.Fading != ob.Fading {
+			return false
+		}
+	}
+	return true
+}
+
+// invalidate makes the next refresh locate the show's cues again.
+func (t *runTracker) invalidate() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.location = cachedLocation{}
+	t.generation++
+}
+
+// run follows Qlab until ctx is done. It refreshes on every update, and on
+// a timer while blocks are running or Qlab is unreachable.
+func (t *runTracker) run(ctx context.Context) {
+	sub := t.link.client.Subscribe(64)
+	defer sub.Close()
+	ticker := time.NewTicker(runPollInterval)
+	defer ticker.Stop()
+
+	t.refresh(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case u, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			t.note(u)
+			// Qlab sends updates in bursts; one refresh covers them.
+		drain:
+			for {
+				select {
+				case u, ok := <-sub.C:
+					if !ok {
+						return
+					}
+					t.note(u)
+				default:
+					break drain
+				}
+			}
+			t.refresh(ctx)
+		case <-ticker.C:
+			if rs := t.get(); !rs.Connected || len(rs.Blocks) > 0 {
+				t.refresh(ctx)
+			}
+		}
+	}
+}
+
+// note invalidates the located cues on updates that may have moved them.
+func (t *runTracker) note(u qlab.Update) {
+	switch u.Kind {
+	case qlab.UpdateWorkspace, qlab.UpdateCueList, qlab.UpdateOverflow, qlab.UpdateDisconnect:
+		t.invalidate()
+	}
+}
+
+// refresh reads Qlab's running state. If Qlab cannot be read the state is
+// marked disconnected and keeps its row.
+func (t *runTracker) refresh(ctx context.Context) {
+	rs, err := t.read(ctx)
+	t.mu.Lock()
+	if err != nil {
+		rs = RunState{Row: t.current.Row, Blocks: []BlockRun{}, Updated: time.Now()}
+	}
+	changed := !rs.sameAs(t.current) || t.current.Updated.IsZero()
+	t.current = rs
+	watchers := t.watchers
+	t.mu.Unlock()
+	if changed {
+		for _, f := range watchers {
+			f(rs)
+		}
+	}
+}
+
+func (t *runTracker) locate(ctx context.Context, ws *qlab.WorkspaceHandle, show *Show) (*liveShow, error) {
+	t.mu.Lock()
+	cached, generation := t.location, t.generation
+	t.mu.Unlock()
+	if cached.ls != nil && cached.show == show && cached.ws == ws {
+		return cached.ls, nil
+	}
+	ls, err := locateShow(ctx, ws, show)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	if t.generation == generation {
+		t.location = cachedLocation{ls: ls, show: show, ws: ws}
+	}
+	t.mu.Unlock()
+	return ls, nil
+}
+
+func (t *runTracker) read(ctx context.Context) (RunState, error) {
+	ws, err := t.link.workspace()
+	if err != nil {
+		return RunState{}, err
+	}
+	show, tl := t.state.get()
+	ls, err := t.locate(ctx, ws, show)
+	if err != nil {
+		return RunState{}, err
+	}
+	c := ws.Client()
+
+	running, err := ws.RunningCues(ctx)
+	if err != nil {
+		return RunState{}, err
+	}
+	isRunning := map[string]bool{}
+	for _, cue := range running {
+		isRunning[cue.UniqueID] = true
+	}
+	head, err := qlab.GetCueProperty(ctx, c, ws.ID, ls.list.UniqueID, qlab.PropPlaybackPositionID)
+	if err != nil {
+		return RunState{}, err
+	}
+
+	rs := RunState{Connected: true, Blocks: []BlockRun{}}
+	var runningIDs []string
+	for _, b := range show.Blocks {
+		bc, ok := ls.blocks[b.ID]
+		if !ok {
+			continue
+		}
+		if b.Type == "cue" {
+			if bc.group == head {
+				rs.Playhead = b.ID
+			}
+			continue
+		}
+		if !bc.running(isRunning) {
+			continue
+		}
+		runningIDs = append(runningIDs, b.ID)
+		br := BlockRun{Block: b.ID, Row: tl.titleRow(b.ID)}
+		for _, id := range bc.fades {
+			if isRunning[id] {
+				br.Fading = true
+				br.Row = tl.findCell(b.ID, "FADE_OUT").row
+			}
+		}
+		if bc.content != "" {
+			elapsed, err := qlab.GetCueProperty(ctx, c, ws.ID, bc.content, qlab.PropActionElapsed)
+			if err != nil {
+				return RunState{}, err
+			}
+			br.Elapsed = elapsed.Seconds()
+			if b.hasDefinedTiming() {
+				duration, err := qlab.GetCueProperty(ctx, c, ws.ID, bc.content, qlab.PropDuration)
+				if err != nil {
+					return RunState{}, err
+				}
+				if duration > 0 {
+					remaining := max(duration-elapsed, 0).Seconds()
+					br.Remaining = &remaining
+				}
+			}
+		}
+		rs.Blocks = append(rs.Blocks, br)
+	}
+	rs.Row = tl.currentRow(rs.Playhead, runningIDs)
+	rs.Updated = time.Now()
+	return rs, nil
+}
+
+func (s *server) handleRun(w http.ResponseWriter, r *http.Request) {
+	if s.run == nil {
+		writeJSON(w, RunState{Blocks: []BlockRun{}})
+		return
+	}
+	writeJSON(w, s.run.get())
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"qrun/lib/qlab"
+)
+
+func TestCurrentRow(t *testing.T) {
+	tl, err := BuildTimeline(smallShow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		playhead string
+		running  []string
+		want     int
+	}{
+		{"q1", nil, 0},
+		{"q2", []string{"wash", "hold"}, 0},
+		{"q2", []string{"wash", "loop"}, 3},
+		{"q2", nil, 0},
+		{"", nil, 8},
+	}
+	for _, tt := range tests {
+		if got := tl.currentRow(tt.playhead, tt.running); got != tt.want {
+			t.Errorf("currentRow(%q, %q) = %d, want %d", tt.playhead, tt.running, got, tt.want)
+		}
+	}
+}
+
+func blockRuns(rs RunState) []string {
+	var out []string
+	for _, br := range rs.Blocks {
+		s := br.Block
+		if br.Fading {
+			s += " fading"
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func TestRunTracker(t *testing.T) {
+	mock, srv, ids := setupDriftTest(t)
+	for _, key := range []string{"block:wash/0", "block:loop/0"} {
+		mock.SetCueProperty(ids[key], "infiniteLoop", true)
+	}
+	mock.SetCueProperty(ids["block:hold/0"], "duration", 10.0)
+	mock.SetCueProperty(ids["block:hold/1"], "duration", 2.0)
+	tracker := newRunTracker(srv.link, srv.state)
+
+	ws, _ := srv.link.workspace()
+	if err := qlab.SetCueProperty(t.Context(), ws.Client(), ws.ID, "list-1", qlab.PropPlaybackPositionID, ids["block:q1"]); err != nil {
+		t.Fatal(err)
+	}
+	tracker.refresh(t.Context())
+	if rs := tracker.get(); !rs.Connected || rs.Playhead != "q1" || rs.Row != 0 || len(rs.Blocks) != 0 {
+		t.Errorf("before GO: got %+v", rs)
+	}
+
+	if err := ws.Go(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	// The refresh's requests queue behind the GO, so it has run by the time
+	// they are answered.
+	tracker.refresh(t.Context())
+	mock.Advance(4 * time.Second)
+	tracker.refresh(t.Context())
+	rs := tracker.get()
+	if got, want := blockRuns(rs), []string{"wash", "hold"}; !slices.Equal(got, want) || rs.Playhead != "q2" || rs.Row != 0 {
+		t.Fatalf("after GO: got %v, want %v; %+v", got, want, rs)
+	}
+	hold := rs.Blocks[1]
+	if hold.Row != 1 || hold.Elapsed != 4 || hold.Remaining == nil || *hold.Remaining != 6 {
+		t.Errorf("hold: got %+v", hold)
+	}
+	if rs.Blocks[0].Remaining != nil {
+		t.Errorf("wash runs until faded but has %v remaining", *rs.Blocks[0].Remaining)
+	}
+
+	mock.Advance(6 * time.Second)
+	tracker.refresh(t.Context())
+	rs = tracker.get()
+	if got, want := blockRuns(rs), []string{"wash fading", "loop"}; !slices.Equal(got, want) || rs.Row != 3 {
+		t.Fatalf("after hold: got %v row %d, want %v row 3", got, rs.Row, want)
+	}
+	if rs.Blocks[0].Row != 3 {
+		t.Errorf("fading wash on row %d, want its FADE_OUT on 3", rs.Blocks[0].Row)
+	}
+
+	mock.Advance(2 * time.Second)
+	tracker.refresh(t.Context())
+	if got, want := blockRuns(tracker.get()), []string{"loop"}; !slices.Equal(got, want) {
+		t.Errorf("after fade: got %v, want %v", got, want)
+	}
+}
+
+func TestRunAPI(t *testing.T) {
+	mock, srv, ids := setupDriftTest(t)
+	mock.SetCueProperty(ids["block:wash/0"], "infiniteLoop", true)
+	mock.SetCueProperty(ids["block:hold/0"], "duration", 10.0)
+	srv.run = newRunTracker(srv.link, srv.state)
+	go srv.run.run(t.Context())
+
+	ws, _ := srv.link.workspace()
+	if err := ws.CueStart(t.Context(), ids["block:q1"]); err != nil {
+		t.Fatal(err)
+	}
+
+	// Updates from the GO drive the tracker; nothing here refreshes it.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		rec := httptest.NewRecorder()
+		srv.handleRun(rec, httptest.NewRequest("GET", "/api/run", nil))
+		var rs RunState
+		if err := json.Unmarshal(rec.Body.Bytes(), &rs); err != nil {
+			t.Fatal(err)
+		}
+		if slices.Equal(blockRuns(rs), []string{"wash", "hold"}) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %+v, want wash and hold running", rs)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRunTrackerLocate(t *testing.T) {
+	_, srv, _ := setupDriftTest(t)
+	tracker := newRunTracker(srv.link, srv.state)
+	ws, _ := srv.link.workspace()
+	show, _ := srv.state.get()
+
+	locate := func(show *Show) *liveShow {
+		t.Helper()
+		ls, err := tracker.locate(t.Context(), ws, show)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ls
+	}
+	first := locate(show)
+	if locate(show) != first {
+		t.Error("same show was located again")
+	}
+	other := show.clone()
+	edited := locate(other)
+	if edited == first {
+		t.Error("location was reused for another show")
+	}
+	tracker.invalidate()
+	if locate(other) == edited {
+		t.Error("location was reused after invalidate")
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"time"
+)
+
+// server is the REST interface the Qrun clients use.
+type server struct {
+	link      *qlabLink
+	state     *showState
+	run       *runTracker
+	events    *eventFeed
+	audit     *auditLog
+	resetFade time.Duration
+}
+
+func (s *server) handler(static fs.FS) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.FS(static)))
+	mux.HandleFunc("GET /api/show", s.handleGetShow)
+	mux.HandleFunc("GET /api/show/diagnostics", s.handleDiagnostics)
+	mux.HandleFunc("GET /api/show/schema", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/schema+json")
+		io.WriteString(w, showSchema)
+	})
+	s.editHandlers(mux)
+	mux.HandleFunc("GET /api/history", s.handleHistory)
+	mux.HandleFunc("POST /api/undo", s.handleUndo)
+	mux.HandleFunc("POST /api/redo", s.handleRedo)
+	mux.HandleFunc("/api/timeline", func(w http.ResponseWriter, r *http.Request) {
+		_, timeline := s.state.get()
+		writeJSON(w, timeline)
+	})
+	mux.HandleFunc("/api/qlab", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, s.link.status())
+	})
+	mux.HandleFunc("GET /api/qlab/workspaces", s.link.handleWorkspaces)
+	mux.HandleFunc("POST /api/qlab/workspace", s.link.handleSelectWorkspace)
+	mux.HandleFunc("GET /api/qlab/diff", s.handleDiff)
+	mux.HandleFunc("POST /api/qlab/apply", s.handleApply)
+	mux.HandleFunc("POST /api/reset", s.handleReset)
+	s.transportHandlers(mux)
+	mux.HandleFunc("GET /api/run", s.handleRun)
+	mux.HandleFunc("GET /api/events", s.handleEvents)
+	return mux
+}
@@ -3,9 +3,14 @@ package main
 import "fmt"
 
 type Show struct {
+	Title    string     `json:"title,omitempty"`
 	Tracks   []*Track   `json:"tracks"`
 	Blocks   []*Block   `json:"blocks"`
 	Triggers []*Trigger `json:"triggers"`
+
+	// Templates are blocks that other blocks derive from. They are not on
+	// the timeline.
+	Templates []*Block `json:"templates,omitempty"`
 }
 
 type Track struct {
@@ -37,6 +42,13 @@ func (t *Trigger) String() string {
 	return s
 }
 
+// diagnostic reports a problem with the trigger. Triggers are identified
+// by their source, which no two share.
+func (t *Trigger) diagnostic(severity, code, format string, args ...any) Diagnostic {
+	src := TriggerSource{Block: t.Source.Block, Signal: t.Source.Signal}
+	return Diagnostic{Severity: severity, Code: code, Block: t.Source.Block, Trigger: &src, Message: fmt.Sprintf(format, args...)}
+}
+
 type TriggerSource struct {
 	Block  string `json:"block"`
 	Signal string `json:"signal"`
@@ -51,6 +63,66 @@ type TriggerTarget struct {
 	block *Block
 }
 
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Diagnostic is a problem with a show, with the track, block and trigger it
+// concerns where there is one. Errors make the show invalid; warnings are
+// allowed but probably not meant, like a block that can never start.
+type Diagnostic struct {
+	Severity string         `json:"severity"`
+	Code     string         `json:"code"`
+	Message  string         `json:"message"`
+	Track    string         `json:"track,omitempty"`
+	Block    string         `json:"block,omitempty"`
+	Trigger  *TriggerSource `json:"trigger,omitempty"`
+}
+
+// ShowError is returned for an invalid show. It carries all of the show's
+// diagnostics, warnings included, not just the errors.
+type ShowError struct {
+	Message     string       `json:"error"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+func (e *ShowError) Error() string {
+	return e.Message
+}
+
+// clone copies the show so the copy can be edited without touching a show
+// that is being served.
+func (show *Show) clone() *Show {
+	c := &Show{
+		Title:    show.Title,
+		Tracks:   make([]*Track, 0, len(show.Tracks)),
+		Blocks:   make([]*Block, 0, len(show.Blocks)),
+		Triggers: make([]*Trigger, 0, len(show.Triggers)),
+	}
+	for _, t := range show.Tracks {
+		c.Tracks = append(c.Tracks, &Track{ID: t.ID, Name: t.Name})
+	}
+	for _, b := range show.Blocks {
+		c.Blocks = append(c.Blocks, &Block{ID: b.ID, Type: b.Type, Track: b.Track, Name: b.Name, Loop: b.Loop})
+	}
+	for _, t := range show.Triggers {
+		c.Triggers = append(c.Triggers, t.clone())
+	}
+	for _, b := range show.Templates {
+		c.Templates = append(c.Templates, &Block{ID: b.ID, Type: b.Type, Track: b.Track, Name: b.Name, Loop: b.Loop})
+	}
+	return c
+}
+
+func (t *Trigger) clone() *Trigger {
+	c := &Trigger{Source: TriggerSource{Block: t.Source.Block, Signal: t.Source.Signal}}
+	for _, target := range t.Targets {
+		c.Targets = append(c.Targets, TriggerTarget{Block: target.Block, Hook: target.Hook})
+	}
+	return c
+}
+
 func (block *Block) hasDefinedTiming() bool {
 	if block.Type == "cue" || block.Type == "delay" {
 		return true
@@ -73,15 +145,39 @@ func isValidEventForBlock(block *Block, event string) bool {
 	}
 }
 
+// Validate checks the show, returning a *ShowError if any of its
+// diagnostics is an error. Warnings alone leave the show valid.
 func (show *Show) Validate() error {
+	diags := show.Diagnose()
+	var errs []Diagnostic
+	for _, d := range diags {
+		if d.Severity == SeverityError {
+			errs = append(errs, d)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	msg := errs[0].Message
+	if len(errs) > 1 {
+		msg += fmt.Sprintf(" (and %d more errors)", len(errs)-1)
+	}
+	return &ShowError{Message: msg, Diagnostics: diags}
+}
+
+// Diagnose checks the show and returns every problem it finds, rather than
+// stopping at the first.
+func (show *Show) Diagnose() []Diagnostic {
 	if show == nil {
-		return fmt.Errorf("show is nil")
+		return []Diagnostic{{Severity: SeverityError, Code: "nil-show", Message: "show is nil"}}
 	}
+	diags := []Diagnostic{}
 
 	trackIDs := map[string]bool{}
 	for _, track := range show.Tracks {
 		if trackIDs[track.ID] {
-			return fmt.Errorf("duplicate track id %q", track.ID)
+			diags = append(diags, Diagnostic{Severity: SeverityError, Code: "duplicate-track", Track: track.ID, Message: fmt.Sprintf("duplicate track id %q", track.ID)})
+			continue
 		}
 		trackIDs[track.ID] = true
 	}
@@ -89,14 +185,15 @@ func (show *Show) Validate() error {
 	blocksByID := map[string]*Block{}
 	for _, block := range show.Blocks {
 		if blocksByID[block.ID] != nil {
-			return fmt.Errorf("duplicate block id %q", block.ID)
+			diags = append(diags, Diagnostic{Severity: SeverityError, Code: "duplicate-block", Block: block.ID, Message: fmt.Sprintf("duplicate block id %q", block.ID)})
+			continue
 		}
 		blocksByID[block.ID] = block
 		if block.Type == "cue" {
 			continue
 		}
 		if !trackIDs[block.Track] {
-			return fmt.Errorf("block %q uses unknown track %q", block.ID, block.Track)
+			diags = append(diags, Diagnostic{Severity: SeverityError, Code: "unknown-track", Block: block.ID, Track: block.Track, Message: fmt.Sprintf("block %q uses unknown track %q", block.ID, block.Track)})
 		}
 	}
 
@@ -118,41 +215,59 @@ func (show *Show) Validate() error {
 	for _, trigger := range show.Triggers {
 		sourceBlock := blocksByID[trigger.Source.Block]
 		if sourceBlock == nil {
-			return fmt.Errorf("trigger source block %q not found", trigger.Source.Block)
+			diags = append(diags, trigger.diagnostic(SeverityError, "unknown-source-block", "trigger source block %q not found", trigger.Source.Block))
 		}
 
 		targetedTracks := map[string]string{}
 		for _, target := range trigger.Targets {
 			targetBlock := blocksByID[target.Block]
+			if targetBlock == nil {
+				// The trigger is found by its source; the block is the one missing.
+				d := trigger.diagnostic(SeverityError, "unknown-target-block", "trigger target block %q not found", target.Block)
+				d.Block = target.Block
+				diags = append(diags, d)
+				continue
+			}
 			if prev, ok := targetedTracks[targetBlock.Track]; ok {
-				return fmt.Errorf("trigger conflict: %s targets multiple blocks on track %q (%q and %q)",
+				d := trigger.diagnostic(SeverityError, "track-conflict", "trigger conflict: %s targets multiple blocks on track %q (%q and %q)",
 					trigger, targetBlock.Track, prev, target.Block)
+				d.Track = targetBlock.Track
+				diags = append(diags, d)
+				continue
 			}
 			targetedTracks[targetBlock.Track] = target.Block
 		}
 
-		if t, ok := signalTargetedBy[blockEvent{trigger.Source.Block, trigger.Source.Signal}]; ok {
-			sameTrackSingle := len(trigger.Targets) == 1 && blocksByID[trigger.Targets[0].Block].Track == sourceBlock.Track
-			if !sameTrackSingle {
-				return fmt.Errorf("trigger conflict: %s vs %s", t, trigger)
+		if sourceBlock != nil {
+			if t, ok := signalTargetedBy[blockEvent{trigger.Source.Block, trigger.Source.Signal}]; ok {
+				// An unknown target has been reported already.
+				sameTrackSingle := false
+				if len(trigger.Targets) == 1 {
+					only := blocksByID[trigger.Targets[0].Block]
+					sameTrackSingle = only == nil || only.Track == sourceBlock.Track
+				}
+				if !sameTrackSingle {
+					diags = append(diags, trigger.diagnostic(SeverityError, "signal-conflict", "trigger conflict: %s vs %s", t, trigger))
+				}
+			}
+			if !isValidEventForBlock(sourceBlock, trigger.Source.Signal) {
+				diags = append(diags, trigger.diagnostic(SeverityError, "invalid-signal", "trigger source signal %q is invalid for block %q", trigger.Source.Signal, trigger.Source.Block))
 			}
-		}
-		if !isValidEventForBlock(sourceBlock, trigger.Source.Signal) {
-			return fmt.Errorf("trigger source signal %q is invalid for block %q", trigger.Source.Signal, trigger.Source.Block)
 		}
 		src := blockEvent{trigger.Source.Block, trigger.Source.Signal}
 		if sourceUsed[src] {
-			return fmt.Errorf("duplicate trigger source: block %q signal %q", trigger.Source.Block, trigger.Source.Signal)
+			diags = append(diags, trigger.diagnostic(SeverityError, "duplicate-source", "duplicate trigger source: block %q signal %q", trigger.Source.Block, trigger.Source.Signal))
 		}
 		sourceUsed[src] = true
 
 		for _, target := range trigger.Targets {
 			targetBlock := blocksByID[target.Block]
 			if targetBlock == nil {
-				return fmt.Errorf("trigger target block %q not found", target.Block)
+				continue
 			}
 			if !isValidEventForBlock(targetBlock, target.Hook) {
-				return fmt.Errorf("trigger target hook %q is invalid for block %q", target.Hook, target.Block)
+				diags = append(diags, trigger.diagnostic(SeverityError, "invalid-hook", "trigger target hook %q is invalid for block %q", target.Hook, target.Block))
+				continue
 			}
 			hookTargeted[blockEvent{target.Block, target.Hook}] = true
 			if target.Hook == "START" {
@@ -166,19 +281,25 @@ func (show *Show) Validate() error {
 			continue
 		}
 		if !startTargeted[block.ID] {
-			return fmt.Errorf("block %q has no trigger for its START", block.ID)
+			diags = append(diags, Diagnostic{Severity: SeverityError, Code: "no-start", Block: block.ID, Track: block.Track, Message: fmt.Sprintf("block %q has no trigger for its START", block.ID)})
 		}
 		if !block.hasDefinedTiming() && !hookTargeted[blockEvent{block.ID, "FADE_OUT"}] && !hookTargeted[blockEvent{block.ID, "END"}] {
-			return fmt.Errorf("block %q has no defined timing and nothing triggers its FADE_OUT or END", block.ID)
+			diags = append(diags, Diagnostic{Severity: SeverityWarning, Code: "runs-forever", Block: block.ID, Track: block.Track, Message: fmt.Sprintf("block %q has no defined timing and nothing triggers its FADE_OUT or END, so it runs forever", block.ID)})
 		}
 	}
 
 	for _, trigger := range show.Triggers {
 		sourceBlock := blocksByID[trigger.Source.Block]
+		if sourceBlock == nil {
+			continue
+		}
 		for _, target := range trigger.Targets {
 			targetBlock := blocksByID[target.Block]
+			if targetBlock == nil {
+				continue
+			}
 			if sourceBlock.Type != "cue" && targetBlock.Type != "cue" && sourceBlock.Track == targetBlock.Track && target.Hook == "START" && trigger.Source.Signal != "END" {
-				return fmt.Errorf("same-track START trigger from %q to %q must use END signal, not %s", sourceBlock.ID, targetBlock.ID, trigger.Source.Signal)
+				diags = append(diags, trigger.diagnostic(SeverityError, "same-track-start", "same-track START trigger from %q to %q must use END signal, not %s", sourceBlock.ID, targetBlock.ID, trigger.Source.Signal))
 			}
 		}
 		if sourceBlock.hasDefinedTiming() {
@@ -192,9 +313,39 @@ func (show *Show) Validate() error {
 			continue
 		}
 		if !hookTargeted[blockEvent{sourceBlock.ID, signal}] {
-			return fmt.Errorf("block %q has no defined timing and nothing triggers its %s, so its %s signal will never fire", sourceBlock.ID, signal, signal)
+			diags = append(diags, trigger.diagnostic(SeverityError, "signal-never-fires", "block %q has no defined timing and nothing triggers its %s, so its %s signal will never fire", sourceBlock.ID, signal, signal))
+		}
+	}
+
+	// Cues are started by the operator, and everything else by a block that
+	// is itself started. Blocks started only from a loop that no cue leads
+	// into never run.
+	reachable := map[string]bool{}
+	for _, block := range show.Blocks {
+		if block.Type == "cue" {
+			reachable[block.ID] = true
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, trigger := range show.Triggers {
+			if !reachable[trigger.Source.Block] {
+				continue
+			}
+			for _, target := range trigger.Targets {
+				if target.Hook == "START" && blocksByID[target.Block] != nil && !reachable[target.Block] {
+					reachable[target.Block] = true
+					changed = true
+				}
+			}
+		}
+	}
+	for _, block := range show.Blocks {
+		if reachable[block.ID] || !startTargeted[block.ID] {
+			continue
 		}
+		diags = append(diags, Diagnostic{Severity: SeverityWarning, Code: "unreachable", Block: block.ID, Track: block.Track, Message: fmt.Sprintf("block %q is only started by blocks that never start, so no cue reaches it", block.ID)})
 	}
 
-	return nil
+	return diags
 }
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDiagnose(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(*Show)
+		want []string
+	}{
+		{"valid", func(*Show) {}, nil},
+		{"every error", func(show *Show) {
+			show.Tracks = append(show.Tracks, &Track{ID: "t0", Name: "Lighting again"})
+			show.Blocks = append(show.Blocks, &Block{ID: "sting", Type: "media", Track: "t9", Name: "Sting"})
+			show.Triggers[0].Targets = append(show.Triggers[0].Targets, TriggerTarget{Block: "gone", Hook: "START"})
+			// loop's END is already a hook, and the only target doesn't exist.
+			show.Triggers = append(show.Triggers, &Trigger{Source: TriggerSource{Block: "loop", Signal: "END"}, Targets: []TriggerTarget{{Block: "gone2", Hook: "START"}}})
+		}, []string{
+			"error duplicate-track ",
+			"error unknown-track sting",
+			"error unknown-target-block gone",
+			"error unknown-target-block gone2",
+			"error no-start sting",
+		}},
+		{"runs forever", func(show *Show) {
+			show.Triggers = show.Triggers[:2]
+		}, []string{"warning runs-forever loop"}},
+		{"unreachable", func(show *Show) {
+			show.Tracks = append(show.Tracks, &Track{ID: "t2", Name: "Sound"})
+			show.Blocks = append(show.Blocks,
+				&Block{ID: "a", Type: "delay", Track: "t2", Name: "A"},
+				&Block{ID: "b", Type: "delay", Track: "t2", Name: "B"})
+			show.Triggers = append(show.Triggers,
+				&Trigger{Source: TriggerSource{Block: "a", Signal: "END"}, Targets: []TriggerTarget{{Block: "b", Hook: "START"}}},
+				&Trigger{Source: TriggerSource{Block: "b", Signal: "END"}, Targets: []TriggerTarget{{Block: "a", Hook: "START"}}})
+		}, []string{"warning unreachable a", "warning unreachable b"}},
+	}
+	for _, tt := range tests {
+		show := smallShow()
+		tt.edit(show)
+		diags := show.Diagnose()
+		var got []string
+		for _, d := range diags {
+			got = append(got, d.Severity+" "+d.Code+" "+d.Block)
+			if d.Code == "unknown-target-block" && (d.Trigger == nil || d.Trigger.Block == d.Block) {
+				t.Errorf("%s: %s names no trigger apart from its missing block", tt.name, d.Message)
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+
+		err := show.Validate()
+		wantInvalid := slices.ContainsFunc(tt.want, func(s string) bool { return strings.HasPrefix(s, SeverityError) })
+		var showErr *ShowError
+		switch {
+		case !wantInvalid && err != nil:
+			t.Errorf("%s: Validate() = %v, want nil for warnings alone", tt.name, err)
+		case wantInvalid && (!errors.As(err, &showErr) || len(showErr.Diagnostics) != len(diags)):
+			t.Errorf("%s: Validate() = %v, want a ShowError carrying all %d diagnostics", tt.name, err, len(diags))
+		}
+	}
+}
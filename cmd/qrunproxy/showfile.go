@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// showFileVersion is the version of the show file format this build
+// writes. Bump it and add a migration from the previous version whenever
+// the format changes in a way older files don't already satisfy.
+const showFileVersion = 1
+
+// showFile is a show as stored on disk: the show itself, with its format
+// version alongside.
+type showFile struct {
+	Version int `json:"version"`
+	*Show
+}
+
+// showMigration upgrades a decoded show file by one version, in place.
+type showMigration func(file map[string]json.RawMessage) error
+
+// showMigrations holds the migration from each version to the next.
+var showMigrations = map[int]showMigration{
+	0: migrateShowV0,
+}
+
+// migrateShowV0 upgrades files from before the format was versioned, which
+// named the show's title "show".
+func migrateShowV0(file map[string]json.RawMessage) error {
+	if title, ok := file["show"]; ok {
+		file["title"] = title
+		delete(file, "show")
+	}
+	return nil
+}
+
+// decodeShowFile reads a show file of any known version, migrating it to
+// the current one.
+func decodeShowFile(data []byte) (*Show, error) {
+	var file map[string]json.RawMessage
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	version := 0
+	if raw, ok := file["version"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return nil, fmt.Errorf("version: %w", err)
+		}
+	}
+	if version > showFileVersion {
+		return nil, fmt.Errorf("show file version %d is newer than this qrun, which reads up to %d", version, showFileVersion)
+	}
+	for ; version < showFileVersion; version++ {
+		migrate, ok := showMigrations[version]
+		if !ok {
+			return nil, fmt.Errorf("no migration from show file version %d", version)
+		}
+		if err := migrate(file); err != nil {
+			return nil, fmt.Errorf("migrating from version %d: %w", version, err)
+		}
+	}
+	file["version"] = json.RawMessage(fmt.Sprint(showFileVersion))
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return nil, err
+	}
+	sf := showFile{Show: &Show{}}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&sf); err != nil {
+		return nil, err
+	}
+	return sf.Show, nil
+}
+
+func loadShowFile(path string) (*Show, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	show, err := decodeShowFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return show, nil
+}
+
+// saveShowFile writes the show to path in the current format.
+func saveShowFile(path string, show *Show) error {
+	data, err := json.MarshalIndent(showFile{Version: showFileVersion, Show: fileShow(show)}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'))
+}
+
+// showSaver keeps the show file and its history up to date without holding
+// up edits: changes are written in the background, and those made while a
+// write is under way are saved together by the next one.
+type showSaver struct {
+	path  string
+	state *showState
+	kick  chan struct{}
+	done  chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// newShowSaver saves state to path after every change until closed.
+func newShowSaver(path string, state *showState) *showSaver {
+	sv := &showSaver{path: path, state: state, kick: make(chan struct{}, 1), done: make(chan struct{})}
+	go sv.run()
+	state.watch(func(*Show, Timeline) { sv.changed() })
+	return sv
+}
+
+func (sv *showSaver) changed() {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	if sv.closed {
+		return
+	}
+	select {
+	case sv.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (sv *showSaver) run() {
+	defer close(sv.done)
+	for range sv.kick {
+		show, hist := sv.state.snapshot()
+		if err := saveShowFile(sv.path, show); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving show: %v\n", err)
+		}
+		if err := saveHistoryFile(historyPath(sv.path), hist); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving show history: %v\n", err)
+		}
+	}
+}
+
+// Close stops saving once any pending change is written.
+func (sv *showSaver) Close() {
+	sv.mu.Lock()
+	if !sv.closed {
+		sv.closed = true
+		close(sv.kick)
+	}
+	sv.mu.Unlock()
+	<-sv.done
+}
+
+// fileShow is a copy of show as it is written to disk. The timeline puts
+// cue blocks on its cue track; that is not the file's business.
+func fileShow(show *Show) *Show {
+	show = show.clone()
+	for _, b := range show.Blocks {
+		if b.Type == "cue" {
+			b.Track = ""
+		}
+	}
+	return show
+}
+
+// writeFileAtomic writes a temporary file next to path and renames it
+// over, so a crash mid-write leaves the previous file intact.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// showSchema is a JSON Schema for show files, so they can be checked
+// without qrun. Keep it in step with showFile.
+const showSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "Qrun show",
+  "type": "object",
+  "required": ["version", "tracks", "blocks", "triggers"],
+  "additionalProperties": false,
+  "properties": {
+    "version": {"const": 1},
+    "title": {"type": "string"},
+    "tracks": {"type": "array", "items": {"$ref": "#/$defs/track"}},
+    "blocks": {"type": "array", "items": {"$ref": "#/$defs/block"}},
+    "triggers": {"type": "array", "items": {"$ref": "#/$defs/trigger"}},
+    "templates": {"type": "array", "items": {"$ref": "#/$defs/block"}}
+  },
+  "$defs": {
+    "track": {
+      "type": "object",
+      "required": ["id", "name"],
+      "additionalProperties": false,
+      "properties": {
+        "id": {"type": "string", "minLength": 1},
+        "name": {"type": "string"}
+      }
+    },
+    "block": {
+      "type": "object",
+      "required": ["id", "type", "name"],
+      "additionalProperties": false,
+      "properties": {
+        "id": {"type": "string", "minLength": 1},
+        "type": {"type": "string"},
+        "track": {"type": "string"},
+        "name": {"type": "string"},
+        "loop": {"type": "boolean"}
+      }
+    },
+    "trigger": {
+      "type": "object",
+      "required": ["source", "targets"],
+      "additionalProperties": false,
+      "properties": {
+        "source": {
+          "type": "object",
+          "required": ["block", "signal"],
+          "additionalProperties": false,
+          "properties": {
+            "block": {"type": "string"},
+            "signal": {"enum": ["GO", "START", "FADE_OUT", "END"]}
+          }
+        },
+        "targets": {
+          "type": "array",
+          "items": {
+            "type": "object",
+            "required": ["block", "hook"],
+            "additionalProperties": false,
+            "properties": {
+              "block": {"type": "string"},
+              "hook": {"enum": ["START", "FADE_OUT", "END"]}
+            }
+          }
+        }
+      }
+    }
+  }
+}
+`
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestShowFileRoundTrip(t *testing.T) {
+	show := smallShow()
+	show.Title = "Small"
+	show.Templates = []*Block{{ID: "wash-template", Type: "light", Name: "Wash"}}
+	// Building the timeline moves cue blocks onto the cue track.
+	if _, err := BuildTimeline(show); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "show.json")
+	if err := saveShowFile(path, show); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveShowFile(path, show); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("%d files in the directory after saving, want just the show", len(entries))
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), "{\n  \"version\": 1,") || strings.Contains(string(data), cueTrackID) {
+		t.Errorf("saved:\n%s", data)
+	}
+
+	got, err := loadShowFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := got.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	want := smallShow()
+	want.Title, want.Templates = show.Title, show.Templates
+	gotJSON, _ := json.Marshal(got)
+	wantJSON, _ := json.Marshal(want)
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("loaded\n%s\nwant\n%s", gotJSON, wantJSON)
+	}
+}
+
+func TestShowFileMigration(t *testing.T) {
+	// qrunweb's sample show predates versioning.
+	show, err := loadShowFile("../qrunweb/static/show.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if show.Title != "The Tempest" || len(show.Tracks) != 5 {
+		t.Errorf("got %q with %d tracks", show.Title, len(show.Tracks))
+	}
+
+	tests := []struct {
+		name, file, err string
+	}{
+		{"newer", `{"version": 2, "tracks": [], "blocks": [], "triggers": []}`, "newer than this qrun"},
+		{"unknown field", `{"version": 1, "tracks": [], "blocks": [], "triggers": [], "colour": "red"}`, "colour"},
+		{"v0 unknown field", `{"show": "x", "tracks": [{"id": "t", "nmae": "x"}]}`, "nmae"},
+	}
+	for _, tt := range tests {
+		if _, err := decodeShowFile([]byte(tt.file)); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got error %v, want one mentioning %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+// jsonFields lists the JSON names of a struct's exported fields, including
+// those of embedded structs.
+func jsonFields(typ reflect.Type) []string {
+	var out []string
+	for i := range typ.NumField() {
+		f := typ.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if f.Anonymous {
+			t := f.Type
+			if t.Kind() == reflect.Pointer {
+				t = t.Elem()
+			}
+			out = append(out, jsonFields(t)...)
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		out = append(out, name)
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestShowSchema(t *testing.T) {
+	type object struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	var schema struct {
+		object
+		Defs map[string]object `json:"$defs"`
+	}
+	if err := json.Unmarshal([]byte(showSchema), &schema); err != nil {
+		t.Fatal(err)
+	}
+	var trigger struct {
+		Properties struct {
+			Source  object `json:"source"`
+			Targets struct {
+				Items object `json:"items"`
+			} `json:"targets"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(schema.Defs["trigger"].Properties["source"], &trigger.Properties.Source); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(schema.Defs["trigger"].Properties["targets"], &trigger.Properties.Targets); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		obj  object
+		typ  reflect.Type
+	}{
+		{"file", schema.object, reflect.TypeFor[showFile]()},
+		{"track", schema.Defs["track"], reflect.TypeFor[Track]()},
+		{"block", schema.Defs["block"], reflect.TypeFor[Block]()},
+		{"trigger", schema.Defs["trigger"], reflect.TypeFor[Trigger]()},
+		{"source", trigger.Properties.Source, reflect.TypeFor[TriggerSource]()},
+		{"target", trigger.Properties.Targets.Items, reflect.TypeFor[TriggerTarget]()},
+	}
+	for _, tt := range tests {
+		if got, want := slices.Sorted(maps.Keys(tt.obj.Properties)), jsonFields(tt.typ); !slices.Equal(got, want) {
+			t.Errorf("%s: schema has %q, Go has %q", tt.name, got, want)
+		}
+	}
+
+	var version struct {
+		Const int `json:"const"`
+	}
+	json.Unmarshal(schema.Properties["version"], &version)
+	if version.Const != showFileVersion {
+		t.Errorf("schema is for version %d, files are version %d", version.Const, showFileVersion)
+	}
+}
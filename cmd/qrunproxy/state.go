@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"sync"
+)
+
+// errStaleRevision is returned by edit when the show has changed since the
+// revision the caller based its edit on.
+var errStaleRevision = errors.New("show has changed since it was read")
+
+// showState is the show the proxy is serving and the timeline built from it.
+// Both are replaced together and never modified in place, so a caller may
+// keep using what get returned after the state changes. Each change bumps
+// the revision, which editors use to detect concurrent edits, and is
+// recorded in the history so it can be undone.
+type showState struct {
+	setMu    sync.Mutex
+	mu       sync.RWMutex
+	show     *Show
+	timeline Timeline
+	rev      int
+	hist     *history
+	watchers []func(*Show, Timeline)
+}
+
+func newShowState(show *Show) (*showState, error) {
+	s := &showState{hist: newHistory()}
+	if err := s.setLocked(show, nil); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *showState) get() (*Show, Timeline) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.show, s.timeline
+}
+
+// revision returns the current show and its revision.
+func (s *showState) revision() (*Show, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.show, s.rev
+}
+
+// set validates show and makes it current, recording the change as op. On
+// error the state is unchanged.
+func (s *showState) set(op string, show *Show) error {
+	s.setMu.Lock()
+	defer s.setMu.Unlock()
+	before := s.show
+	return s.setLocked(show, func(h *history) { h.record(op, before, show) })
+}
+
+// edit applies f to a copy of the show at revision rev and makes the result
+// current if it is valid, recording the change as op. On error the state is
+// unchanged.
+func (s *showState) edit(op string, rev int, f func(*Show) error) error {
+	s.setMu.Lock()
+	defer s.setMu.Unlock()
+	if rev != s.rev {
+		return errStaleRevision
+	}
+	before := s.show
+	show := before.clone()
+	if err := f(show); err != nil {
+		return err
+	}
+	return s.setLocked(show, func(h *history) { h.record(op, before, show) })
+}
+
+// undo reverts the last change recorded in the history, if the show is
+// still at revision rev.
+func (s *showState) undo(rev int) error {
+	s.setMu.Lock()
+	defer s.setMu.Unlock()
+	if rev != s.rev {
+		return errStaleRevision
+	}
+	if s.hist.Pos == 0 {
+		return errNothingToUndo
+	}
+	return s.setLocked(s.hist.before(s.hist.Pos-1).clone(), func(h *history) { h.Pos-- })
+}
+
+// redo makes the last change undone again, if the show is still at
+// revision rev.
+func (s *showState) redo(rev int) error {
+	s.setMu.Lock()
+	defer s.setMu.Unlock()
+	if rev != s.rev {
+		return errStaleRevision
+	}
+	if s.hist.Pos == len(s.hist.Entries) {
+		return errNothingToRedo
+	}
+	return s.setLocked(s.hist.Entries[s.hist.Pos].Show.clone(), func(h *history) { h.Pos++ })
+}
+
+// setLocked makes show current if it is valid, applying change to the
+// history with it.
+func (s *showState) setLocked(show *Show, change func(*history)) error {
+	if err := show.Validate(); err != nil {
+		return err
+	}
+	timeline, err := BuildTimeline(show)
+	if err != nil {
+		return &ShowError{Message: err.Error(), Diagnostics: []Diagnostic{{Severity: SeverityError, Code: "layout", Message: err.Error()}}}
+	}
+	s.mu.Lock()
+	s.show = show
+	s.timeline = timeline
+	s.rev++
+	if change != nil {
+		change(s.hist)
+	}
+	watchers := s.watchers
+	s.mu.Unlock()
+	for _, f := range watchers {
+		f(show, timeline)
+	}
+	return nil
+}
+
+// snapshot returns the show and a copy of the history that ends at it.
+func (s *showState) snapshot() (*Show, *history) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.show, s.hist.clone()
+}
+
+func (s *showState) historyState() HistoryState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.hist.state()
+}
+
+// restoreHistory replaces the history with one loaded from disk.
+func (s *showState) restoreHistory(h *history) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hist = h
+}
+
+// watch calls f after every change.
+func (s *showState) watch(f func(*Show, Timeline)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.watchers = append(s.watchers, f)
+}
@@ -108,6 +108,16 @@ func (g exclusiveGroup) String() string {
 	return s + ")"
 }
 
+// rows is the number of rows in the timeline, the length of its longest
+// track.
+func (tl *Timeline) rows() int {
+	n := 0
+	for _, t := range tl.Tracks {
+		n = max(n, len(t.Cells))
+	}
+	return n
+}
+
 func (tl *Timeline) debugf(format string, args ...any) {
 	if tl.debugW != nil {
 		fmt.Fprintf(tl.debugW, format+"\n", args...)
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"qrun/lib/qlab"
+)
+
+const defaultAuditSize = 500
+
+// AuditEntry records a command an operator sent to Qlab through the proxy.
+type AuditEntry struct {
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+	Block   string    `json:"block,omitempty"`
+	From    string    `json:"from"`
+	Error   string    `json:"error,omitempty"`
+}
+
+func (e AuditEntry) String() string {
+	s := e.Time.Format("15:04:05.000") + " " + e.From + " " + e.Command
+	if e.Block != "" {
+		s += " " + e.Block
+	}
+	if e.Error != "" {
+		s += ": " + e.Error
+	}
+	return s
+}
+
+// auditLog keeps the most recent commands, newest last, and writes each to
+// out as it is recorded.
+type auditLog struct {
+	out io.Writer
+	max int
+
+	mu      sync.Mutex
+	entries []AuditEntry
+	events  *eventFeed
+}
+
+func newAuditLog(out io.Writer, events *eventFeed) *auditLog {
+	return &auditLog{out: out, max: defaultAuditSize, events: events}
+}
+
+func (a *auditLog) record(e AuditEntry) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	a.entries = append(a.entries, e)
+	if over := len(a.entries) - a.max; over > 0 {
+		a.entries = slices.Delete(a.entries, 0, over)
+	}
+	a.mu.Unlock()
+	if a.out != nil {
+		fmt.Fprintln(a.out, e)
+	}
+	if a.events != nil {
+		a.events.publish("audit", e)
+	}
+}
+
+func (a *auditLog) list() []AuditEntry {
+	if a == nil {
+		return []AuditEntry{}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return slices.Clone(a.entries)
+}
+
+// commandStatus is the HTTP status for a command that failed with err.
+func commandStatus(err error) int {
+	switch {
+	case errors.Is(err, errQlabDisabled), errors.Is(err, errNoWorkspace),
+		errors.Is(err, qlab.ErrDisconnected), errors.Is(err, qlab.ErrWorkspaceClosed),
+		errors.Is(err, qlab.ErrTimeout):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, qlab.ErrPermissionDenied):
+		return http.StatusForbidden
+	case errors.Is(err, errBlockNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+var errBlockNotFound = errors.New("block not found")
+
+// runCommand runs f against the show workspace on behalf of from and
+// records it in the audit log. It returns once Qlab has acted on whatever f
+// sent.
+func (s *server) runCommand(ctx context.Context, from, name, block string, f func(ctx context.Context, ws *qlab.WorkspaceHandle) error) (AuditEntry, error) {
+	e := AuditEntry{Time: time.Now(), Command: name, Block: block, From: from}
+	ws, err := s.link.workspace()
+	if err == nil && !ws.Connected() {
+		err = fmt.Errorf("workspace %s: %w", ws.ID, qlab.ErrDisconnected)
+	}
+	if err == nil {
+		err = f(ctx, ws)
+	}
+	// Commands get no reply. Qlab answers in order, so once a thump sent
+	// after them is answered they have run.
+	if err == nil {
+		err = ws.Thump(ctx)
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	s.audit.record(e)
+	return e, err
+}
+
+// commandError replies to a request whose command failed with err.
+func commandError(w http.ResponseWriter, err error) {
+	msg := err.Error()
+	if commandStatus(err) == http.StatusServiceUnavailable {
+		msg = "Qlab is unreachable: " + msg
+	}
+	http.Error(w, msg, commandStatus(err))
+}
+
+// command runs f for a transport request and replies with the audit entry.
+func (s *server) command(w http.ResponseWriter, r *http.Request, name, block string, f func(ctx context.Context, ws *qlab.WorkspaceHandle) error) {
+	e, err := s.runCommand(r.Context(), r.RemoteAddr, name, block, f)
+	if err != nil {
+		commandError(w, err)
+		return
+	}
+	writeJSON(w, e)
+}
+
+// locate finds show's cues, reusing the run tracker's location when there
+// is one.
+func (s *server) locate(ctx context.Context, ws *qlab.WorkspaceHandle, show *Show) (*liveShow, error) {
+	if s.run != nil {
+		return s.run.locate(ctx, ws, show)
+	}
+	return locateShow(ctx, ws, show)
+}
+
+// blockCommand runs f with the cues of the block named in the request path.
+func (s *server) blockCommand(w http.ResponseWriter, r *http.Request, name string, f func(ctx context.Context, ws *qlab.WorkspaceHandle, bc blockCues) error) {
+	id := r.PathValue("id")
+	s.command(w, r, name, id, func(ctx context.Context, ws *qlab.WorkspaceHandle) error {
+		show, _ := s.state.get()
+		ls, err := s.locate(ctx, ws, show)
+		if err != nil {
+			return err
+		}
+		bc, ok := ls.blocks[id]
+		if !ok {
+			if show.findBlock(id) == nil {
+				return fmt.Errorf("%w: %q", errBlockNotFound, id)
+			}
+			return fmt.Errorf("block %q is not in Qlab", id)
+		}
+		return f(ctx, ws, bc)
+	})
+}
+
+// startBlock fires a block: a cue block's Group runs its actions as a GO
+// would, and any other block's content starts without its START actions.
+func startBlock(ctx context.Context, ws *qlab.WorkspaceHandle, bc blockCues) error {
+	if bc.content == "" {
+		return ws.CueStart(ctx, bc.group)
+	}
+	return ws.CueStart(ctx, bc.content)
+}
+
+// stopBlock stops a block at once, its END actions included if they have
+// started.
+func stopBlock(ctx context.Context, ws *qlab.WorkspaceHandle, bc blockCues) error {
+	running, err := ws.RunningCues(ctx)
+	if err != nil {
+		return err
+	}
+	for _, cue := range running {
+		if cue.UniqueID == bc.group {
+			return ws.CueStop(ctx, bc.group)
+		}
+	}
+	if bc.content == "" {
+		return ws.CueStop(ctx, bc.group)
+	}
+	return ws.CueStop(ctx, bc.content)
+}
+
+func (s *server) transportHandlers(mux *http.ServeMux) {
+	workspaceCommands := []struct {
+		name string
+		f    func(*qlab.WorkspaceHandle, context.Context) error
+	}{
+		{"go", (*qlab.WorkspaceHandle).Go},
+		{"stop", (*qlab.WorkspaceHandle).Stop},
+		{"pause", (*qlab.WorkspaceHandle).Pause},
+		{"resume", (*qlab.WorkspaceHandle).Resume},
+		{"panic", (*qlab.WorkspaceHandle).Panic},
+	}
+	for _, c := range workspaceCommands {
+		mux.HandleFunc("POST /api/"+c.name, func(w http.ResponseWriter, r *http.Request) {
+			s.command(w, r, c.name, "", func(ctx context.Context, ws *qlab.WorkspaceHandle) error {
+				return c.f(ws, ctx)
+			})
+		})
+	}
+	mux.HandleFunc("POST /api/blocks/{id}/start", func(w http.ResponseWriter, r *http.Request) {
+		s.blockCommand(w, r, "start", startBlock)
+	})
+	mux.HandleFunc("POST /api/blocks/{id}/stop", func(w http.ResponseWriter, r *http.Request) {
+		s.blockCommand(w, r, "stop", stopBlock)
+	})
+	mux.HandleFunc("GET /api/audit", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, s.audit.list())
+	})
+}
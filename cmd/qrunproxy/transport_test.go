@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"qrun/lib/qlab"
+)
+
+func TestTransport(t *testing.T) {
+	mock, srv, ids := setupDriftTest(t)
+	mock.SetCueProperty(ids["block:wash/0"], "infiniteLoop", true)
+	srv.audit = newAuditLog(nil, nil)
+	ts := httptest.NewServer(srv.handler(fstest.MapFS{}))
+	t.Cleanup(ts.Close)
+
+	// sent posts a command and returns the addresses of the commands it sent
+	// Qlab, leaving out the reads.
+	sent := func(path string, wantStatus int) []string {
+		t.Helper()
+		mock.ResetRequests()
+		resp, err := http.Post(ts.URL+path, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != wantStatus {
+			t.Fatalf("%s: got status %d, want %d: %s", path, resp.StatusCode, wantStatus, body)
+		}
+		var out []string
+		for _, req := range mock.Requests() {
+			addr := strings.TrimPrefix(req.Address, "/workspace/ws-1/")
+			switch {
+			case addr == "cueLists", addr == "runningCues", addr == "thump", strings.HasPrefix(addr, "cue_id/") && len(req.Args) == 0 && !strings.HasSuffix(addr, "/start") && !strings.HasSuffix(addr, "/stop"):
+				continue
+			}
+			out = append(out, addr)
+		}
+		return out
+	}
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/api/go", []string{"go"}},
+		{"/api/pause", []string{"pause"}},
+		{"/api/resume", []string{"resume"}},
+		{"/api/stop", []string{"stop"}},
+		{"/api/panic", []string{"panic"}},
+		{"/api/blocks/q1/start", []string{"cue_id/" + ids["block:q1"] + "/start"}},
+		{"/api/blocks/wash/start", []string{"cue_id/" + ids["block:wash/0"] + "/start"}},
+		{"/api/blocks/wash/stop", []string{"cue_id/" + ids["block:wash/0"] + "/stop"}},
+	}
+	for _, tt := range tests {
+		if got := sent(tt.path, http.StatusOK); !slices.Equal(got, tt.want) {
+			t.Errorf("%s sent %q, want %q", tt.path, got, tt.want)
+		}
+	}
+	if running := mock.RunningCueIDs("ws-1"); slices.Contains(running, ids["block:wash/0"]) {
+		t.Error("wash still running after stop")
+	}
+
+	if got := sent("/api/blocks/nope/start", http.StatusNotFound); len(got) != 0 {
+		t.Errorf("unknown block sent %q", got)
+	}
+
+	resp, err := http.Get(ts.URL + "/api/audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var entries []AuditEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		s := strings.TrimSpace(e.Command + " " + e.Block)
+		if e.Error != "" {
+			s += " failed"
+		}
+		got = append(got, s)
+	}
+	want := []string{"go", "pause", "resume", "stop", "panic", "start q1", "start wash", "stop wash", "start nope failed"}
+	if !slices.Equal(got, want) {
+		t.Errorf("audit log %q, want %q", got, want)
+	}
+	if entries[0].From == "" || entries[0].Time.IsZero() {
+		t.Errorf("audit entry missing its source or time: %+v", entries[0])
+	}
+}
+
+func TestTransportUnreachable(t *testing.T) {
+	_, srv, _ := setupDriftTest(t)
+	srv.audit = newAuditLog(nil, nil)
+	ws, _ := srv.link.workspace()
+	if err := ws.Disconnect(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	<-ws.Closed()
+
+	state, _ := newShowState(smallShow())
+	for _, s := range []*server{srv, {state: state, audit: srv.audit}} {
+		for _, req := range []struct{ path, body string }{{"/api/go", ""}, {"/api/reset", `{"row":0}`}} {
+			rec := httptest.NewRecorder()
+			s.handler(fstest.MapFS{}).ServeHTTP(rec, httptest.NewRequest("POST", req.path, strings.NewReader(req.body)))
+			if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "Qlab is unreachable") {
+				t.Errorf("%s: got %d %q, want 503 saying Qlab is unreachable", req.path, rec.Code, rec.Body.String())
+			}
+		}
+	}
+	entries := srv.audit.list()
+	if len(entries) != 4 || slices.ContainsFunc(entries, func(e AuditEntry) bool { return e.Error == "" }) {
+		t.Errorf("audit log %+v, want four failed commands", entries)
+	}
+}
+
+func TestCommandStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{errQlabDisabled, http.StatusServiceUnavailable},
+		{qlab.ErrTimeout, http.StatusServiceUnavailable},
+		{qlab.ErrPermissionDenied, http.StatusForbidden},
+		{errBlockNotFound, http.StatusNotFound},
+		{qlab.ErrNotFound, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		if got := commandStatus(tt.err); got != tt.want {
+			t.Errorf("commandStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
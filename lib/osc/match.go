@@ -0,0 +1,108 @@
+package osc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Match reports whether an OSC address pattern matches a method address.
+// Wildcards never match across '/':
+//
+//	?        any single character
+//	*        any run of characters, including none
+//	[abc]    any listed character; a-z ranges, and a leading ! negates
+//	{foo,ba} any of the comma-separated strings
+func Match(pattern, address string) bool {
+	pp := strings.Split(pattern, "/")
+	ap := strings.Split(address, "/")
+	if len(pp) != len(ap) {
+		return false
+	}
+	for i := range pp {
+		if !matchPart(pp[i], ap[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchPart(p, s string) bool {
+	for len(p) > 0 {
+		switch p[0] {
+		case '*':
+			p = strings.TrimLeft(p, "*")
+			if p == "" {
+				return true
+			}
+			for i := range len(s) + 1 {
+				if matchPart(p, s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if s == "" {
+				return false
+			}
+		case '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 || s == "" || !matchSet(p[1:end], s[0]) {
+				return false
+			}
+			p, s = p[end+1:], s[1:]
+			continue
+		case '{':
+			end := strings.IndexByte(p, '}')
+			if end < 0 {
+				return false
+			}
+			for _, alt := range strings.Split(p[1:end], ",") {
+				if strings.HasPrefix(s, alt) && matchPart(p[end+1:], s[len(alt):]) {
+					return true
+				}
+			}
+			return false
+		default:
+			if s == "" || s[0] != p[0] {
+				return false
+			}
+		}
+		p, s = p[1:], s[1:]
+	}
+	return s == ""
+}
+
+func matchSet(set string, c byte) bool {
+	negate := strings.HasPrefix(set, "!")
+	if negate {
+		set = set[1:]
+	}
+	for i := 0; i < len(set); i++ {
+		if i+2 < len(set) && set[i+1] == '-' {
+			if set[i] <= c && c <= set[i+2] {
+				return !negate
+			}
+			i += 2
+			continue
+		}
+		if set[i] == c {
+			return !negate
+		}
+	}
+	return negate
+}
+
+// validMethodAddress rejects addresses containing characters OSC reserves
+// for patterns.
+func validMethodAddress(addr string) error {
+	if !strings.HasPrefix(addr, "/") || strings.HasSuffix(addr, "/") {
+		return fmt.Errorf("osc: method address %q must start and not end with /", addr)
+	}
+	if i := strings.IndexAny(addr, " #*,?[]{}"); i >= 0 {
+		return fmt.Errorf("osc: method address %q contains %q", addr, addr[i])
+	}
+	if strings.Contains(addr, "//") {
+		return fmt.Errorf("osc: method address %q has an empty part", addr)
+	}
+	return nil
+}
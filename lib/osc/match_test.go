@@ -0,0 +1,54 @@
+package osc
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		address string
+		want    bool
+	}{
+		{"/qrun/go", "/qrun/go", true},
+		{"/qrun/go", "/qrun/goo", false},
+		{"/qrun/go", "/qrun", false},
+		{"/qrun/?o", "/qrun/go", true},
+		{"/qrun/?", "/qrun/go", false},
+		{"/qrun/*", "/qrun/go", true},
+		{"/qrun/*", "/qrun/reset/1", false},
+		{"/*/*/1", "/qrun/reset/1", true},
+		{"/qrun/g*o*", "/qrun/go", true},
+		{"/qrun/*t", "/qrun/reset", true},
+		{"/qrun/reset/[0-9]", "/qrun/reset/7", true},
+		{"/qrun/reset/[0-9]", "/qrun/reset/12", false},
+		{"/qrun/reset/[0-9][0-9]", "/qrun/reset/12", true},
+		{"/qrun/reset/[!0-4]", "/qrun/reset/7", true},
+		{"/qrun/reset/[!0-4]", "/qrun/reset/3", false},
+		{"/qrun/reset/[13-]", "/qrun/reset/-", true},
+		{"/qrun/reset/[abc", "/qrun/reset/a", false},
+		{"/qrun/{go,stop}", "/qrun/stop", true},
+		{"/qrun/{go,stop}", "/qrun/reset", false},
+		{"/qrun/{g,go}o", "/qrun/goo", true},
+		{"/qrun/reset/{1,2}*", "/qrun/reset/25", true},
+		{"/qrun/reset/{1,2*}", "/qrun/reset/25", false},
+		{"/qrun/{go", "/qrun/go", false},
+		{"/qrun/", "/qrun/", true},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.address); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestValidMethodAddress(t *testing.T) {
+	for _, addr := range []string{"/qrun/go", "/qrun/reset/12", "/a"} {
+		if err := validMethodAddress(addr); err != nil {
+			t.Errorf("%q: %v", addr, err)
+		}
+	}
+	for _, addr := range []string{"", "qrun", "/qrun/", "/qrun//go", "/qrun/*", "/qrun/{a,b}", "/q run", "/q#"} {
+		if err := validMethodAddress(addr); err == nil {
+			t.Errorf("%q: expected error", addr)
+		}
+	}
+}
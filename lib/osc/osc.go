@@ -0,0 +1,422 @@
+// Package osc encodes and decodes Open Sound Control 1.0 and 1.1 packets.
+//
+// Arguments map to Go types as follows:
+//
+//	i  int32 (int is accepted when encoding)
+//	f  float32
+//	s  string
+//	S  Symbol
+//	b  []byte
+//	h  int64
+//	d  float64
+//	t  Timetag (time.Time is accepted when encoding)
+//	c  Char
+//	r  color.RGBA
+//	m  MIDI
+//	T  true
+//	F  false
+//	N  nil
+//	I  Impulse
+//	[] []any
+package osc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+	"time"
+)
+
+const bundleTag = "#bundle\x00"
+
+// ntpEpochOffset is the number of seconds from 1900, the NTP epoch used by
+// timetags, to 1970.
+const ntpEpochOffset = 2208988800
+
+// Timetag is an NTP timestamp: seconds since 1900 in the high 32 bits and
+// fractions of a second in the low 32.
+type Timetag uint64
+
+// Immediately asks the receiver to act on a bundle as soon as it arrives.
+const Immediately Timetag = 1
+
+func NewTimetag(t time.Time) Timetag {
+	secs := uint64(t.Unix() + ntpEpochOffset)
+	frac := (uint64(t.Nanosecond())<<32 + 500_000_000) / 1_000_000_000
+	return Timetag(secs<<32 | frac)
+}
+
+func (t Timetag) Time() time.Time {
+	secs := int64(t>>32) - ntpEpochOffset
+	nanos := ((uint64(t)&0xFFFFFFFF)*1_000_000_000 + 1<<31) >> 32
+	return time.Unix(secs, int64(nanos))
+}
+
+// Symbol is a string sent with the alternate 'S' tag.
+type Symbol string
+
+// Char is a single ASCII character, sent as 32 bits.
+type Char rune
+
+// Impulse is the argument-less 'I' tag, called Infinitum in OSC 1.0.
+type Impulse struct{}
+
+type MIDI struct {
+	Port   uint8
+	Status uint8
+	Data1  uint8
+	Data2  uint8
+}
+
+// Packet is a Message or a Bundle.
+type Packet interface {
+	MarshalBinary() ([]byte, error)
+	isPacket()
+}
+
+type Message struct {
+	Address string
+	Args    []any
+}
+
+// Bundle elements are delivered together; bundles may nest.
+type Bundle struct {
+	Timetag  Timetag
+	Elements []Packet
+}
+
+func (Message) isPacket() {}
+func (Bundle) isPacket()  {}
+
+func pad(n int) int {
+	return (4 - n%4) % 4
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = append(buf, s...)
+	return append(buf, make([]byte, 1+pad(len(s)+1))...)
+}
+
+func (m Message) MarshalBinary() ([]byte, error) {
+	if !strings.HasPrefix(m.Address, "/") {
+		return nil, fmt.Errorf("osc: address %q does not start with /", m.Address)
+	}
+	tags := []byte{','}
+	var payload []byte
+	for _, arg := range m.Args {
+		var err error
+		tags, payload, err = appendArg(tags, payload, arg)
+		if err != nil {
+			return nil, fmt.Errorf("osc: %s: %w", m.Address, err)
+		}
+	}
+	buf := appendString(nil, m.Address)
+	buf = appendString(buf, string(tags))
+	return append(buf, payload...), nil
+}
+
+func appendArg(tags []byte, payload []byte, arg any) ([]byte, []byte, error) {
+	be := binary.BigEndian
+	switch v := arg.(type) {
+	case int32:
+		return append(tags, 'i'), be.AppendUint32(payload, uint32(v)), nil
+	case int:
+		if v < math.MinInt32 || v > math.MaxInt32 {
+			return nil, nil, fmt.Errorf("int %d overflows int32", v)
+		}
+		return append(tags, 'i'), be.AppendUint32(payload, uint32(int32(v))), nil
+	case float32:
+		return append(tags, 'f'), be.AppendUint32(payload, math.Float32bits(v)), nil
+	case string:
+		if strings.IndexByte(v, 0) >= 0 {
+			return nil, nil, fmt.Errorf("string %q contains NUL", v)
+		}
+		return append(tags, 's'), appendString(payload, v), nil
+	case Symbol:
+		if strings.IndexByte(string(v), 0) >= 0 {
+			return nil, nil, fmt.Errorf("symbol %q contains NUL", v)
+		}
+		return append(tags, 'S'), appendString(payload, string(v)), nil
+	case []byte:
+		payload = be.AppendUint32(payload, uint32(len(v)))
+		payload = append(payload, v...)
+		return append(tags, 'b'), append(payload, make([]byte, pad(len(v)))...), nil
+	case int64:
+		return append(tags, 'h'), be.AppendUint64(payload, uint64(v)), nil
+	case float64:
+		return append(tags, 'd'), be.AppendUint64(payload, math.Float64bits(v)), nil
+	case Timetag:
+		return append(tags, 't'), be.AppendUint64(payload, uint64(v)), nil
+	case time.Time:
+		return append(tags, 't'), be.AppendUint64(payload, uint64(NewTimetag(v))), nil
+	case Char:
+		return append(tags, 'c'), be.AppendUint32(payload, uint32(v)), nil
+	case color.RGBA:
+		return append(tags, 'r'), append(payload, v.R, v.G, v.B, v.A), nil
+	case MIDI:
+		return append(tags, 'm'), append(payload, v.Port, v.Status, v.Data1, v.Data2), nil
+	case bool:
+		if v {
+			return append(tags, 'T'), payload, nil
+		}
+		return append(tags, 'F'), payload, nil
+	case nil:
+		return append(tags, 'N'), payload, nil
+	case Impulse:
+		return append(tags, 'I'), payload, nil
+	case []any:
+		tags = append(tags, '[')
+		for _, e := range v {
+			var err error
+			tags, payload, err = appendArg(tags, payload, e)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		return append(tags, ']'), payload, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported argument type %T", arg)
+	}
+}
+
+func (b Bundle) MarshalBinary() ([]byte, error) {
+	buf := []byte(bundleTag)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(b.Timetag))
+	for _, e := range b.Elements {
+		if e == nil {
+			return nil, fmt.Errorf("osc: nil bundle element")
+		}
+		data, err := e.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(data)))
+		buf = append(buf, data...)
+	}
+	return buf, nil
+}
+
+func IsBundle(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(bundleTag))
+}
+
+// Parse decodes a Message or a Bundle. It rejects unknown type tags, missing
+// or non-zero padding, and trailing bytes.
+func Parse(data []byte) (Packet, error) {
+	if IsBundle(data) {
+		return parseBundle(data)
+	}
+	return ParseMessage(data)
+}
+
+func parseBundle(data []byte) (Bundle, error) {
+	if len(data) < len(bundleTag)+8 {
+		return Bundle{}, fmt.Errorf("osc: truncated bundle timetag")
+	}
+	b := Bundle{Timetag: Timetag(binary.BigEndian.Uint64(data[len(bundleTag):]))}
+	pos := len(bundleTag) + 8
+	for pos < len(data) {
+		if pos+4 > len(data) {
+			return Bundle{}, fmt.Errorf("osc: truncated bundle element size")
+		}
+		size := int(binary.BigEndian.Uint32(data[pos:]))
+		pos += 4
+		if size == 0 || size%4 != 0 {
+			return Bundle{}, fmt.Errorf("osc: bundle element size %d is not a positive multiple of 4", size)
+		}
+		if size > len(data)-pos {
+			return Bundle{}, fmt.Errorf("osc: truncated bundle element")
+		}
+		e, err := Parse(data[pos : pos+size])
+		if err != nil {
+			return Bundle{}, err
+		}
+		b.Elements = append(b.Elements, e)
+		pos += size
+	}
+	return b, nil
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) string() (string, error) {
+	end := bytes.IndexByte(d.data[d.pos:], 0)
+	if end < 0 {
+		return "", fmt.Errorf("unterminated string")
+	}
+	s := string(d.data[d.pos : d.pos+end])
+	if err := d.skipPadding(end + 1); err != nil {
+		return "", fmt.Errorf("string %q: %w", s, err)
+	}
+	return s, nil
+}
+
+// skipPadding advances past n bytes of content and the zero bytes that pad
+// it to a multiple of four.
+func (d *decoder) skipPadding(n int) error {
+	end := d.pos + n + pad(n)
+	if end > len(d.data) {
+		return fmt.Errorf("truncated padding")
+	}
+	for _, b := range d.data[d.pos+n : end] {
+		if b != 0 {
+			return fmt.Errorf("non-zero padding")
+		}
+	}
+	d.pos = end
+	return nil
+}
+
+func (d *decoder) next(n int) ([]byte, error) {
+	if d.pos+n > len(d.data) {
+		return nil, fmt.Errorf("truncated argument")
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) arg(tag byte) (any, error) {
+	be := binary.BigEndian
+	switch tag {
+	case 'i', 'f', 'c', 'r', 'm':
+		b, err := d.next(4)
+		if err != nil {
+			return nil, err
+		}
+		switch tag {
+		case 'i':
+			return int32(be.Uint32(b)), nil
+		case 'f':
+			return math.Float32frombits(be.Uint32(b)), nil
+		case 'c':
+			return Char(be.Uint32(b)), nil
+		case 'r':
+			return color.RGBA{R: b[0], G: b[1], B: b[2], A: b[3]}, nil
+		default:
+			return MIDI{Port: b[0], Status: b[1], Data1: b[2], Data2: b[3]}, nil
+		}
+	case 'h', 'd', 't':
+		b, err := d.next(8)
+		if err != nil {
+			return nil, err
+		}
+		switch tag {
+		case 'h':
+			return int64(be.Uint64(b)), nil
+		case 'd':
+			return math.Float64frombits(be.Uint64(b)), nil
+		default:
+			return Timetag(be.Uint64(b)), nil
+		}
+	case 's':
+		return d.string()
+	case 'S':
+		s, err := d.string()
+		return Symbol(s), err
+	case 'b':
+		b, err := d.next(4)
+		if err != nil {
+			return nil, err
+		}
+		size := be.Uint32(b)
+		if uint64(size) > uint64(len(d.data)-d.pos) {
+			return nil, fmt.Errorf("truncated blob")
+		}
+		blob := bytes.Clone(d.data[d.pos : d.pos+int(size)])
+		if err := d.skipPadding(int(size)); err != nil {
+			return nil, fmt.Errorf("blob: %w", err)
+		}
+		return blob, nil
+	case 'T':
+		return true, nil
+	case 'F':
+		return false, nil
+	case 'N':
+		return nil, nil
+	case 'I':
+		return Impulse{}, nil
+	default:
+		return nil, fmt.Errorf("unknown type tag %q", tag)
+	}
+}
+
+func ParseMessage(data []byte) (Message, error) {
+	if len(data)%4 != 0 {
+		return Message{}, fmt.Errorf("osc: message length %d is not a multiple of 4", len(data))
+	}
+	d := &decoder{data: data}
+	addr, err := d.string()
+	if err != nil {
+		return Message{}, fmt.Errorf("osc: address: %w", err)
+	}
+	if !strings.HasPrefix(addr, "/") {
+		return Message{}, fmt.Errorf("osc: address %q does not start with /", addr)
+	}
+	m := Message{Address: addr}
+
+	// OSC 1.0 allows old senders to omit the type tag string entirely.
+	if d.pos == len(data) {
+		return m, nil
+	}
+	tags, err := d.string()
+	if err != nil {
+		return Message{}, fmt.Errorf("osc: %s: type tags: %w", addr, err)
+	}
+	if !strings.HasPrefix(tags, ",") {
+		return Message{}, fmt.Errorf("osc: %s: type tags %q do not start with ','", addr, tags)
+	}
+
+	var stack [][]any
+	var cur []any
+	for i := 1; i < len(tags); i++ {
+		switch tags[i] {
+		case '[':
+			stack = append(stack, cur)
+			cur = []any{}
+		case ']':
+			if len(stack) == 0 {
+				return Message{}, fmt.Errorf("osc: %s: unmatched ']'", addr)
+			}
+			arr := cur
+			cur = append(stack[len(stack)-1], arr)
+			stack = stack[:len(stack)-1]
+		default:
+			v, err := d.arg(tags[i])
+			if err != nil {
+				return Message{}, fmt.Errorf("osc: %s: argument %d: %w", addr, i-1, err)
+			}
+			cur = append(cur, v)
+		}
+	}
+	if len(stack) > 0 {
+		return Message{}, fmt.Errorf("osc: %s: unterminated array", addr)
+	}
+	if d.pos != len(data) {
+		return Message{}, fmt.Errorf("osc: %s: %d trailing bytes", addr, len(data)-d.pos)
+	}
+	m.Args = cur
+	return m, nil
+}
+
+// Messages flattens a packet into its messages in order.
+func Messages(p Packet) []Message {
+	switch p := p.(type) {
+	case Message:
+		return []Message{p}
+	case Bundle:
+		var msgs []Message
+		for _, e := range p.Elements {
+			msgs = append(msgs, Messages(e)...)
+		}
+		return msgs
+	default:
+		return nil
+	}
+}
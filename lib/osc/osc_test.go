@@ -0,0 +1,202 @@
+package osc
+
+import (
+	"bytes"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustMarshal(t testing.TB, p Packet) []byte {
+	t.Helper()
+	data, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	args := []any{
+		int32(-7), float32(1.5), "hello", "", Symbol("sym"), []byte{1, 2, 3}, []byte{},
+		int64(1 << 40), 2.25, Timetag(42), Char('x'),
+		color.RGBA{R: 1, G: 2, B: 3, A: 4}, MIDI{Port: 0, Status: 0x90, Data1: 60, Data2: 127},
+		true, false, nil, Impulse{},
+		[]any{int32(1), []any{"nested"}, []any{}},
+	}
+	m := Message{Address: "/workspace/ws-1/go", Args: args}
+	got, err := Parse(mustMarshal(t, m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("got %#v, want %#v", got, m)
+	}
+}
+
+func TestMessageEncoding(t *testing.T) {
+	data := mustMarshal(t, Message{Address: "/ab", Args: []any{int32(1), "xyz1", true, []any{nil}}})
+	want := []byte("/ab\x00" + ",isT[N]\x00" + "\x00\x00\x00\x01" + "xyz1\x00\x00\x00\x00")
+	if !bytes.Equal(data, want) {
+		t.Errorf("got %q, want %q", data, want)
+	}
+}
+
+func TestMarshalConversions(t *testing.T) {
+	tm := time.Unix(1700000000, 0)
+	got, err := Parse(mustMarshal(t, Message{Address: "/a", Args: []any{7, tm}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Message{Address: "/a", Args: []any{int32(7), NewTimetag(tm)}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	tests := map[string]Message{
+		"uint8":       {Address: "/a", Args: []any{uint8(1)}},
+		"struct":      {Address: "/a", Args: []any{struct{}{}}},
+		"nested":      {Address: "/a", Args: []any{[]any{map[string]int{}}}},
+		"int range":   {Address: "/a", Args: []any{1 << 40}},
+		"nul string":  {Address: "/a", Args: []any{"a\x00b"}},
+		"bad address": {Address: "a"},
+	}
+	for name, m := range tests {
+		if _, err := m.MarshalBinary(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	b := Bundle{Elements: []Packet{Message{Address: "/a", Args: []any{uint8(1)}}}}
+	if _, err := b.MarshalBinary(); err == nil {
+		t.Error("bundle with a bad element: expected error")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"unaligned":         "/a\x00",
+		"no address nul":    "/abc",
+		"relative address":  "ab\x00\x00,\x00\x00\x00",
+		"no comma":          "/a\x00\x00i\x00\x00\x00\x00\x00\x00\x01",
+		"unknown tag":       "/a\x00\x00,x\x00\x00",
+		"truncated int":     "/a\x00\x00,i\x00\x00",
+		"unterminated arg":  "/a\x00\x00,s\x00\x00abcd",
+		"bad padding":       "/a\x00\x01,\x00\x00\x00",
+		"bad arg padding":   "/a\x00\x00,s\x00\x00ab\x00\x01",
+		"trailing bytes":    "/a\x00\x00,\x00\x00\x00\x00\x00\x00\x01",
+		"unmatched ]":       "/a\x00\x00,]\x00\x00",
+		"unclosed [":        "/a\x00\x00,[\x00\x00",
+		"truncated blob":    "/a\x00\x00,b\x00\x00\x00\x00\x00\x08abcd",
+		"bad blob padding":  "/a\x00\x00,b\x00\x00\x00\x00\x00\x01a\x00\x00\x01",
+		"bundle timetag":    bundleTag + "\x00\x00\x00\x00",
+		"bundle size":       bundleTag + "\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00",
+		"bundle zero size":  bundleTag + "\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x00",
+		"bundle unaligned":  bundleTag + "\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x03/a\x00",
+		"bundle overrun":    bundleTag + "\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x08/a\x00\x00",
+		"bundle bad member": bundleTag + "\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x04a\x00\x00\x00",
+	}
+	for name, data := range tests {
+		if p, err := Parse([]byte(data)); err == nil {
+			t.Errorf("%s: expected error, got %#v", name, p)
+		}
+	}
+}
+
+func TestParseWithoutTypeTags(t *testing.T) {
+	got, err := Parse([]byte("/abc\x00\x00\x00\x00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, Message{Address: "/abc"}) {
+		t.Errorf("got %#v", got)
+	}
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	b := Bundle{
+		Timetag: Immediately,
+		Elements: []Packet{
+			Message{Address: "/a", Args: []any{int32(1)}},
+			Bundle{
+				Timetag: NewTimetag(time.Unix(1700000000, 250_000_000)),
+				Elements: []Packet{
+					Message{Address: "/b/c", Args: []any{"x", float32(2)}},
+					Bundle{Timetag: Immediately},
+				},
+			},
+			Message{Address: "/d"},
+		},
+	}
+	got, err := Parse(mustMarshal(t, b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, b) {
+		t.Errorf("got %#v, want %#v", got, b)
+	}
+
+	var addrs []string
+	for _, m := range Messages(got) {
+		addrs = append(addrs, m.Address)
+	}
+	if strings.Join(addrs, " ") != "/a /b/c /d" {
+		t.Errorf("got messages %v", addrs)
+	}
+}
+
+func TestTimetag(t *testing.T) {
+	for _, tm := range []time.Time{
+		time.Unix(0, 0),
+		time.Unix(1700000000, 1),
+		time.Unix(1700000000, 999_999_999),
+	} {
+		if got := NewTimetag(tm).Time(); !got.Equal(tm) {
+			t.Errorf("round trip of %v gave %v", tm, got)
+		}
+	}
+	if got := NewTimetag(time.Unix(0, 0)) >> 32; got != ntpEpochOffset {
+		t.Errorf("unix epoch is %d NTP seconds, want %d", got, ntpEpochOffset)
+	}
+	if got := NewTimetag(time.Unix(0, 500_000_000)) & 0xFFFFFFFF; got != 1<<31 {
+		t.Errorf("half a second is fraction %#x, want %#x", uint64(got), 1<<31)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	seeds := []Packet{
+		Message{Address: "/workspace/ws-1/cue_id/cue-1/name", Args: []any{"Intro"}},
+		Message{Address: "/a", Args: []any{int32(1), float32(2), []byte{3}, int64(4), 5.0, true, nil, []any{Char('c')}}},
+		Bundle{Timetag: Immediately, Elements: []Packet{
+			Message{Address: "/a"},
+			Bundle{Elements: []Packet{Message{Address: "/b", Args: []any{Symbol("c"), MIDI{}, color.RGBA{}}}}},
+		}},
+	}
+	for _, p := range seeds {
+		f.Add(mustMarshal(f, p))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p, err := Parse(data)
+		if err != nil {
+			return
+		}
+		first, err := p.MarshalBinary()
+		if err != nil {
+			t.Fatalf("parsed %#v does not marshal: %v", p, err)
+		}
+		again, err := Parse(first)
+		if err != nil {
+			t.Fatalf("re-encoded %#v does not parse: %v", p, err)
+		}
+		second, err := again.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(first, second) {
+			t.Fatalf("encoding is not stable: %q then %q", first, second)
+		}
+	})
+}
@@ -0,0 +1,277 @@
+package osc
+
+import (
+	"errors"
+	"net"
+	"slices"
+	"sync"
+)
+
+// Request is one message delivered to a Handler. Messages from a bundle carry
+// its timetag; handlers run on arrival and may honour the timetag themselves.
+type Request struct {
+	Message
+	Timetag    Timetag
+	RemoteAddr net.Addr
+
+	reply func([]byte) error
+}
+
+// Reply sends a message back to the sender over the transport the request
+// arrived on.
+func (r *Request) Reply(m Message) error {
+	data, err := m.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return r.reply(data)
+}
+
+type Handler interface {
+	ServeOSC(r *Request)
+}
+
+type HandlerFunc func(r *Request)
+
+func (f HandlerFunc) ServeOSC(r *Request) {
+	f(r)
+}
+
+// Dispatcher is a registry of OSC methods. An incoming address is treated as
+// a pattern and every method it matches is called, in address order.
+type Dispatcher struct {
+	mu      sync.RWMutex
+	methods map[string]Handler
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{methods: map[string]Handler{}}
+}
+
+// Handle registers h at a literal method address, replacing any handler
+// already there.
+func (d *Dispatcher) Handle(addr string, h Handler) error {
+	if err := validMethodAddress(addr); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.methods[addr] = h
+	return nil
+}
+
+func (d *Dispatcher) HandleFunc(addr string, f func(r *Request)) error {
+	return d.Handle(addr, HandlerFunc(f))
+}
+
+func (d *Dispatcher) Remove(addr string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.methods, addr)
+}
+
+// Addresses returns the registered method addresses in order.
+func (d *Dispatcher) Addresses() []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.addressesLocked()
+}
+
+func (d *Dispatcher) ServeOSC(r *Request) {
+	var matched []Handler
+	d.mu.RLock()
+	if h, ok := d.methods[r.Address]; ok {
+		matched = append(matched, h)
+	} else {
+		for _, addr := range d.addressesLocked() {
+			if Match(r.Address, addr) {
+				matched = append(matched, d.methods[addr])
+			}
+		}
+	}
+	d.mu.RUnlock()
+	for _, h := range matched {
+		rr := *r
+		h.ServeOSC(&rr)
+	}
+}
+
+func (d *Dispatcher) addressesLocked() []string {
+	addrs := make([]string, 0, len(d.methods))
+	for addr := range d.methods {
+		addrs = append(addrs, addr)
+	}
+	slices.Sort(addrs)
+	return addrs
+}
+
+var ErrServerClosed = errors.New("osc: server closed")
+
+// Server receives OSC over UDP datagrams and SLIP-framed TCP streams. Each
+// connection, and the UDP socket, is served in order on its own goroutine.
+type Server struct {
+	Handler Handler
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	packets   map[net.PacketConn]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+}
+
+func (s *Server) ListenAndServeUDP(addr string) error {
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	return s.ServeUDP(pc)
+}
+
+func (s *Server) ListenAndServeTCP(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.ServeTCP(ln)
+}
+
+// ServeUDP handles datagrams from pc until the server is closed.
+func (s *Server) ServeUDP(pc net.PacketConn) error {
+	if !track(s, &s.packets, pc) {
+		pc.Close()
+		return ErrServerClosed
+	}
+	defer untrack(s, &s.packets, pc)
+
+	buf := make([]byte, 65536)
+	for {
+		n, from, err := pc.ReadFrom(buf)
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			return err
+		}
+		s.dispatch(buf[:n], from, func(data []byte) error {
+			_, err := pc.WriteTo(data, from)
+			return err
+		})
+	}
+}
+
+// ServeTCP accepts connections on ln until the server is closed.
+func (s *Server) ServeTCP(ln net.Listener) error {
+	if !track(s, &s.listeners, ln) {
+		ln.Close()
+		return ErrServerClosed
+	}
+	defer untrack(s, &s.listeners, ln)
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			return err
+		}
+		if !track(s, &s.conns, conn) {
+			conn.Close()
+			return ErrServerClosed
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer untrack(s, &s.conns, conn)
+	defer conn.Close()
+
+	var writeMu sync.Mutex
+	reply := func(data []byte) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		_, err := conn.Write(SLIPEncode(data))
+		return err
+	}
+
+	buf := make([]byte, 0, 65536)
+	tmp := make([]byte, 4096)
+	for {
+		n, err := conn.Read(tmp)
+		if err != nil {
+			return
+		}
+		buf = append(buf, tmp[:n]...)
+		for {
+			frame, rest, ok := NextSLIPFrame(buf)
+			if !ok {
+				break
+			}
+			buf = rest
+			s.dispatch(frame, conn.RemoteAddr(), reply)
+		}
+	}
+}
+
+// dispatch drops packets that do not parse; OSC has no way to report them.
+func (s *Server) dispatch(data []byte, from net.Addr, reply func([]byte) error) {
+	p, err := Parse(data)
+	if err != nil || s.Handler == nil {
+		return
+	}
+	s.dispatchPacket(p, Immediately, from, reply)
+}
+
+func (s *Server) dispatchPacket(p Packet, tt Timetag, from net.Addr, reply func([]byte) error) {
+	switch p := p.(type) {
+	case Message:
+		s.Handler.ServeOSC(&Request{Message: p, Timetag: tt, RemoteAddr: from, reply: reply})
+	case Bundle:
+		for _, e := range p.Elements {
+			s.dispatchPacket(e, p.Timetag, from, reply)
+		}
+	}
+}
+
+// Close stops every listener and closes open connections.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for ln := range s.listeners {
+		ln.Close()
+	}
+	for pc := range s.packets {
+		pc.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	return nil
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func track[T comparable](s *Server, set *map[T]struct{}, v T) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	if *set == nil {
+		*set = map[T]struct{}{}
+	}
+	(*set)[v] = struct{}{}
+	return true
+}
+
+func untrack[T comparable](s *Server, set *map[T]struct{}, v T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(*set, v)
+}
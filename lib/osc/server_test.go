@@ -0,0 +1,238 @@
+package osc
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	mu   sync.Mutex
+	reqs []*Request
+	got  chan struct{}
+}
+
+func newRecorder() *recorder {
+	return &recorder{got: make(chan struct{}, 100)}
+}
+
+func (rec *recorder) handler(name string) Handler {
+	return HandlerFunc(func(r *Request) {
+		rec.mu.Lock()
+		r.Address = name + " <- " + r.Address
+		rec.reqs = append(rec.reqs, r)
+		rec.mu.Unlock()
+		rec.got <- struct{}{}
+	})
+}
+
+func (rec *recorder) wait(t *testing.T, n int) []*Request {
+	t.Helper()
+	for range n {
+		select {
+		case <-rec.got:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for %d requests", n)
+		}
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	reqs := rec.reqs
+	rec.reqs = nil
+	return reqs
+}
+
+func addresses(reqs []*Request) []string {
+	var addrs []string
+	for _, r := range reqs {
+		addrs = append(addrs, r.Address)
+	}
+	return addrs
+}
+
+func setupServer(t *testing.T) (*recorder, net.Addr, net.Addr) {
+	t.Helper()
+	rec := newRecorder()
+	d := NewDispatcher()
+	for _, addr := range []string{"/qrun/go", "/qrun/reset/1", "/qrun/reset/2", "/qrun/reset/10"} {
+		if err := d.Handle(addr, rec.handler(addr)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Handle("/qrun/*", rec.handler("bad")); err == nil {
+		t.Error("pattern accepted as a method address")
+	}
+
+	srv := &Server{Handler: d}
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	udpErr := make(chan error, 1)
+	tcpErr := make(chan error, 1)
+	go func() { udpErr <- srv.ServeUDP(pc) }()
+	go func() { tcpErr <- srv.ServeTCP(ln) }()
+	t.Cleanup(func() {
+		srv.Close()
+		for _, ch := range []chan error{udpErr, tcpErr} {
+			if err := <-ch; !errors.Is(err, ErrServerClosed) {
+				t.Errorf("serve returned %v, want ErrServerClosed", err)
+			}
+		}
+	})
+	return rec, pc.LocalAddr(), ln.Addr()
+}
+
+func TestServerUDP(t *testing.T) {
+	rec, udpAddr, _ := setupServer(t)
+	conn, err := net.Dial("udp", udpAddr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	send := func(p Packet) {
+		t.Helper()
+		if _, err := conn.Write(mustMarshal(t, p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send(Message{Address: "/qrun/go", Args: []any{int32(1)}})
+	reqs := rec.wait(t, 1)
+	if reqs[0].Address != "/qrun/go <- /qrun/go" || !reflect.DeepEqual(reqs[0].Args, []any{int32(1)}) {
+		t.Errorf("got %q %v", reqs[0].Address, reqs[0].Args)
+	}
+	if reqs[0].Timetag != Immediately {
+		t.Errorf("got timetag %v, want Immediately", reqs[0].Timetag)
+	}
+
+	send(Message{Address: "/qrun/reset/?"})
+	want := []string{"/qrun/reset/1 <- /qrun/reset/?", "/qrun/reset/2 <- /qrun/reset/?"}
+	if got := addresses(rec.wait(t, 2)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	tt := NewTimetag(time.Unix(1700000000, 0))
+	send(Bundle{Timetag: tt, Elements: []Packet{
+		Message{Address: "/qrun/reset/1{0,}"},
+		Message{Address: "/qrun/go"},
+	}})
+	reqs = rec.wait(t, 3)
+	want = []string{"/qrun/reset/1 <- /qrun/reset/1{0,}", "/qrun/reset/10 <- /qrun/reset/1{0,}", "/qrun/go <- /qrun/go"}
+	if got := addresses(reqs); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, r := range reqs {
+		if r.Timetag != tt {
+			t.Errorf("%s: got timetag %v, want the bundle's", r.Address, r.Timetag)
+		}
+	}
+
+	conn.Write([]byte("garbage"))
+	send(Message{Address: "/nothing/here"})
+	send(Message{Address: "/qrun/go"})
+	if got := addresses(rec.wait(t, 1)); !reflect.DeepEqual(got, []string{"/qrun/go <- /qrun/go"}) {
+		t.Errorf("got %v after unmatched packets", got)
+	}
+}
+
+func TestServerTCP(t *testing.T) {
+	rec, _, tcpAddr := setupServer(t)
+	conn, err := net.Dial("tcp", tcpAddr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Two frames in one write, the second split across writes.
+	first := SLIPEncode(mustMarshal(t, Message{Address: "/qrun/go"}))
+	second := SLIPEncode(mustMarshal(t, Message{Address: "/qrun/reset/2"}))
+	conn.Write(append(first, second[:5]...))
+	conn.Write(second[5:])
+
+	want := []string{"/qrun/go <- /qrun/go", "/qrun/reset/2 <- /qrun/reset/2"}
+	if got := addresses(rec.wait(t, 2)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestServerReply(t *testing.T) {
+	d := NewDispatcher()
+	d.HandleFunc("/ping", func(r *Request) {
+		r.Reply(Message{Address: "/pong", Args: r.Args})
+	})
+	srv := &Server{Handler: d}
+	defer srv.Close()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.ServeUDP(pc)
+	go srv.ServeTCP(ln)
+
+	ping := mustMarshal(t, Message{Address: "/ping", Args: []any{"hi"}})
+	want := Message{Address: "/pong", Args: []any{"hi"}}
+
+	udp, err := net.Dial("udp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+	udp.SetDeadline(time.Now().Add(2 * time.Second))
+	udp.Write(ping)
+	buf := make([]byte, 1024)
+	n, err := udp.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Parse(buf[:n]); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("UDP reply %#v, %v", got, err)
+	}
+
+	tcp, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	tcp.SetDeadline(time.Now().Add(2 * time.Second))
+	tcp.Write(SLIPEncode(ping))
+	var stream []byte
+	for {
+		n, err := tcp.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream = append(stream, buf[:n]...)
+		if frame, _, ok := NextSLIPFrame(stream); ok {
+			if got, err := Parse(frame); err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("TCP reply %#v, %v", got, err)
+			}
+			break
+		}
+	}
+}
+
+func TestServerClosedBeforeServe(t *testing.T) {
+	srv := &Server{}
+	srv.Close()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.ServeUDP(pc); !errors.Is(err, ErrServerClosed) {
+		t.Errorf("got %v, want ErrServerClosed", err)
+	}
+}
@@ -0,0 +1,62 @@
+package osc
+
+// SLIP (RFC 1055) frames OSC packets on stream transports, as OSC 1.1
+// specifies for TCP.
+const (
+	slipEnd    = 0xC0
+	slipEsc    = 0xDB
+	slipEscEnd = 0xDC
+	slipEscEsc = 0xDD
+)
+
+// NextSLIPFrame returns the first complete frame in data, decoded, and the
+// bytes after it. ok is false until a whole frame has arrived.
+func NextSLIPFrame(data []byte) (frame []byte, rest []byte, ok bool) {
+	start := -1
+	for i, b := range data {
+		if b == slipEnd {
+			if start == -1 {
+				start = i
+			} else {
+				raw := data[start+1 : i]
+				frame := SLIPDecode(raw)
+				return frame, data[i+1:], true
+			}
+		}
+	}
+	return nil, data, false
+}
+
+func SLIPEncode(data []byte) []byte {
+	out := []byte{slipEnd}
+	for _, b := range data {
+		switch b {
+		case slipEnd:
+			out = append(out, slipEsc, slipEscEnd)
+		case slipEsc:
+			out = append(out, slipEsc, slipEscEsc)
+		default:
+			out = append(out, b)
+		}
+	}
+	out = append(out, slipEnd)
+	return out
+}
+
+func SLIPDecode(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] == slipEsc && i+1 < len(data) {
+			switch data[i+1] {
+			case slipEscEnd:
+				out = append(out, slipEnd)
+			case slipEscEsc:
+				out = append(out, slipEsc)
+			}
+			i++
+		} else {
+			out = append(out, data[i])
+		}
+	}
+	return out
+}
@@ -0,0 +1,45 @@
+package osc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSLIPRoundTrip(t *testing.T) {
+	data := []byte{1, slipEnd, 2, slipEsc, 3, slipEsc, slipEscEnd}
+	enc := SLIPEncode(data)
+	if bytes.Count(enc, []byte{slipEnd}) != 2 {
+		t.Errorf("encoded %x has END inside the frame", enc)
+	}
+	frame, rest, ok := NextSLIPFrame(enc)
+	if !ok || len(rest) != 0 {
+		t.Fatalf("got ok=%v rest=%x", ok, rest)
+	}
+	if !bytes.Equal(frame, data) {
+		t.Errorf("got %x, want %x", frame, data)
+	}
+}
+
+func TestNextSLIPFrame(t *testing.T) {
+	stream := append(SLIPEncode([]byte("one")), SLIPEncode([]byte("two"))...)
+
+	var frames []string
+	buf := stream[:len(stream)-2]
+	for {
+		frame, rest, ok := NextSLIPFrame(buf)
+		if !ok {
+			break
+		}
+		frames = append(frames, string(frame))
+		buf = rest
+	}
+	if len(frames) != 1 || frames[0] != "one" {
+		t.Fatalf("got %q from a partial stream", frames)
+	}
+
+	buf = append(buf, stream[len(stream)-2:]...)
+	frame, _, ok := NextSLIPFrame(buf)
+	if !ok || string(frame) != "two" {
+		t.Errorf("got %q, %v after the rest arrived", frame, ok)
+	}
+}
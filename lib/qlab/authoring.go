@@ -0,0 +1,109 @@
+package qlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// NewCue creates a cue of the given type ("group", "memo", "audio", ...) and
+// returns its unique ID. The cue is inserted after afterCueID, or after the
+// current selection if afterCueID is empty. Qlab selects the new cue, so
+// consecutive calls with an empty afterCueID create cues in order.
+func (c *Client) NewCue(ctx context.Context, workspaceID string, cueType string, afterCueID string) (string, error) {
+	if afterCueID != "" {
+		if err := c.SelectCue(ctx, workspaceID, afterCueID); err != nil {
+			return "", err
+		}
+	}
+	reply, err := c.request(ctx, fmt.Sprintf("/workspace/%s/new", workspaceID), cueType)
+	if err != nil {
+		return "", err
+	}
+	var id string
+	if err := json.Unmarshal(reply.Data, &id); err != nil {
+		return "", fmt.Errorf("qlab: new %s: %w", cueType, err)
+	}
+	return id, nil
+}
+
+func (c *Client) SelectCue(ctx context.Context, workspaceID string, cueID string) error {
+	return c.send(ctx, fmt.Sprintf("/workspace/%s/select_id/%s", workspaceID, cueID))
+}
+
+func (c *Client) DeleteCue(ctx context.Context, workspaceID string, cueID string) error {
+	return c.send(ctx, fmt.Sprintf("/workspace/%s/delete_id/%s", workspaceID, cueID))
+}
+
+// MoveCue moves a cue to position index within parentID, which may be a
+// group or a cue list.
+func (c *Client) MoveCue(ctx context.Context, workspaceID string, cueID string, parentID string, index int) error {
+	return c.send(ctx, fmt.Sprintf("/workspace/%s/move/%s", workspaceID, cueID), int32(index), parentID)
+}
+
+func (c *Client) CueChildren(ctx context.Context, workspaceID string, cueID string) ([]Cue, error) {
+	reply, err := c.CueGet(ctx, workspaceID, cueID, "children")
+	if err != nil {
+		return nil, err
+	}
+	var cues []Cue
+	if err := json.Unmarshal(reply.Data, &cues); err != nil {
+		return nil, err
+	}
+	return cues, nil
+}
+
+func (c *Client) CueParent(ctx context.Context, workspaceID string, cueID string) (string, error) {
+	return GetCueProperty(ctx, c, workspaceID, cueID, stringProperty("parent"))
+}
+
+// GroupCues creates a group cue in place of the first cue and moves every
+// listed cue into it, in order.
+func (c *Client) GroupCues(ctx context.Context, workspaceID string, cueIDs []string) (string, error) {
+	if len(cueIDs) == 0 {
+		return "", fmt.Errorf("qlab: group: no cues")
+	}
+	groupID, err := c.NewCue(ctx, workspaceID, "group", cueIDs[0])
+	if err != nil {
+		return "", err
+	}
+	for i, id := range cueIDs {
+		if err := c.MoveCue(ctx, workspaceID, id, groupID, i); err != nil {
+			return "", err
+		}
+	}
+	return groupID, nil
+}
+
+// UngroupCue moves a group's children into the group's parent at the group's
+// position and deletes the group.
+func (c *Client) UngroupCue(ctx context.Context, workspaceID string, groupID string) error {
+	parentID, err := c.CueParent(ctx, workspaceID, groupID)
+	if err != nil {
+		return err
+	}
+	siblings, err := c.CueChildren(ctx, workspaceID, parentID)
+	if err != nil {
+		return err
+	}
+	index := -1
+	for i, sib := range siblings {
+		if sib.UniqueID == groupID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("qlab: ungroup %s: %w", groupID, ErrNotFound)
+	}
+	children, err := c.CueChildren(ctx, workspaceID, groupID)
+	if err != nil {
+		return err
+	}
+	for i, child := range children {
+		if err := c.MoveCue(ctx, workspaceID, child.UniqueID, parentID, index+i); err != nil {
+			return err
+		}
+	}
+	return c.DeleteCue(ctx, workspaceID, groupID)
+}
@@ -0,0 +1,165 @@
+package qlab
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNewCue(t *testing.T) {
+	mock, client := setupCueTest(t)
+	ctx := t.Context()
+
+	memo, err := client.NewCue(ctx, "ws-1", "memo", "cue-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	group, err := client.NewCue(ctx, "ws-1", "group", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if memo == "" || group == "" || memo == group {
+		t.Fatalf("bad unique IDs %q %q", memo, group)
+	}
+
+	children, err := client.CueChildren(ctx, "ws-1", "list-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"cue-1", memo, group}; !slices.Equal(childIDs(children), want) {
+		t.Errorf("got %v, want %v", childIDs(children), want)
+	}
+	if children[1].Type != "Memo" || children[2].Type != "Group" {
+		t.Errorf("got types %q %q, want Memo Group", children[1].Type, children[2].Type)
+	}
+
+	selected, err := client.SelectedCues(ctx, "ws-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(selected) != 1 || selected[0].UniqueID != group {
+		t.Errorf("new cue not selected: %v", childIDs(selected))
+	}
+
+	if _, ok := mock.FindCue("ws-1", memo); !ok {
+		t.Error("memo cue missing from mock tree")
+	}
+}
+
+func TestNewCueEmptyWorkspace(t *testing.T) {
+	mock, client := setupTest(t)
+
+	id, err := client.NewCue(t.Context(), "ws-1", "memo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lists, err := client.CueLists(t.Context(), "ws-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 1 || len(lists[0].Cues) != 1 || lists[0].Cues[0].UniqueID != id {
+		t.Errorf("got %+v, want one list holding %s", lists, id)
+	}
+	if _, ok := mock.FindCue("ws-1", id); !ok {
+		t.Error("cue missing from mock tree")
+	}
+}
+
+func TestDeleteCue(t *testing.T) {
+	mock, client := setupCueTest(t)
+
+	if err := client.DeleteCue(t.Context(), "ws-1", "cue-1"); err != nil {
+		t.Fatal(err)
+	}
+	children, err := client.CueChildren(t.Context(), "ws-1", "list-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(children) != 0 {
+		t.Errorf("got %v, want no children", childIDs(children))
+	}
+	if _, ok := mock.FindCue("ws-1", "cue-1"); ok {
+		t.Error("deleted cue still in mock tree")
+	}
+}
+
+func TestMoveCue(t *testing.T) {
+	_, client := setupCueTest(t)
+	ctx := t.Context()
+
+	a, _ := client.NewCue(ctx, "ws-1", "memo", "cue-1")
+	group, _ := client.NewCue(ctx, "ws-1", "group", a)
+
+	if err := client.MoveCue(ctx, "ws-1", "cue-1", group, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.MoveCue(ctx, "ws-1", a, group, 99); err != nil {
+		t.Fatal(err)
+	}
+
+	children, err := client.CueChildren(ctx, "ws-1", group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"cue-1", a}; !slices.Equal(childIDs(children), want) {
+		t.Errorf("got %v, want %v", childIDs(children), want)
+	}
+
+	parent, err := client.CueParent(ctx, "ws-1", "cue-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent != group {
+		t.Errorf("got parent %q, want %q", parent, group)
+	}
+
+	if err := client.MoveCue(ctx, "ws-1", group, "cue-1", 0); err != nil {
+		t.Fatal(err)
+	}
+	top, err := client.CueChildren(ctx, "ws-1", "list-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{group}; !slices.Equal(childIDs(top), want) {
+		t.Errorf("moving a group into its own child changed the tree: %v", childIDs(top))
+	}
+}
+
+func TestGroupUngroup(t *testing.T) {
+	_, client := setupCueTest(t)
+	ctx := t.Context()
+
+	a, _ := client.NewCue(ctx, "ws-1", "memo", "cue-1")
+	b, _ := client.NewCue(ctx, "ws-1", "memo", a)
+	c, _ := client.NewCue(ctx, "ws-1", "memo", b)
+
+	group, err := client.GroupCues(ctx, "ws-1", []string{a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	top, err := client.CueChildren(ctx, "ws-1", "list-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"cue-1", group, c}; !slices.Equal(childIDs(top), want) {
+		t.Errorf("got %v, want %v", childIDs(top), want)
+	}
+	inner, err := client.CueChildren(ctx, "ws-1", group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{a, b}; !slices.Equal(childIDs(inner), want) {
+		t.Errorf("got %v, want %v", childIDs(inner), want)
+	}
+
+	if err := client.UngroupCue(ctx, "ws-1", group); err != nil {
+		t.Fatal(err)
+	}
+	top, err = client.CueChildren(ctx, "ws-1", "list-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"cue-1", a, b, c}; !slices.Equal(childIDs(top), want) {
+		t.Errorf("got %v, want %v", childIDs(top), want)
+	}
+}
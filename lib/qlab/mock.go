@@ -2,19 +2,57 @@ package qlab
 
 import (
 	"encoding/json"
+	"fmt"
 	"net"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
+
+	"qrun/lib/osc"
 )
 
+type MockRequest struct {
+	Address string
+	Args    []any
+	Bundled bool
+}
+
 type MockServer struct {
+	addr     string
 	listener net.Listener
 	mu       sync.Mutex
 	conns    []net.Conn
+	requests []MockRequest
+
+	thumpDelay time.Duration
+	dropThumps bool
+	props      map[string]map[string]any
+	selected   map[string]string
+	nextID     int
+
+	Version       string
+	Workspaces    []Workspace
+	CueLists      map[string][]Cue
+	PanicDuration time.Duration
+
+	// Passcodes maps a workspace ID to the permissions each passcode grants;
+	// the empty passcode covers connecting without one. Other passcodes are
+	// refused with "badpass", and messages a connection is not permitted to
+	// send are ignored. Workspaces without an entry grant full access.
+	Passcodes map[string]map[string]Permissions
+
+	// MaxDatagram clips UDP replies, standing in for replies that do not fit
+	// in a datagram.
+	MaxDatagram int
 
-	Version    string
-	Workspaces []Workspace
-	CueLists   map[string][]Cue
+	udp      *net.UDPConn
+	udpPeers map[string]*udpPeer
+
+	simState    *simState
+	updateConns map[net.Conn]map[string]bool
+	grants      map[net.Conn]map[string]Permissions
 }
 
 func NewMockServer() (*MockServer, error) {
@@ -22,43 +60,125 @@ func NewMockServer() (*MockServer, error) {
 	if err != nil {
 		return nil, err
 	}
+	udp, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
 	m := &MockServer{
-		listener:   ln,
-		Version:    "5.0.0",
-		Workspaces: []Workspace{},
-		CueLists:   make(map[string][]Cue),
+		addr:          ln.Addr().String(),
+		listener:      ln,
+		udp:           udp,
+		MaxDatagram:   maxDatagram,
+		Version:       "5.0.0",
+		Workspaces:    []Workspace{},
+		CueLists:      make(map[string][]Cue),
+		PanicDuration: DefaultPanicDuration,
+		Passcodes:     make(map[string]map[string]Permissions),
 	}
-	go m.serve()
+	go m.serve(ln)
+	go m.serveUDP(udp)
 	return m, nil
 }
 
 func (m *MockServer) Port() int {
-	return m.listener.Addr().(*net.TCPAddr).Port
+	_, port, _ := net.SplitHostPort(m.addr)
+	n, _ := strconv.Atoi(port)
+	return n
+}
+
+// UDPPort is the port the server accepts OSC datagrams on.
+func (m *MockServer) UDPPort() int {
+	return m.udp.LocalAddr().(*net.UDPAddr).Port
 }
 
 func (m *MockServer) Close() error {
-	err := m.listener.Close()
 	m.mu.Lock()
+	ln := m.listener
+	udp := m.udp
+	m.mu.Unlock()
+	err := ln.Close()
+	udp.Close()
+	m.DropConnections()
+	return err
+}
+
+// Restart closes the listener and all connections, then listens again on the
+// same address. Workspaces and cue lists are preserved.
+func (m *MockServer) Restart() error {
+	m.Close()
+	ln, err := net.Listen("tcp", m.addr)
+	if err != nil {
+		return err
+	}
+	udp, err := net.ListenUDP("udp", m.udp.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		ln.Close()
+		return err
+	}
+	m.mu.Lock()
+	m.listener = ln
+	m.udp = udp
+	m.mu.Unlock()
+	go m.serve(ln)
+	go m.serveUDP(udp)
+	return nil
+}
+
+func (m *MockServer) DropConnections() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	for _, conn := range m.conns {
 		conn.Close()
 	}
-	m.mu.Unlock()
-	return err
+	m.conns = nil
+	m.udpPeers = nil
+	m.updateConns = nil
+	m.grants = nil
+}
+
+// Requests returns every message received since the last ResetRequests.
+func (m *MockServer) Requests() []MockRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]MockRequest(nil), m.requests...)
+}
+
+// SetThumpDelay delays replies to /thump to simulate a slow Qlab.
+func (m *MockServer) SetThumpDelay(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.thumpDelay = d
+}
+
+// SetDropThumps makes the server ignore /thump requests entirely.
+func (m *MockServer) SetDropThumps(drop bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dropThumps = drop
 }
 
-func (m *MockServer) SendUpdate(addr string) {
+func (m *MockServer) ResetRequests() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	msg := buildOSC(addr)
-	encoded := slipEncode(msg)
+	m.requests = nil
+}
+
+func (m *MockServer) SendUpdate(addr string, args ...any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	msg := osc.Message{Address: addr, Args: args}
 	for _, conn := range m.conns {
-		conn.Write(encoded)
+		writePacket(conn, msg)
+	}
+	for _, peer := range m.udpPeers {
+		writePacket(peer, msg)
 	}
 }
 
-func (m *MockServer) serve() {
+func (m *MockServer) serve(ln net.Listener) {
 	for {
-		conn, err := m.listener.Accept()
+		conn, err := ln.Accept()
 		if err != nil {
 			return
 		}
@@ -69,7 +189,21 @@ func (m *MockServer) serve() {
 	}
 }
 
+func (m *MockServer) removeConn(conn net.Conn) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.updateConns, conn)
+	for i, c := range m.conns {
+		if c == conn {
+			m.conns = append(m.conns[:i], m.conns[i+1:]...)
+			return
+		}
+	}
+}
+
 func (m *MockServer) handleConn(conn net.Conn) {
+	defer m.removeConn(conn)
+	defer conn.Close()
 	buf := make([]byte, 0, 65536)
 	tmp := make([]byte, 4096)
 	for {
@@ -79,16 +213,12 @@ func (m *MockServer) handleConn(conn net.Conn) {
 		}
 		buf = append(buf, tmp[:n]...)
 		for {
-			frame, rest, ok := extractSLIPFrame(buf)
+			frame, rest, ok := osc.NextSLIPFrame(buf)
 			if !ok {
 				break
 			}
 			buf = rest
-			addr, args, err := parseOSC(frame)
-			if err != nil {
-				continue
-			}
-			m.handleRequest(conn, addr, args)
+			m.handlePacket(conn, frame)
 		}
 	}
 }
@@ -102,14 +232,79 @@ func (m *MockServer) sendReply(conn net.Conn, addr string, wsID string, status s
 		Data:        json.RawMessage(jsonData),
 	}
 	replyJSON, _ := json.Marshal(r)
-	msg := buildOSC("/reply"+addr, string(replyJSON))
-	conn.Write(slipEncode(msg))
+	writePacket(conn, osc.Message{Address: "/reply" + addr, Args: []any{string(replyJSON)}})
+}
+
+func writePacket(conn net.Conn, msg osc.Message) {
+	data, err := msg.MarshalBinary()
+	if err != nil {
+		return
+	}
+	if _, ok := conn.(*udpPeer); ok {
+		conn.Write(data)
+		return
+	}
+	conn.Write(osc.SLIPEncode(data))
+}
+
+// udpPeer stands in for a connection so UDP clients share the TCP request
+// handling. Only Write and Close are used.
+type udpPeer struct {
+	net.Conn
+	m    *MockServer
+	pc   *net.UDPConn
+	addr *net.UDPAddr
+}
+
+func (p *udpPeer) Write(b []byte) (int, error) {
+	if len(b) > p.m.MaxDatagram {
+		b = b[:p.m.MaxDatagram]
+	}
+	return p.pc.WriteToUDP(b, p.addr)
+}
+
+func (p *udpPeer) Close() error {
+	return nil
+}
+
+func (m *MockServer) serveUDP(pc *net.UDPConn) {
+	buf := make([]byte, 65536)
+	for {
+		n, from, err := pc.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		m.mu.Lock()
+		if m.udpPeers == nil {
+			m.udpPeers = make(map[string]*udpPeer)
+		}
+		peer := m.udpPeers[from.String()]
+		if peer == nil {
+			peer = &udpPeer{m: m, pc: pc, addr: from}
+			m.udpPeers[from.String()] = peer
+		}
+		m.mu.Unlock()
+		m.handlePacket(peer, buf[:n])
+	}
 }
 
-func (m *MockServer) handleRequest(conn net.Conn, addr string, args []any) {
+// handlePacket handles a message, or every message of a bundle in order
+// under one lock. Timetags are ignored; bundles are applied on arrival.
+func (m *MockServer) handlePacket(conn net.Conn, data []byte) {
+	p, err := osc.Parse(data)
+	if err != nil {
+		return
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	_, bundled := p.(osc.Bundle)
+	for _, msg := range osc.Messages(p) {
+		m.requests = append(m.requests, MockRequest{Address: msg.Address, Args: msg.Args, Bundled: bundled})
+		m.handleRequestLocked(conn, msg.Address, msg.Args)
+	}
+}
 
+func (m *MockServer) handleRequestLocked(conn net.Conn, addr string, args []any) {
 	switch {
 	case addr == "/version":
 		m.sendReply(conn, addr, "", "ok", m.Version)
@@ -126,9 +321,30 @@ func (m *MockServer) handleRequest(conn net.Conn, addr string, args []any) {
 	wsID := parts[2]
 	rest := strings.Join(parts[3:], "/")
 
+	if rest == "connect" {
+		m.connectLocked(conn, addr, wsID, args)
+		return
+	}
+	if !m.permittedLocked(conn, wsID, rest, args) {
+		return
+	}
+
 	switch {
-	case rest == "connect":
-		m.sendReply(conn, addr, wsID, "ok", "ok")
+	case rest == "updates":
+		m.setUpdatesLocked(conn, wsID, len(args) > 0 && mockNumber(args[0]) != 0)
+	case m.workspaceCommand(wsID, rest, args):
+		m.sendReply(conn, addr, wsID, "ok", nil)
+	case rest == "thump":
+		if m.dropThumps {
+			return
+		}
+		if m.thumpDelay > 0 {
+			time.AfterFunc(m.thumpDelay, func() {
+				m.sendReply(conn, addr, wsID, "ok", "thump")
+			})
+			return
+		}
+		m.sendReply(conn, addr, wsID, "ok", "thump")
 	case rest == "cueLists":
 		cues := m.CueLists[wsID]
 		if cues == nil {
@@ -136,9 +352,42 @@ func (m *MockServer) handleRequest(conn net.Conn, addr string, args []any) {
 		}
 		m.sendReply(conn, addr, wsID, "ok", cues)
 	case rest == "selectedCues":
-		m.sendReply(conn, addr, wsID, "ok", []Cue{})
+		cues := []Cue{}
+		if cue := m.findCueByID(wsID, m.selected[wsID]); cue != nil {
+			cues = append(cues, *cue)
+		}
+		m.sendReply(conn, addr, wsID, "ok", cues)
+	case rest == "new":
+		if len(args) == 0 {
+			m.sendReply(conn, addr, wsID, "error", nil)
+			return
+		}
+		cueType, _ := args[0].(string)
+		m.sendReply(conn, addr, wsID, "ok", m.newCue(wsID, cueType))
+	case strings.HasPrefix(rest, "select_id/"):
+		id := strings.TrimPrefix(rest, "select_id/")
+		if m.findCueByID(wsID, id) == nil {
+			m.sendReply(conn, addr, wsID, "not found", nil)
+			return
+		}
+		m.selectCue(wsID, id)
+	case strings.HasPrefix(rest, "delete_id/"):
+		if !m.deleteCue(wsID, strings.TrimPrefix(rest, "delete_id/")) {
+			m.sendReply(conn, addr, wsID, "not found", nil)
+		}
+	case strings.HasPrefix(rest, "move/"):
+		if len(args) < 2 {
+			m.sendReply(conn, addr, wsID, "error", nil)
+			return
+		}
+		parentID, _ := args[1].(string)
+		if !m.moveCue(wsID, strings.TrimPrefix(rest, "move/"), parentID, int(mockNumber(args[0]))) {
+			m.sendReply(conn, addr, wsID, "not found", nil)
+		}
 	case rest == "runningCues":
-		m.sendReply(conn, addr, wsID, "ok", []Cue{})
+		m.sendReply(conn, addr, wsID, "ok", m.runningCuesLocked(wsID, false))
+	case rest == "runningOrPausedCues":
+		m.sendReply(conn, addr, wsID, "ok", m.runningCuesLocked(wsID, true))
 	case strings.HasPrefix(rest, "cue_id/"):
 		sub := strings.SplitN(rest, "/", 3)
 		if len(sub) < 3 {
@@ -149,10 +398,13 @@ func (m *MockServer) handleRequest(conn net.Conn, addr string, args []any) {
 			m.sendReply(conn, addr, wsID, "not found", nil)
 			return
 		}
-		if len(args) > 0 {
-			m.setCueProperty(cue, sub[2], args[0])
-		} else {
-			m.sendReply(conn, addr, wsID, "ok", m.getCueProperty(cue, sub[2]))
+		switch {
+		case m.cueCommand(wsID, cue, sub[2], args):
+			m.sendReply(conn, addr, wsID, "ok", nil)
+		case len(args) > 0:
+			m.setCueProperty(wsID, cue, sub[2], args)
+		default:
+			m.sendReply(conn, addr, wsID, "ok", m.getCueProperty(wsID, cue, sub[2]))
 		}
 	case strings.HasPrefix(rest, "cue/"):
 		sub := strings.SplitN(rest, "/", 3)
@@ -164,16 +416,216 @@ func (m *MockServer) handleRequest(conn net.Conn, addr string, args []any) {
 			m.sendReply(conn, addr, wsID, "not found", nil)
 			return
 		}
-		if len(args) > 0 {
-			m.setCueProperty(cue, sub[2], args[0])
-		} else {
-			m.sendReply(conn, addr, wsID, "ok", m.getCueProperty(cue, sub[2]))
+		switch {
+		case m.cueCommand(wsID, cue, sub[2], args):
+			m.sendReply(conn, addr, wsID, "ok", nil)
+		case len(args) > 0:
+			m.setCueProperty(wsID, cue, sub[2], args)
+		default:
+			m.sendReply(conn, addr, wsID, "ok", m.getCueProperty(wsID, cue, sub[2]))
 		}
 	default:
 		m.sendReply(conn, addr, wsID, "ok", nil)
 	}
 }
 
+func (m *MockServer) connectLocked(conn net.Conn, addr string, wsID string, args []any) {
+	perms := FullAccess
+	if codes, ok := m.Passcodes[wsID]; ok {
+		var passcode string
+		if len(args) > 0 {
+			passcode, _ = args[0].(string)
+		}
+		if perms, ok = codes[passcode]; !ok {
+			m.sendReply(conn, addr, wsID, "ok", "badpass")
+			return
+		}
+	}
+	if m.grants == nil {
+		m.grants = make(map[net.Conn]map[string]Permissions)
+	}
+	if m.grants[conn] == nil {
+		m.grants[conn] = make(map[string]Permissions)
+	}
+	m.grants[conn][wsID] = perms
+	m.sendReply(conn, addr, wsID, "ok", "ok:"+perms.String())
+}
+
+func (m *MockServer) permittedLocked(conn net.Conn, wsID string, rest string, args []any) bool {
+	if _, ok := m.Passcodes[wsID]; !ok {
+		return true
+	}
+	granted, ok := m.grants[conn][wsID]
+	return ok && granted.Allows(requiredPermissions(rest, args))
+}
+
+var mockCueTypes = map[string]string{
+	"midi":     "MIDI",
+	"midifile": "MIDI File",
+	"cuelist":  "Cue List",
+	"cuecart":  "Cue Cart",
+	"goto":     "GoTo",
+	"devamp":   "Devamp",
+	"timecode": "Timecode",
+}
+
+func mockCueType(t string) string {
+	t = strings.ToLower(t)
+	if name, ok := mockCueTypes[t]; ok {
+		return name
+	}
+	if t == "" {
+		return t
+	}
+	return strings.ToUpper(t[:1]) + t[1:]
+}
+
+// FindCue returns a copy of the cue with the given unique ID.
+func (m *MockServer) FindCue(wsID, cueID string) (Cue, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if cue := m.findCueByID(wsID, cueID); cue != nil {
+		return *cue, true
+	}
+	return Cue{}, false
+}
+
+func (m *MockServer) newID() string {
+	m.nextID++
+	return fmt.Sprintf("mock-%d", m.nextID)
+}
+
+func (m *MockServer) selectCue(wsID, cueID string) {
+	if m.selected == nil {
+		m.selected = make(map[string]string)
+	}
+	m.selected[wsID] = cueID
+}
+
+// newCue inserts after the selected cue, or at the end of the first cue list
+// when nothing is selected, and selects the new cue.
+func (m *MockServer) newCue(wsID, cueType string) string {
+	cue := Cue{UniqueID: m.newID(), Type: mockCueType(cueType)}
+
+	if siblings, index, ok := m.locateCue(wsID, m.selected[wsID]); ok && index >= 0 {
+		*siblings = slices.Insert(*siblings, index+1, cue)
+	} else {
+		if len(m.CueLists[wsID]) == 0 {
+			m.CueLists[wsID] = []Cue{{UniqueID: m.newID(), Name: "Main Cue List", Type: "Cue List"}}
+		}
+		list := &m.CueLists[wsID][0]
+		list.Cues = append(list.Cues, cue)
+	}
+	m.selectCue(wsID, cue.UniqueID)
+	m.cueUpdateLocked(wsID, m.parentID(wsID, cue.UniqueID))
+	return cue.UniqueID
+}
+
+func (m *MockServer) deleteCue(wsID, cueID string) bool {
+	siblings, index, ok := m.locateCue(wsID, cueID)
+	if !ok {
+		return false
+	}
+	m.stopCueLocked(wsID, cueID)
+	parentID := m.parentID(wsID, cueID)
+	if index < 0 {
+		m.CueLists[wsID] = slices.Delete(m.CueLists[wsID], -index-1, -index)
+	} else {
+		*siblings = slices.Delete(*siblings, index, index+1)
+	}
+	delete(m.props, cueID)
+	if m.selected[wsID] == cueID {
+		delete(m.selected, wsID)
+	}
+	if parentID != "" {
+		m.cueUpdateLocked(wsID, parentID)
+	}
+	return true
+}
+
+func (m *MockServer) moveCue(wsID, cueID, parentID string, index int) bool {
+	siblings, from, ok := m.locateCue(wsID, cueID)
+	if !ok || from < 0 || m.isDescendant(wsID, cueID, parentID) {
+		return false
+	}
+	cue := (*siblings)[from]
+	oldParentID := m.parentID(wsID, cueID)
+	*siblings = slices.Delete(*siblings, from, from+1)
+
+	parent := m.findCueByID(wsID, parentID)
+	if parent == nil {
+		*siblings = slices.Insert(*siblings, from, cue)
+		return false
+	}
+	index = max(0, min(index, len(parent.Cues)))
+	parent.Cues = slices.Insert(parent.Cues, index, cue)
+	m.cueUpdateLocked(wsID, oldParentID)
+	if parentID != oldParentID {
+		m.cueUpdateLocked(wsID, parentID)
+	}
+	return true
+}
+
+func (m *MockServer) isDescendant(wsID, ancestorID, cueID string) bool {
+	ancestor := m.findCueByID(wsID, ancestorID)
+	if ancestor == nil {
+		return false
+	}
+	return ancestor.UniqueID == cueID || findCueInList(ancestor.Cues, cueID, func(c *Cue) string { return c.UniqueID }) != nil
+}
+
+// locateCue returns the slice holding a cue and its index in it. Cue lists
+// themselves live in m.CueLists and are reported with a negative index
+// (-i-1) since they have no parent cue.
+func (m *MockServer) locateCue(wsID, cueID string) (*[]Cue, int, bool) {
+	if cueID == "" {
+		return nil, 0, false
+	}
+	lists := m.CueLists[wsID]
+	for i := range lists {
+		if lists[i].UniqueID == cueID {
+			return nil, -i - 1, true
+		}
+		if siblings, index, ok := locateInChildren(&lists[i].Cues, cueID); ok {
+			return siblings, index, true
+		}
+	}
+	return nil, 0, false
+}
+
+func locateInChildren(cues *[]Cue, cueID string) (*[]Cue, int, bool) {
+	for i := range *cues {
+		if (*cues)[i].UniqueID == cueID {
+			return cues, i, true
+		}
+		if siblings, index, ok := locateInChildren(&(*cues)[i].Cues, cueID); ok {
+			return siblings, index, true
+		}
+	}
+	return nil, 0, false
+}
+
+func (m *MockServer) parentID(wsID, cueID string) string {
+	for _, list := range m.CueLists[wsID] {
+		if id, ok := parentInChildren(list, cueID); ok {
+			return id
+		}
+	}
+	return ""
+}
+
+func parentInChildren(parent Cue, cueID string) (string, bool) {
+	for _, c := range parent.Cues {
+		if c.UniqueID == cueID {
+			return parent.UniqueID, true
+		}
+		if id, ok := parentInChildren(c, cueID); ok {
+			return id, true
+		}
+	}
+	return "", false
+}
+
 func (m *MockServer) findCueByID(wsID, cueID string) *Cue {
 	return findCueInList(m.CueLists[wsID], cueID, func(c *Cue) string { return c.UniqueID })
 }
@@ -194,7 +646,51 @@ func findCueInList(cues []Cue, val string, key func(*Cue) string) *Cue {
 	return nil
 }
 
-func (m *MockServer) getCueProperty(cue *Cue, prop string) any {
+var mockPropertyDefaults = map[string]any{
+	"notes":                 "",
+	"fileTarget":            "",
+	"cueTargetID":           "",
+	"preWait":               0.0,
+	"postWait":              0.0,
+	"duration":              0.0,
+	"fadeAndStopOthersTime": 0.0,
+	"continueMode":          0,
+	"fadeAndStopOthers":     0,
+	"stopTargetWhenDone":    false,
+	"infiniteLoop":          false,
+	"mode":                  int(GroupStartAll),
+	"levels":                [][]float64{},
+}
+
+// CueProperty returns a stored property in its JSON form (string, float64,
+// bool or [][]float64), or its default if it was never set.
+func (m *MockServer) CueProperty(cueID string, prop string) any {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v, ok := m.props[cueID][prop]; ok {
+		return v
+	}
+	return mockPropertyDefaults[prop]
+}
+
+// SetCueProperty stores a property that is not part of Cue, bypassing OSC.
+func (m *MockServer) SetCueProperty(cueID string, prop string, val any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.storeProp(cueID, prop, val)
+}
+
+func (m *MockServer) storeProp(cueID string, prop string, val any) {
+	if m.props == nil {
+		m.props = make(map[string]map[string]any)
+	}
+	if m.props[cueID] == nil {
+		m.props[cueID] = make(map[string]any)
+	}
+	m.props[cueID][prop] = val
+}
+
+func (m *MockServer) getCueProperty(wsID string, cue *Cue, prop string) any {
 	switch prop {
 	case "uniqueID":
 		return cue.UniqueID
@@ -212,14 +708,30 @@ func (m *MockServer) getCueProperty(cue *Cue, prop string) any {
 		return cue.Armed
 	case "listName":
 		return cue.ListName
-	default:
-		return nil
+	case "children":
+		if cue.Cues == nil {
+			return []Cue{}
+		}
+		return cue.Cues
+	case "parent":
+		return m.parentID(wsID, cue.UniqueID)
 	}
+	if v, ok := m.simProperty(wsID, cue, prop); ok {
+		return v
+	}
+	if v, ok := m.props[cue.UniqueID][prop]; ok {
+		return v
+	}
+	return mockPropertyDefaults[prop]
 }
 
-func (m *MockServer) setCueProperty(cue *Cue, prop string, val any) {
-	str, _ := val.(string)
+func (m *MockServer) setCueProperty(wsID string, cue *Cue, prop string, args []any) {
+	str, _ := args[0].(string)
 	switch prop {
+	case "playbackPositionId":
+		if cue.Type == "Cue List" {
+			m.setPlayheadLocked(wsID, cue.UniqueID, str)
+		}
 	case "name":
 		cue.Name = str
 	case "number":
@@ -228,5 +740,56 @@ func (m *MockServer) setCueProperty(cue *Cue, prop string, val any) {
 		cue.ColorName = str
 	case "listName":
 		cue.ListName = str
+	case "flagged":
+		cue.Flagged = mockNumber(args[0]) != 0
+	case "armed":
+		cue.Armed = mockNumber(args[0]) != 0
+	case "notes", "fileTarget", "cueTargetID":
+		m.storeProp(cue.UniqueID, prop, str)
+	case "preWait", "postWait", "duration", "fadeAndStopOthersTime":
+		m.storeProp(cue.UniqueID, prop, mockNumber(args[0]))
+	case "continueMode", "fadeAndStopOthers":
+		m.storeProp(cue.UniqueID, prop, int(mockNumber(args[0])))
+	case "stopTargetWhenDone", "infiniteLoop":
+		m.storeProp(cue.UniqueID, prop, mockNumber(args[0]) != 0)
+	case "mode":
+		m.storeProp(cue.UniqueID, prop, int(mockNumber(args[0])))
+	case "setLevel":
+		if len(args) < 3 {
+			return
+		}
+		row, col := int(mockNumber(args[0])), int(mockNumber(args[1]))
+		levels, _ := m.props[cue.UniqueID]["levels"].([][]float64)
+		for len(levels) <= row {
+			levels = append(levels, nil)
+		}
+		for len(levels[row]) <= col {
+			levels[row] = append(levels[row], 0)
+		}
+		levels[row][col] = mockNumber(args[2])
+		m.storeProp(cue.UniqueID, "levels", levels)
+	}
+}
+
+// mockNumber rounds float32 arguments through their decimal form so that
+// values like 0.1 read back as sent rather than as 0.10000000149.
+func mockNumber(arg any) float64 {
+	switch v := arg.(type) {
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float32:
+		f, _ := strconv.ParseFloat(strconv.FormatFloat(float64(v), 'g', -1, 32), 64)
+		return f
+	case float64:
+		return v
+	case string:
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	default:
+		return 0
 	}
 }
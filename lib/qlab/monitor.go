@@ -0,0 +1,160 @@
+package qlab
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultThumpInterval = 1 * time.Second
+	DefaultThumpTimeout  = 500 * time.Millisecond
+	DefaultMaxMisses     = 2
+)
+
+type LatencyStats struct {
+	Last    time.Duration `json:"last"`
+	Min     time.Duration `json:"min"`
+	Max     time.Duration `json:"max"`
+	Mean    time.Duration `json:"mean"`
+	Samples int           `json:"samples"`
+	Misses  int           `json:"misses"`
+
+	total time.Duration
+}
+
+func (s *LatencyStats) add(d time.Duration) {
+	s.Last = d
+	if s.Samples == 0 || d < s.Min {
+		s.Min = d
+	}
+	if d > s.Max {
+		s.Max = d
+	}
+	s.Samples++
+	s.total += d
+	s.Mean = s.total / time.Duration(s.Samples)
+	s.Misses = 0
+}
+
+type Liveness struct {
+	WorkspaceID string       `json:"workspace_id"`
+	Alive       bool         `json:"alive"`
+	LastSeen    time.Time    `json:"last_seen"`
+	Latency     LatencyStats `json:"latency"`
+}
+
+// Monitor thumps every connected workspace on an interval. A workspace goes
+// stale after MaxMisses consecutive thumps fail or exceed Timeout, and alive
+// again on the next timely reply.
+type Monitor struct {
+	Interval  time.Duration
+	Timeout   time.Duration
+	MaxMisses int
+
+	client *Client
+	mu     sync.Mutex
+	status map[string]*Liveness
+	events chan Liveness
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+func NewMonitor(client *Client) *Monitor {
+	return &Monitor{
+		Interval:  DefaultThumpInterval,
+		Timeout:   DefaultThumpTimeout,
+		MaxMisses: DefaultMaxMisses,
+		client:    client,
+		status:    make(map[string]*Liveness),
+		events:    make(chan Liveness, 16),
+	}
+}
+
+func (m *Monitor) Start() {
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	go m.run()
+}
+
+func (m *Monitor) Stop() {
+	close(m.stop)
+	<-m.done
+}
+
+// Events delivers alive/stale transitions. Slow readers may miss
+// transitions; Status always reports the current state.
+func (m *Monitor) Events() <-chan Liveness {
+	return m.events
+}
+
+func (m *Monitor) Status(workspaceID string) (Liveness, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l := m.status[workspaceID]
+	if l == nil {
+		return Liveness{WorkspaceID: workspaceID}, false
+	}
+	return *l, true
+}
+
+func (m *Monitor) run() {
+	defer close(m.done)
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+	for {
+		m.thumpAll()
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Monitor) thumpAll() {
+	var wg sync.WaitGroup
+	for _, id := range m.client.connectedWorkspaces() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.thump(id)
+		}()
+	}
+	wg.Wait()
+}
+
+func (m *Monitor) thump(workspaceID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.Timeout)
+	defer cancel()
+	start := time.Now()
+	_, err := m.client.request(ctx, thumpAddr(workspaceID))
+	latency := time.Since(start)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l := m.status[workspaceID]
+	if l == nil {
+		l = &Liveness{WorkspaceID: workspaceID}
+		m.status[workspaceID] = l
+	}
+	wasAlive := l.Alive
+
+	if err != nil {
+		l.Latency.Misses++
+		if l.Latency.Misses >= m.MaxMisses {
+			l.Alive = false
+		}
+	} else {
+		l.Latency.add(latency)
+		l.LastSeen = start.Add(latency)
+		l.Alive = true
+	}
+
+	if l.Alive != wasAlive {
+		select {
+		case m.events <- *l:
+		default:
+		}
+	}
+}
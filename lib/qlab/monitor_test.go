@@ -0,0 +1,145 @@
+package qlab
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func setupMonitor(t *testing.T, setup func(*testing.T) (*MockServer, *Client)) (*MockServer, *Monitor) {
+	t.Helper()
+	mock, client := setup(t)
+	if _, err := client.Connect(t.Context(), "ws-1", ""); err != nil {
+		t.Fatal(err)
+	}
+	mon := NewMonitor(client)
+	mon.Interval = 10 * time.Millisecond
+	mon.Timeout = 50 * time.Millisecond
+	mon.MaxMisses = 2
+	mon.Start()
+	t.Cleanup(mon.Stop)
+	return mock, mon
+}
+
+func waitLiveness(t *testing.T, mon *Monitor, alive bool) Liveness {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case l := <-mon.Events():
+			if l.WorkspaceID != "ws-1" {
+				t.Fatalf("got workspace %q, want %q", l.WorkspaceID, "ws-1")
+			}
+			if l.Alive == alive {
+				return l
+			}
+		case <-deadline:
+			t.Fatalf("timeout waiting for alive=%v", alive)
+		}
+	}
+}
+
+func TestMonitorAlive(t *testing.T) {
+	mock, mon := setupMonitor(t, setupTest)
+	mock.SetThumpDelay(5 * time.Millisecond)
+
+	waitLiveness(t, mon, true)
+	time.Sleep(100 * time.Millisecond)
+
+	l, ok := mon.Status("ws-1")
+	if !ok {
+		t.Fatal("no status for ws-1")
+	}
+	if !l.Alive {
+		t.Error("expected alive")
+	}
+	if l.Latency.Samples < 2 {
+		t.Errorf("got %d samples, want at least 2", l.Latency.Samples)
+	}
+	if l.Latency.Max < 5*time.Millisecond {
+		t.Errorf("max latency %v below simulated delay", l.Latency.Max)
+	}
+	if l.Latency.Min > l.Latency.Mean || l.Latency.Mean > l.Latency.Max {
+		t.Errorf("inconsistent stats: %+v", l.Latency)
+	}
+}
+
+func TestMonitorDroppedThumps(t *testing.T) {
+	testMonitorDroppedThumps(t, setupUDPTest)
+}
+
+func TestMonitorDroppedThumpsTCP(t *testing.T) {
+	// Each dropped reply leaves a slot that a later reply would fill; the
+	// client must reconnect for thumps to be matched again.
+	testMonitorDroppedThumps(t, func(t *testing.T) (*MockServer, *Client) {
+		mock, client := setupTest(t)
+		client.Timeout = 100 * time.Millisecond
+		return mock, client
+	})
+}
+
+func testMonitorDroppedThumps(t *testing.T, setup func(*testing.T) (*MockServer, *Client)) {
+	mock, mon := setupMonitor(t, setup)
+	waitLiveness(t, mon, true)
+
+	mock.SetDropThumps(true)
+	l := waitLiveness(t, mon, false)
+	if l.Latency.Misses < mon.MaxMisses {
+		t.Errorf("went stale after %d misses, want %d", l.Latency.Misses, mon.MaxMisses)
+	}
+
+	mock.SetDropThumps(false)
+	waitLiveness(t, mon, true)
+}
+
+func TestMonitorSlowThumps(t *testing.T) {
+	mock, mon := setupMonitor(t, setupTest)
+	waitLiveness(t, mon, true)
+
+	mock.SetThumpDelay(200 * time.Millisecond)
+	waitLiveness(t, mon, false)
+
+	mock.SetThumpDelay(0)
+	waitLiveness(t, mon, true)
+}
+
+func TestMonitorRepliesSlowerThanInterval(t *testing.T) {
+	mock, mon := setupMonitor(t, setupTest)
+	waitLiveness(t, mon, true)
+
+	// Each late reply must be matched to its own thump, which timed out,
+	// never credited to a later one as a fast reply.
+	mock.SetThumpDelay(200 * time.Millisecond)
+	waitLiveness(t, mon, false)
+	deadline := time.After(500 * time.Millisecond)
+	for {
+		select {
+		case l := <-mon.Events():
+			if l.Alive {
+				t.Fatalf("went alive with latency %v while replies take 200ms", l.Latency.Last)
+			}
+			continue
+		case <-deadline:
+		}
+		break
+	}
+	if l, _ := mon.Status("ws-1"); l.Alive {
+		t.Errorf("alive with latency %v while replies take 200ms", l.Latency.Last)
+	}
+
+	mock.SetThumpDelay(0)
+	waitLiveness(t, mon, true)
+}
+
+func TestThump(t *testing.T) {
+	mock, client := setupTest(t)
+	if err := client.Thump(t.Context(), "ws-1"); err != nil {
+		t.Fatal(err)
+	}
+	mock.SetDropThumps(true)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.request(ctx, thumpAddr("ws-1")); err == nil {
+		t.Fatal("expected timeout for dropped thump")
+	}
+}
@@ -0,0 +1,145 @@
+package qlab
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"qrun/lib/osc"
+)
+
+var (
+	ErrBadPasscode      = errors.New("qlab: bad passcode")
+	ErrPermissionDenied = errors.New("qlab: permission denied")
+)
+
+// Permissions are the access levels Qlab grants a connection to a workspace.
+// View allows reading, Edit allows changing cues, and Control allows
+// running them.
+type Permissions struct {
+	View    bool
+	Edit    bool
+	Control bool
+}
+
+// FullAccess is granted by workspaces without a passcode, and by Qlab
+// versions that do not report permissions.
+var FullAccess = Permissions{View: true, Edit: true, Control: true}
+
+var (
+	needEdit    = Permissions{Edit: true}
+	needControl = Permissions{Control: true}
+)
+
+// ParsePermissions parses the "view|edit|control" list Qlab returns after
+// "ok:" in a connect reply. Unknown levels are ignored.
+func ParsePermissions(s string) Permissions {
+	var p Permissions
+	for _, level := range strings.Split(s, "|") {
+		switch level {
+		case "view":
+			p.View = true
+		case "edit":
+			p.Edit = true
+		case "control":
+			p.Control = true
+		}
+	}
+	return p
+}
+
+func (p Permissions) String() string {
+	var levels []string
+	if p.View {
+		levels = append(levels, "view")
+	}
+	if p.Edit {
+		levels = append(levels, "edit")
+	}
+	if p.Control {
+		levels = append(levels, "control")
+	}
+	return strings.Join(levels, "|")
+}
+
+func (p Permissions) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// Allows reports whether p grants every level in need.
+func (p Permissions) Allows(need Permissions) bool {
+	return (p.View || !need.View) && (p.Edit || !need.Edit) && (p.Control || !need.Control)
+}
+
+// parseConnectReply decodes the data of a connect reply: "ok:" followed by
+// the granted permissions, a bare "ok" from Qlab 4, or "badpass".
+func parseConnectReply(addr string, data json.RawMessage) (Permissions, error) {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return Permissions{}, fmt.Errorf("qlab: %s: %w", addr, err)
+	}
+	switch {
+	case s == "badpass":
+		return Permissions{}, fmt.Errorf("qlab: %s: %w", addr, ErrBadPasscode)
+	case s == "ok":
+		return FullAccess, nil
+	case strings.HasPrefix(s, "ok:"):
+		return ParsePermissions(strings.TrimPrefix(s, "ok:")), nil
+	default:
+		return Permissions{}, fmt.Errorf("qlab: %s: unexpected connect reply %q", addr, s)
+	}
+}
+
+// requiredPermissions classifies a message by the access Qlab requires to act
+// on it. rest is the address after /workspace/{id}/. Reads, selection and
+// session messages such as connect and updates need nothing beyond view.
+func requiredPermissions(rest string, args []any) Permissions {
+	switch rest {
+	case "go", "stop", "hardStop", "pause", "resume", "panic", "reset", "panicInTime":
+		return needControl
+	case "new":
+		return needEdit
+	}
+	if strings.HasPrefix(rest, "delete_id/") || strings.HasPrefix(rest, "move/") {
+		return needEdit
+	}
+	cue, ok := strings.CutPrefix(rest, "cue_id/")
+	if !ok {
+		cue, ok = strings.CutPrefix(rest, "cue/")
+	}
+	if !ok {
+		return Permissions{}
+	}
+	_, action, ok := strings.Cut(cue, "/")
+	if !ok {
+		return Permissions{}
+	}
+	switch action {
+	case "start", "go", "stop", "hardStop", "pause", "resume", "load", "loadAt", "reset", "panic", "panicInTime":
+		return needControl
+	}
+	if len(args) > 0 {
+		return needEdit
+	}
+	return Permissions{}
+}
+
+// checkPermissionsLocked refuses a message the connection to its workspace
+// is not permitted to send. Workspaces not connected through c are not
+// checked.
+func (c *Client) checkPermissionsLocked(msg osc.Message) error {
+	parts := strings.SplitN(msg.Address, "/", 4)
+	if len(parts) < 4 || parts[1] != "workspace" {
+		return nil
+	}
+	w := c.spaces[parts[2]]
+	if w == nil {
+		return nil
+	}
+	need := requiredPermissions(parts[3], msg.Args)
+	if !w.permissions.Allows(need) {
+		return fmt.Errorf("qlab: %s: %w: needs %s, have %s", msg.Address, ErrPermissionDenied, need, w.permissions)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package qlab
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParsePermissions(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Permissions
+	}{
+		{"view|edit|control", FullAccess},
+		{"view", Permissions{View: true}},
+		{"view|control", Permissions{View: true, Control: true}},
+		{"", Permissions{}},
+		{"view|sing", Permissions{View: true}},
+	}
+	for _, tt := range tests {
+		if got := ParsePermissions(tt.in); got != tt.want {
+			t.Errorf("ParsePermissions(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+	if got := (Permissions{View: true, Control: true}).String(); got != "view|control" {
+		t.Errorf("got %q, want view|control", got)
+	}
+}
+
+func TestRequiredPermissions(t *testing.T) {
+	tests := []struct {
+		rest string
+		args []any
+		want Permissions
+	}{
+		{"go", nil, needControl},
+		{"panic", nil, needControl},
+		{"cue_id/a/start", nil, needControl},
+		{"cue/1/loadAt", []any{float32(1)}, needControl},
+		{"cue_id/a/panicInTime", []any{float32(2)}, needControl},
+		{"cue_id/a/name", []any{"x"}, needEdit},
+		{"cue_id/a/name", nil, Permissions{}},
+		{"new", []any{"audio"}, needEdit},
+		{"move/a", []any{int32(0), "list-1"}, needEdit},
+		{"delete_id/a", nil, needEdit},
+		{"cueLists", nil, Permissions{}},
+		{"select_id/a", nil, Permissions{}},
+		{"updates", []any{int32(1)}, Permissions{}},
+	}
+	for _, tt := range tests {
+		if got := requiredPermissions(tt.rest, tt.args); got != tt.want {
+			t.Errorf("requiredPermissions(%q, %v) = %+v, want %+v", tt.rest, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestConnectPermissions(t *testing.T) {
+	mock, client := setupTest(t)
+
+	ws, err := client.Connect(t.Context(), "ws-1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ws.Permissions(); got != FullAccess {
+		t.Errorf("got %v, want full access", got)
+	}
+
+	mock.Passcodes["ws-2"] = map[string]Permissions{"1234": {View: true, Edit: true}}
+	ws2, err := client.Connect(t.Context(), "ws-2", "1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ws2.Permissions(); got != (Permissions{View: true, Edit: true}) {
+		t.Errorf("got %v, want view|edit", got)
+	}
+}
+
+func TestConnectBadPasscode(t *testing.T) {
+	mock, client := setupTest(t)
+	mock.Passcodes["ws-1"] = map[string]Permissions{"1234": FullAccess}
+
+	if _, err := client.Connect(t.Context(), "ws-1", "4321"); !errors.Is(err, ErrBadPasscode) {
+		t.Errorf("got %v, want ErrBadPasscode", err)
+	}
+	if _, err := client.Connect(t.Context(), "ws-1", ""); !errors.Is(err, ErrBadPasscode) {
+		t.Errorf("got %v, want ErrBadPasscode without a passcode", err)
+	}
+	if client.Workspace("ws-1") != nil {
+		t.Error("refused workspace was registered")
+	}
+}
+
+func TestViewOnlyRefusesMutations(t *testing.T) {
+	mock, client := setupTest(t)
+	mock.CueLists["ws-1"] = []Cue{{UniqueID: "list-1", Type: "Cue List", Cues: []Cue{
+		{UniqueID: "a", Number: "1", Name: "A", Type: "Audio"},
+	}}}
+	mock.Passcodes["ws-1"] = map[string]Permissions{"": {View: true}}
+
+	ws, err := client.Connect(t.Context(), "ws-1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ResetRequests()
+
+	for name, call := range map[string]func() error{
+		"Go":     func() error { return ws.Go(t.Context()) },
+		"Panic":  func() error { return ws.Panic(t.Context()) },
+		"CueSet": func() error { return ws.CueSet(t.Context(), "a", "name", "B") },
+		"SetCueProperties": func() error {
+			return SetCueProperties(t.Context(), client, "ws-1", PropName.Change("a", "B"))
+		},
+	} {
+		if err := call(); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("%s: got %v, want ErrPermissionDenied", name, err)
+		}
+	}
+	if reqs := mock.Requests(); len(reqs) != 0 {
+		t.Errorf("refused calls were sent: %v", reqs)
+	}
+
+	name, err := GetCueProperty(t.Context(), client, "ws-1", "a", PropName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "A" {
+		t.Errorf("got name %q, want A", name)
+	}
+}
+
+func TestEditWithoutControl(t *testing.T) {
+	mock, client := setupTest(t)
+	mock.CueLists["ws-1"] = []Cue{{UniqueID: "list-1", Type: "Cue List", Cues: []Cue{
+		{UniqueID: "a", Number: "1", Name: "A", Type: "Audio"},
+	}}}
+	mock.Passcodes["ws-1"] = map[string]Permissions{"edit": {View: true, Edit: true}}
+
+	ws, err := client.Connect(t.Context(), "ws-1", "edit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.CueSet(t.Context(), "a", "name", "B"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.CueStart(t.Context(), "a"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("got %v, want ErrPermissionDenied", err)
+	}
+	name, err := GetCueProperty(t.Context(), client, "ws-1", "a", PropName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "B" {
+		t.Errorf("got name %q, want B", name)
+	}
+}
+
+func TestMockIgnoresUnpermitted(t *testing.T) {
+	mock, _ := setupSim(t, Cue{UniqueID: "a", Number: "1", Type: "Audio"})
+	mock.Passcodes["ws-1"] = map[string]Permissions{"": {View: true}}
+
+	// A client that never connected does not check permissions, so its Go
+	// reaches the mock, which must ignore it.
+	other, err := Dial("127.0.0.1", mock.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if err := other.Go(t.Context(), "ws-1"); err != nil {
+		t.Fatal(err)
+	}
+	roundTrip(t, other)
+	assertRunning(t, mock)
+}
@@ -0,0 +1,205 @@
+package qlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"qrun/lib/osc"
+)
+
+type ContinueMode int32
+
+const (
+	NoContinue   ContinueMode = 0
+	AutoContinue ContinueMode = 1
+	AutoFollow   ContinueMode = 2
+)
+
+type FadeAndStopOthers int32
+
+const (
+	FadeStopNone  FadeAndStopOthers = 0
+	FadeStopPeers FadeAndStopOthers = 1
+	FadeStopList  FadeAndStopOthers = 2
+	FadeStopAll   FadeAndStopOthers = 3
+)
+
+// GroupMode values follow Qlab's numbering for the group "mode" property.
+type GroupMode int32
+
+const (
+	GroupStartFirstAndEnter GroupMode = 1
+	GroupStartFirst         GroupMode = 2
+	GroupStartAll           GroupMode = 3
+	GroupStartRandom        GroupMode = 4
+)
+
+// Levels is an audio level matrix in decibels, indexed [row][column]. Row 0
+// holds the output levels; row 0 column 0 is the main level.
+type Levels [][]float64
+
+// Property describes a cue property: the OSC name it is read from and how a
+// Go value is encoded into one or more OSC writes.
+type Property[T any] struct {
+	Name   string
+	decode func(json.RawMessage) (T, error)
+	encode func(T) []propertyWrite
+}
+
+type propertyWrite struct {
+	property string
+	args     []any
+}
+
+var (
+	PropName                  = stringProperty("name")
+	PropNumber                = stringProperty("number")
+	PropNotes                 = stringProperty("notes")
+	PropFileTarget            = stringProperty("fileTarget")
+	PropCueTargetID           = stringProperty("cueTargetID")
+	PropColorName             = stringProperty("colorName")
+	PropPreWait               = durationProperty("preWait")
+	PropPostWait              = durationProperty("postWait")
+	PropDuration              = durationProperty("duration")
+	PropFadeAndStopOthersTime = durationProperty("fadeAndStopOthersTime")
+	PropArmed                 = boolProperty("armed")
+	PropFlagged               = boolProperty("flagged")
+	PropStopTargetWhenDone    = boolProperty("stopTargetWhenDone")
+	PropInfiniteLoop          = boolProperty("infiniteLoop")
+	PropGroupMode             = intProperty[GroupMode]("mode")
+	PropContinueMode          = intProperty[ContinueMode]("continueMode")
+	PropFadeAndStopOthers     = intProperty[FadeAndStopOthers]("fadeAndStopOthers")
+	PropLevels                = Property[Levels]{
+		Name:   "levels",
+		decode: decodeJSON[Levels],
+		encode: encodeLevels,
+	}
+
+	// Runtime state. Qlab ignores writes to all but the playhead, which is
+	// read and moved on a cue list.
+	PropActionElapsed      = durationProperty("actionElapsed")
+	PropIsPanicking        = boolProperty("isPanicking")
+	PropPlaybackPositionID = stringProperty("playbackPositionId")
+)
+
+func decodeJSON[T any](data json.RawMessage) (T, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+func single(property string, arg any) []propertyWrite {
+	return []propertyWrite{{property: property, args: []any{arg}}}
+}
+
+func stringProperty(name string) Property[string] {
+	return Property[string]{
+		Name:   name,
+		decode: decodeJSON[string],
+		encode: func(v string) []propertyWrite { return single(name, v) },
+	}
+}
+
+func durationProperty(name string) Property[time.Duration] {
+	return Property[time.Duration]{
+		Name: name,
+		decode: func(data json.RawMessage) (time.Duration, error) {
+			secs, err := decodeJSON[float64](data)
+			if err != nil {
+				return 0, err
+			}
+			return time.Duration(math.Round(secs * float64(time.Second))), nil
+		},
+		encode: func(v time.Duration) []propertyWrite { return single(name, float32(v.Seconds())) },
+	}
+}
+
+func boolProperty(name string) Property[bool] {
+	return Property[bool]{
+		Name:   name,
+		decode: decodeJSON[bool],
+		encode: func(v bool) []propertyWrite {
+			if v {
+				return single(name, int32(1))
+			}
+			return single(name, int32(0))
+		},
+	}
+}
+
+func intProperty[T ~int32](name string) Property[T] {
+	return Property[T]{
+		Name:   name,
+		decode: decodeJSON[T],
+		encode: func(v T) []propertyWrite { return single(name, int32(v)) },
+	}
+}
+
+// encodeLevels writes each cell with setLevel; Qlab has no message that
+// replaces the whole matrix.
+func encodeLevels(levels Levels) []propertyWrite {
+	var writes []propertyWrite
+	for row, cols := range levels {
+		for col, db := range cols {
+			writes = append(writes, propertyWrite{
+				property: "setLevel",
+				args:     []any{int32(row), int32(col), float32(db)},
+			})
+		}
+	}
+	return writes
+}
+
+// Change is a property value bound to a cue, for SetCueProperties.
+type Change struct {
+	cueID  string
+	writes []propertyWrite
+}
+
+func (p Property[T]) Change(cueID string, v T) Change {
+	return Change{cueID: cueID, writes: p.encode(v)}
+}
+
+func GetCueProperty[T any](ctx context.Context, c *Client, workspaceID string, cueID string, p Property[T]) (T, error) {
+	var zero T
+	reply, err := c.CueGet(ctx, workspaceID, cueID, p.Name)
+	if err != nil {
+		return zero, err
+	}
+	v, err := p.decode(reply.Data)
+	if err != nil {
+		return zero, fmt.Errorf("qlab: cue %s: decode %s: %w", cueID, p.Name, err)
+	}
+	return v, nil
+}
+
+func SetCueProperty[T any](ctx context.Context, c *Client, workspaceID string, cueID string, p Property[T], v T) error {
+	for _, w := range p.encode(v) {
+		addr := fmt.Sprintf("/workspace/%s/cue_id/%s/%s", workspaceID, cueID, w.property)
+		if err := c.send(ctx, addr, w.args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetCueProperties sends every change in one OSC bundle, so Qlab applies them
+// together rather than exposing a half-updated cue to other clients.
+func SetCueProperties(ctx context.Context, c *Client, workspaceID string, changes ...Change) error {
+	b := osc.Bundle{Timetag: osc.Immediately}
+	for _, ch := range changes {
+		for _, w := range ch.writes {
+			addr := fmt.Sprintf("/workspace/%s/cue_id/%s/%s", workspaceID, ch.cueID, w.property)
+			b.Elements = append(b.Elements, osc.Message{Address: addr, Args: w.args})
+		}
+	}
+	if len(b.Elements) == 0 {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.writePacketLocked(ctx, fmt.Sprintf("/workspace/%s (bundle)", workspaceID), b)
+}
@@ -0,0 +1,139 @@
+package qlab
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func setupCueTest(t *testing.T) (*MockServer, *Client) {
+	t.Helper()
+	mock, client := setupTest(t)
+	mock.CueLists["ws-1"] = []Cue{
+		{
+			UniqueID: "list-1",
+			Type:     "Cue List",
+			Cues: []Cue{
+				{UniqueID: "cue-1", Number: "1", Name: "Lights Up", Type: "Audio"},
+			},
+		},
+	}
+	return mock, client
+}
+
+func testProperty[T any](t *testing.T, client *Client, p Property[T], v T) {
+	t.Helper()
+	if err := SetCueProperty(t.Context(), client, "ws-1", "cue-1", p, v); err != nil {
+		t.Fatalf("%s: set: %v", p.Name, err)
+	}
+	got, err := GetCueProperty(t.Context(), client, "ws-1", "cue-1", p)
+	if err != nil {
+		t.Fatalf("%s: get: %v", p.Name, err)
+	}
+	if !reflect.DeepEqual(got, v) {
+		t.Errorf("%s: got %v, want %v", p.Name, got, v)
+	}
+}
+
+func TestCueProperties(t *testing.T) {
+	_, client := setupCueTest(t)
+
+	testProperty(t, client, PropName, "Blackout")
+	testProperty(t, client, PropNumber, "1.5")
+	testProperty(t, client, PropNotes, "qrun block")
+	testProperty(t, client, PropFileTarget, "/media/storm.wav")
+	testProperty(t, client, PropCueTargetID, "cue-2")
+	testProperty(t, client, PropColorName, "red")
+	testProperty(t, client, PropPreWait, 1500*time.Millisecond)
+	testProperty(t, client, PropPostWait, 100*time.Millisecond)
+	testProperty(t, client, PropDuration, 3*time.Second)
+	testProperty(t, client, PropFadeAndStopOthersTime, 2*time.Second)
+	testProperty(t, client, PropArmed, true)
+	testProperty(t, client, PropArmed, false)
+	testProperty(t, client, PropFlagged, true)
+	testProperty(t, client, PropStopTargetWhenDone, true)
+	testProperty(t, client, PropContinueMode, AutoFollow)
+	testProperty(t, client, PropFadeAndStopOthers, FadeStopList)
+	testProperty(t, client, PropLevels, Levels{{0, -6}, {-3.5, -60}})
+}
+
+func TestCuePropertyDefaults(t *testing.T) {
+	_, client := setupCueTest(t)
+
+	mode, err := GetCueProperty(t.Context(), client, "ws-1", "cue-1", PropContinueMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode != NoContinue {
+		t.Errorf("got %v, want %v", mode, NoContinue)
+	}
+
+	notes, err := GetCueProperty(t.Context(), client, "ws-1", "cue-1", PropNotes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notes != "" {
+		t.Errorf("got %q, want empty notes", notes)
+	}
+}
+
+func TestCuePropertyMockStorage(t *testing.T) {
+	mock, client := setupCueTest(t)
+
+	mock.SetCueProperty("cue-1", "preWait", 2.5)
+	d, err := GetCueProperty(t.Context(), client, "ws-1", "cue-1", PropPreWait)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != 2500*time.Millisecond {
+		t.Errorf("got %v, want %v", d, 2500*time.Millisecond)
+	}
+
+	if err := SetCueProperty(t.Context(), client, "ws-1", "cue-1", PropNotes, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Version(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if got := mock.CueProperty("cue-1", "notes"); got != "hello" {
+		t.Errorf("got %v, want %q", got, "hello")
+	}
+}
+
+func TestSetCueProperties(t *testing.T) {
+	mock, client := setupCueTest(t)
+
+	err := SetCueProperties(t.Context(), client, "ws-1",
+		PropName.Change("cue-1", "Storm"),
+		PropPreWait.Change("cue-1", 2*time.Second),
+		PropLevels.Change("cue-1", Levels{{-6}}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name, err := GetCueProperty(t.Context(), client, "ws-1", "cue-1", PropName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "Storm" {
+		t.Errorf("got name %q, want Storm", name)
+	}
+	if got := mock.CueProperty("cue-1", "preWait"); mockNumber(got) != 2 {
+		t.Errorf("got preWait %v, want 2", got)
+	}
+
+	var bundled int
+	for _, r := range mock.Requests() {
+		if r.Bundled {
+			bundled++
+		}
+	}
+	if bundled != 3 {
+		t.Errorf("got %d bundled messages, want 3", bundled)
+	}
+
+	if err := SetCueProperties(t.Context(), client, "ws-1"); err != nil {
+		t.Errorf("empty batch: %v", err)
+	}
+}
@@ -1,21 +1,26 @@
 package qlab
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"qrun/lib/osc"
 )
 
 const (
 	DefaultPort = 53000
 
-	slipEnd    = 0xC0
-	slipEsc    = 0xDB
-	slipEscEnd = 0xDC
-	slipEscEsc = 0xDD
+	DefaultTimeout = 5 * time.Second
+
+	dialTimeout = 5 * time.Second
+	minBackoff  = 100 * time.Millisecond
+	maxBackoff  = 5 * time.Second
 )
 
 type Workspace struct {
@@ -43,118 +48,356 @@ type Reply struct {
 	Data        json.RawMessage `json:"data"`
 }
 
-type Update struct {
+var (
+	ErrDisconnected = errors.New("qlab: disconnected")
+	ErrTimeout      = errors.New("qlab: timeout")
+	ErrCanceled     = errors.New("qlab: canceled")
+	ErrNotFound     = errors.New("qlab: not found")
+	ErrTooLarge     = errors.New("qlab: message too large for a UDP datagram")
+
+	ErrWorkspaceClosed = errors.New("qlab: workspace closed")
+)
+
+// StatusError is returned when Qlab replies with a status other than "ok".
+type StatusError struct {
 	Address string
+	Status  string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("qlab: %s: %s", e.Address, e.Status)
+}
+
+func (e *StatusError) Is(target error) bool {
+	return target == ErrNotFound && e.Status == "not found"
+}
+
+func contextError(addr string, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("qlab: %s: %w: %w", addr, ErrTimeout, err)
+	}
+	return fmt.Errorf("qlab: %s: %w: %w", addr, ErrCanceled, err)
+}
+
+type ConnState int
+
+const (
+	StateConnected ConnState = iota
+	StateDisconnected
+	StateReconnecting
+	StateClosed
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateConnected:
+		return "connected"
+	case StateDisconnected:
+		return "disconnected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateClosed:
+		return "closed"
+	default:
+		return fmt.Sprintf("ConnState(%d)", int(s))
+	}
+}
+
+func (s ConnState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// waiter is a pending request. Qlab replies to each address in the order the
+// requests were sent, so waiters queue per address. A request given up on
+// keeps its place until its reply comes; ch has room for it, so it is
+// dropped unread. If the reply never comes, expireWaiter reconnects.
+type waiter struct {
+	ch  chan *Reply
+	err error
+	// first is the waiter that found the queue empty. While the head of the
+	// queue shares it, the queue has not drained since.
+	first *waiter
 }
 
 type Client struct {
+	// Timeout bounds requests whose context has no deadline.
+	Timeout time.Duration
+
+	addr    string
+	dial    func() (net.Conn, error)
+	udp     bool
 	conn    net.Conn
 	mu      sync.Mutex
-	pending map[string]chan *Reply
+	pending map[string][]*waiter
+	spaces  map[string]*WorkspaceHandle
 	idSeq   atomic.Uint64
-	updates chan Update
+	updates *Subscription
+	subMu   sync.Mutex
+	subs    map[*Subscription]struct{}
+	state   ConnState
+	states  chan ConnState
+	done    chan struct{}
 }
 
 func Dial(host string, port int) (*Client, error) {
-	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", host, port), 5*time.Second)
+	addr := net.JoinHostPort(host, fmt.Sprint(port))
+	return start(addr, false, func() (net.Conn, error) {
+		return net.DialTimeout("tcp", addr, dialTimeout)
+	})
+}
+
+func start(addr string, udp bool, dial func() (net.Conn, error)) (*Client, error) {
+	conn, err := dial()
 	if err != nil {
 		return nil, err
 	}
 	c := &Client{
+		Timeout: DefaultTimeout,
+		addr:    addr,
+		dial:    dial,
+		udp:     udp,
 		conn:    conn,
-		pending: make(map[string]chan *Reply),
-		updates: make(chan Update, 64),
+		pending: make(map[string][]*waiter),
+		spaces:  make(map[string]*WorkspaceHandle),
+		subs:    make(map[*Subscription]struct{}),
+		state:   StateConnected,
+		states:  make(chan ConnState, 16),
+		done:    make(chan struct{}),
 	}
-	go c.readLoop()
+	c.updates = c.Subscribe(64)
+	go c.supervise(conn)
 	return c, nil
 }
 
 func (c *Client) Close() error {
-	return c.conn.Close()
+	c.mu.Lock()
+	select {
+	case <-c.done:
+		c.mu.Unlock()
+		return nil
+	default:
+	}
+	close(c.done)
+	conn := c.conn
+	c.conn = nil
+	c.failPendingLocked()
+	c.setStateLocked(StateClosed)
+	for _, w := range c.spaces {
+		c.closeWorkspaceLocked(w)
+	}
+	c.mu.Unlock()
+	c.closeSubscriptions()
+	if conn == nil {
+		return nil
+	}
+	return conn.Close()
 }
 
+// Updates delivers updates from the client's built-in subscription.
 func (c *Client) Updates() <-chan Update {
-	return c.updates
+	return c.updates.C
 }
 
-func (c *Client) readLoop() {
-	buf := make([]byte, 0, 65536)
-	tmp := make([]byte, 4096)
+func (c *Client) State() ConnState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// StateChanges delivers connection state transitions. Slow readers may miss
+// intermediate states; State always reports the current one.
+func (c *Client) StateChanges() <-chan ConnState {
+	return c.states
+}
+
+func (c *Client) setStateLocked(s ConnState) {
+	if c.state == s {
+		return
+	}
+	c.state = s
+	select {
+	case c.states <- s:
+	default:
+	}
+}
+
+func (c *Client) supervise(conn net.Conn) {
 	for {
-		n, err := c.conn.Read(tmp)
-		if err != nil {
+		c.readLoop(conn)
+		if !c.dropConn(conn) {
 			return
 		}
-		buf = append(buf, tmp[:n]...)
-		for {
-			frame, rest, ok := extractSLIPFrame(buf)
-			if !ok {
-				break
+		conn = c.redial()
+		if conn == nil {
+			return
+		}
+		go c.restore(conn)
+	}
+}
+
+func (c *Client) dropConn(conn net.Conn) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	conn.Close()
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	c.conn = nil
+	c.failPendingLocked()
+	c.setStateLocked(StateDisconnected)
+	for _, w := range c.spaces {
+		w.connected = false
+	}
+	return true
+}
+
+func (c *Client) redial() net.Conn {
+	backoff := minBackoff
+	for {
+		c.mu.Lock()
+		c.setStateLocked(StateReconnecting)
+		c.mu.Unlock()
+
+		conn, err := c.dial()
+		if err == nil {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			select {
+			case <-c.done:
+				conn.Close()
+				return nil
+			default:
 			}
-			buf = rest
-			c.handleFrame(frame)
+			c.conn = conn
+			return conn
 		}
+
+		select {
+		case <-c.done:
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
 	}
 }
 
-func extractSLIPFrame(data []byte) (frame []byte, rest []byte, ok bool) {
-	start := -1
-	for i, b := range data {
-		if b == slipEnd {
-			if start == -1 {
-				start = i
-			} else {
-				raw := data[start+1 : i]
-				frame := slipDecode(raw)
-				return frame, data[i+1:], true
+// restore reconnects every open workspace after a reconnect. A workspace
+// Qlab refuses, because it was closed or its passcode changed while we were
+// away, is closed rather than retried.
+func (c *Client) restore(conn net.Conn) {
+	c.mu.Lock()
+	spaces := make([]WorkspaceHandle, 0, len(c.spaces))
+	for _, w := range c.spaces {
+		spaces = append(spaces, WorkspaceHandle{ID: w.ID, passcode: w.passcode, updates: w.updates, alwaysReply: w.alwaysReply})
+	}
+	c.mu.Unlock()
+
+	ctx := context.Background()
+	for _, s := range spaces {
+		perms, err := c.connect(ctx, s.ID, s.passcode)
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) || errors.Is(err, ErrBadPasscode) {
+			c.mu.Lock()
+			if w := c.spaces[s.ID]; w != nil {
+				c.closeWorkspaceLocked(w)
 			}
+			c.mu.Unlock()
+			continue
+		}
+		if err != nil {
+			conn.Close()
+			return
+		}
+		if s.updates {
+			c.EnableUpdates(ctx, s.ID, true)
+		}
+		if s.alwaysReply {
+			c.AlwaysReply(ctx, s.ID, true)
+		}
+		c.mu.Lock()
+		if w := c.spaces[s.ID]; w != nil && c.conn == conn {
+			w.permissions = perms
+			w.connected = true
 		}
+		c.mu.Unlock()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == conn {
+		c.setStateLocked(StateConnected)
 	}
-	return nil, data, false
 }
 
-func slipEncode(data []byte) []byte {
-	out := []byte{slipEnd}
-	for _, b := range data {
-		switch b {
-		case slipEnd:
-			out = append(out, slipEsc, slipEscEnd)
-		case slipEsc:
-			out = append(out, slipEsc, slipEscEsc)
-		default:
-			out = append(out, b)
+func (c *Client) failPendingLocked() {
+	for addr, queue := range c.pending {
+		for _, w := range queue {
+			close(w.ch)
 		}
+		delete(c.pending, addr)
+	}
+}
+
+func (c *Client) connectedWorkspaces() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ids := make([]string, 0, len(c.spaces))
+	for id := range c.spaces {
+		ids = append(ids, id)
 	}
-	out = append(out, slipEnd)
-	return out
+	return ids
 }
 
-func slipDecode(data []byte) []byte {
-	out := make([]byte, 0, len(data))
-	for i := 0; i < len(data); i++ {
-		if data[i] == slipEsc && i+1 < len(data) {
-			switch data[i+1] {
-			case slipEscEnd:
-				out = append(out, slipEnd)
-			case slipEscEsc:
-				out = append(out, slipEsc)
+func (c *Client) readLoop(conn net.Conn) {
+	if c.udp {
+		c.readDatagrams(conn)
+		return
+	}
+	buf := make([]byte, 0, 65536)
+	tmp := make([]byte, 4096)
+	for {
+		n, err := conn.Read(tmp)
+		if err != nil {
+			return
+		}
+		buf = append(buf, tmp[:n]...)
+		for {
+			frame, rest, ok := osc.NextSLIPFrame(buf)
+			if !ok {
+				break
 			}
-			i++
-		} else {
-			out = append(out, data[i])
+			buf = rest
+			c.handleFrame(frame)
 		}
 	}
-	return out
 }
 
 func (c *Client) handleFrame(frame []byte) {
-	addr, args, err := parseOSC(frame)
+	p, err := osc.Parse(frame)
 	if err != nil {
+		if c.udp {
+			c.failTruncated(frame)
+		}
 		return
 	}
+	for _, msg := range osc.Messages(p) {
+		c.handleMessage(msg.Address, msg.Args)
+	}
+}
 
+func (c *Client) handleMessage(addr string, args []any) {
 	if len(addr) > 8 && addr[:8] == "/update/" {
-		c.handleUpdate(addr)
+		u := ParseUpdate(addr, args)
+		if u.Kind == UpdateDisconnect {
+			c.mu.Lock()
+			if w := c.spaces[u.WorkspaceID]; w != nil {
+				c.closeWorkspaceLocked(w)
+			}
+			c.mu.Unlock()
+		}
+		c.publish(u)
 		return
 	}
 
@@ -166,72 +409,158 @@ func (c *Client) handleFrame(frame []byte) {
 		if !ok {
 			return
 		}
+		replyAddr := addr[6:]
 		var reply Reply
 		if err := json.Unmarshal([]byte(jsonStr), &reply); err != nil {
+			if c.udp {
+				c.failReply(replyAddr, fmt.Errorf("qlab: %s: %w", replyAddr, ErrTooLarge))
+			}
 			return
 		}
-		replyAddr := addr[6:]
 		c.mu.Lock()
-		ch, exists := c.pending[replyAddr]
-		if exists {
-			delete(c.pending, replyAddr)
-		}
+		w := c.popWaiterLocked(replyAddr)
 		c.mu.Unlock()
-		if exists {
-			ch <- &reply
+		if w != nil {
+			w.ch <- &reply
 		}
 	}
 }
 
-func (c *Client) handleUpdate(addr string) {
-	select {
-	case c.updates <- Update{Address: addr}:
-	default:
-	}
-}
-
-func (c *Client) send(addr string, args ...any) error {
-	msg := buildOSC(addr, args...)
-	encoded := slipEncode(msg)
+func (c *Client) send(ctx context.Context, addr string, args ...any) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	_, err := c.conn.Write(encoded)
-	return err
+	return c.writeLocked(ctx, addr, args...)
 }
 
-func (c *Client) sendAndWait(addr string, timeout time.Duration, args ...any) (*Reply, error) {
-	ch := make(chan *Reply, 1)
-	c.mu.Lock()
-	c.pending[addr] = ch
-	c.mu.Unlock()
+func (c *Client) writeLocked(ctx context.Context, addr string, args ...any) error {
+	return c.writePacketLocked(ctx, addr, osc.Message{Address: addr, Args: args})
+}
 
-	if err := c.send(addr, args...); err != nil {
-		c.mu.Lock()
+// writePacketLocked sends an encoded message or bundle. addr names the packet
+// in errors.
+func (c *Client) writePacketLocked(ctx context.Context, addr string, p osc.Packet) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(addr, err)
+	}
+	if c.conn == nil {
+		return fmt.Errorf("qlab: %s: %w", addr, ErrDisconnected)
+	}
+	for _, msg := range osc.Messages(p) {
+		if err := c.checkPermissionsLocked(msg); err != nil {
+			return err
+		}
+	}
+	pkt, err := p.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("qlab: %s: %w", addr, err)
+	}
+	if !c.udp {
+		pkt = osc.SLIPEncode(pkt)
+	} else if len(pkt) > maxDatagram {
+		return fmt.Errorf("qlab: %s: %w", addr, ErrTooLarge)
+	}
+	deadline, _ := ctx.Deadline()
+	c.conn.SetWriteDeadline(deadline)
+	if _, err := c.conn.Write(pkt); err != nil {
+		return fmt.Errorf("qlab: %s: %w: %w", addr, ErrDisconnected, err)
+	}
+	return nil
+}
+
+func (c *Client) popWaiterLocked(addr string) *waiter {
+	queue := c.pending[addr]
+	if len(queue) == 0 {
+		return nil
+	}
+	w := queue[0]
+	if len(queue) == 1 {
 		delete(c.pending, addr)
+	} else {
+		c.pending[addr] = queue[1:]
+	}
+	return w
+}
+
+func (c *Client) removeWaiterLocked(addr string, w *waiter) {
+	queue := c.pending[addr]
+	for i, q := range queue {
+		if q == w {
+			queue = append(queue[:i:i], queue[i+1:]...)
+			break
+		}
+	}
+	if len(queue) == 0 {
+		delete(c.pending, addr)
+	} else {
+		c.pending[addr] = queue
+	}
+}
+
+func (c *Client) request(ctx context.Context, addr string, args ...any) (*Reply, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	w := &waiter{ch: make(chan *Reply, 1)}
+	c.mu.Lock()
+	if err := c.writeLocked(ctx, addr, args...); err != nil {
 		c.mu.Unlock()
 		return nil, err
 	}
+	if queue := c.pending[addr]; len(queue) > 0 {
+		w.first = queue[len(queue)-1].first
+	} else {
+		w.first = w
+	}
+	c.pending[addr] = append(c.pending[addr], w)
+	conn := c.conn
+	c.mu.Unlock()
 
 	select {
-	case reply := <-ch:
+	case reply, ok := <-w.ch:
+		if !ok {
+			if w.err != nil {
+				return nil, w.err
+			}
+			return nil, fmt.Errorf("qlab: %s: %w", addr, ErrDisconnected)
+		}
 		if reply.Status != "ok" {
-			return reply, fmt.Errorf("qlab: %s: %s", addr, reply.Status)
+			return reply, &StatusError{Address: addr, Status: reply.Status}
 		}
 		return reply, nil
-	case <-time.After(timeout):
-		c.mu.Lock()
-		delete(c.pending, addr)
-		c.mu.Unlock()
-		return nil, fmt.Errorf("qlab: %s: timeout", addr)
+	case <-ctx.Done():
+		if c.udp && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			// A UDP reply this late is presumed lost; keeping the slot would
+			// shift every later reply to this address onto the wrong waiter.
+			// Over TCP nothing is lost, so the reply is still on its way and
+			// must consume this slot.
+			c.mu.Lock()
+			c.removeWaiterLocked(addr, w)
+			c.mu.Unlock()
+		} else if !c.udp {
+			time.AfterFunc(c.Timeout, func() { c.expireWaiter(conn, addr, w) })
+		}
+		return nil, contextError(addr, ctx.Err())
 	}
 }
 
-func (c *Client) request(addr string, args ...any) (*Reply, error) {
-	return c.sendAndWait(addr, 5*time.Second, args...)
+// expireWaiter reconnects if the queue w was given up in has not drained a
+// further Timeout later. Qlab has then likely dropped a reply, and each later
+// request to addr is being answered with the reply meant for the one before,
+// so a fresh request keeps the queue busy forever. The reconnect fails
+// everything pending on conn.
+func (c *Client) expireWaiter(conn net.Conn, addr string, w *waiter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if queue := c.pending[addr]; c.conn == conn && len(queue) > 0 && queue[0].first == w.first {
+		conn.Close()
+	}
 }
 
-func (c *Client) Version() (string, error) {
-	reply, err := c.request("/version")
+func (c *Client) Version(ctx context.Context) (string, error) {
+	reply, err := c.request(ctx, "/version")
 	if err != nil {
 		return "", err
 	}
@@ -242,8 +571,8 @@ func (c *Client) Version() (string, error) {
 	return v, nil
 }
 
-func (c *Client) Workspaces() ([]Workspace, error) {
-	reply, err := c.request("/workspaces")
+func (c *Client) Workspaces(ctx context.Context) ([]Workspace, error) {
+	reply, err := c.request(ctx, "/workspaces")
 	if err != nil {
 		return nil, err
 	}
@@ -254,71 +583,124 @@ func (c *Client) Workspaces() ([]Workspace, error) {
 	return ws, nil
 }
 
-func (c *Client) Connect(workspaceID string, passcode string) error {
+// Connect connects to a workspace and returns its handle. Connecting to a
+// workspace that is already open returns the existing handle with the new
+// passcode and permissions. A rejected passcode fails with ErrBadPasscode.
+func (c *Client) Connect(ctx context.Context, workspaceID string, passcode string) (*WorkspaceHandle, error) {
+	perms, err := c.connect(ctx, workspaceID, passcode)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := c.spaces[workspaceID]
+	if w == nil {
+		w = &WorkspaceHandle{ID: workspaceID, client: c, closed: make(chan struct{})}
+		c.spaces[workspaceID] = w
+	}
+	w.passcode = passcode
+	w.permissions = perms
+	w.connected = true
+	return w, nil
+}
+
+func (c *Client) connect(ctx context.Context, workspaceID string, passcode string) (Permissions, error) {
 	addr := fmt.Sprintf("/workspace/%s/connect", workspaceID)
+	var reply *Reply
+	var err error
 	if passcode != "" {
-		_, err := c.request(addr, passcode)
-		return err
+		reply, err = c.request(ctx, addr, passcode)
+	} else {
+		reply, err = c.request(ctx, addr)
 	}
-	_, err := c.request(addr)
-	return err
+	if err != nil {
+		return Permissions{}, err
+	}
+	return parseConnectReply(addr, reply.Data)
 }
 
-func (c *Client) Disconnect(workspaceID string) error {
-	return c.send(fmt.Sprintf("/workspace/%s/disconnect", workspaceID))
+// Workspace returns the handle for an open workspace, or nil.
+func (c *Client) Workspace(workspaceID string) *WorkspaceHandle {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.spaces[workspaceID]
+}
+
+func (c *Client) Disconnect(ctx context.Context, workspaceID string) error {
+	c.mu.Lock()
+	if w := c.spaces[workspaceID]; w != nil {
+		c.closeWorkspaceLocked(w)
+	}
+	c.mu.Unlock()
+	return c.send(ctx, fmt.Sprintf("/workspace/%s/disconnect", workspaceID))
 }
 
-func (c *Client) AlwaysReply(workspaceID string, enable bool) error {
+func (c *Client) AlwaysReply(ctx context.Context, workspaceID string, enable bool) error {
 	v := int32(0)
 	if enable {
 		v = 1
 	}
-	return c.send(fmt.Sprintf("/workspace/%s/alwaysReply", workspaceID), v)
+	c.mu.Lock()
+	if w := c.spaces[workspaceID]; w != nil {
+		w.alwaysReply = enable
+	}
+	c.mu.Unlock()
+	return c.send(ctx, fmt.Sprintf("/workspace/%s/alwaysReply", workspaceID), v)
 }
 
-func (c *Client) EnableUpdates(workspaceID string, enable bool) error {
+func (c *Client) EnableUpdates(ctx context.Context, workspaceID string, enable bool) error {
 	v := int32(0)
 	if enable {
 		v = 1
 	}
-	return c.send(fmt.Sprintf("/workspace/%s/updates", workspaceID), v)
+	c.mu.Lock()
+	if w := c.spaces[workspaceID]; w != nil {
+		w.updates = enable
+	}
+	c.mu.Unlock()
+	return c.send(ctx, fmt.Sprintf("/workspace/%s/updates", workspaceID), v)
+}
+
+func thumpAddr(workspaceID string) string {
+	return fmt.Sprintf("/workspace/%s/thump", workspaceID)
 }
 
-func (c *Client) Thump(workspaceID string) error {
-	return c.send(fmt.Sprintf("/workspace/%s/thump", workspaceID))
+func (c *Client) Thump(ctx context.Context, workspaceID string) error {
+	_, err := c.request(ctx, thumpAddr(workspaceID))
+	return err
 }
 
-func (c *Client) Go(workspaceID string) error {
-	return c.send(fmt.Sprintf("/workspace/%s/go", workspaceID))
+func (c *Client) Go(ctx context.Context, workspaceID string) error {
+	return c.send(ctx, fmt.Sprintf("/workspace/%s/go", workspaceID))
 }
 
-func (c *Client) GoTo(workspaceID string, cueNumber string) error {
-	return c.send(fmt.Sprintf("/workspace/%s/go", workspaceID), cueNumber)
+func (c *Client) GoTo(ctx context.Context, workspaceID string, cueNumber string) error {
+	return c.send(ctx, fmt.Sprintf("/workspace/%s/go", workspaceID), cueNumber)
 }
 
-func (c *Client) Stop(workspaceID string) error {
-	return c.send(fmt.Sprintf("/workspace/%s/stop", workspaceID))
+func (c *Client) Stop(ctx context.Context, workspaceID string) error {
+	return c.send(ctx, fmt.Sprintf("/workspace/%s/stop", workspaceID))
 }
 
-func (c *Client) Pause(workspaceID string) error {
-	return c.send(fmt.Sprintf("/workspace/%s/pause", workspaceID))
+func (c *Client) Pause(ctx context.Context, workspaceID string) error {
+	return c.send(ctx, fmt.Sprintf("/workspace/%s/pause", workspaceID))
 }
 
-func (c *Client) Resume(workspaceID string) error {
-	return c.send(fmt.Sprintf("/workspace/%s/resume", workspaceID))
+func (c *Client) Resume(ctx context.Context, workspaceID string) error {
+	return c.send(ctx, fmt.Sprintf("/workspace/%s/resume", workspaceID))
 }
 
-func (c *Client) Panic(workspaceID string) error {
-	return c.send(fmt.Sprintf("/workspace/%s/panic", workspaceID))
+func (c *Client) Panic(ctx context.Context, workspaceID string) error {
+	return c.send(ctx, fmt.Sprintf("/workspace/%s/panic", workspaceID))
 }
 
-func (c *Client) Reset(workspaceID string) error {
-	return c.send(fmt.Sprintf("/workspace/%s/reset", workspaceID))
+func (c *Client) Reset(ctx context.Context, workspaceID string) error {
+	return c.send(ctx, fmt.Sprintf("/workspace/%s/reset", workspaceID))
 }
 
-func (c *Client) CueLists(workspaceID string) ([]Cue, error) {
+func (c *Client) CueLists(ctx context.Context, workspaceID string) ([]Cue, error) {
 	addr := fmt.Sprintf("/workspace/%s/cueLists", workspaceID)
-	reply, err := c.request(addr)
+	reply, err := c.request(ctx, addr)
 	if err != nil {
 		return nil, err
 	}
@@ -329,9 +711,9 @@ func (c *Client) CueLists(workspaceID string) ([]Cue, error) {
 	return cues, nil
 }
 
-func (c *Client) SelectedCues(workspaceID string) ([]Cue, error) {
+func (c *Client) SelectedCues(ctx context.Context, workspaceID string) ([]Cue, error) {
 	addr := fmt.Sprintf("/workspace/%s/selectedCues", workspaceID)
-	reply, err := c.request(addr)
+	reply, err := c.request(ctx, addr)
 	if err != nil {
 		return nil, err
 	}
@@ -342,9 +724,9 @@ func (c *Client) SelectedCues(workspaceID string) ([]Cue, error) {
 	return cues, nil
 }
 
-func (c *Client) RunningCues(workspaceID string) ([]Cue, error) {
+func (c *Client) RunningCues(ctx context.Context, workspaceID string) ([]Cue, error) {
 	addr := fmt.Sprintf("/workspace/%s/runningCues", workspaceID)
-	reply, err := c.request(addr)
+	reply, err := c.request(ctx, addr)
 	if err != nil {
 		return nil, err
 	}
@@ -355,46 +737,52 @@ func (c *Client) RunningCues(workspaceID string) ([]Cue, error) {
 	return cues, nil
 }
 
-func (c *Client) CueGet(workspaceID string, cueID string, property string) (*Reply, error) {
+func (c *Client) CueGet(ctx context.Context, workspaceID string, cueID string, property string) (*Reply, error) {
 	addr := fmt.Sprintf("/workspace/%s/cue_id/%s/%s", workspaceID, cueID, property)
-	return c.request(addr)
+	return c.request(ctx, addr)
 }
 
-func (c *Client) CueGetByNumber(workspaceID string, cueNumber string, property string) (*Reply, error) {
+func (c *Client) CueGetByNumber(ctx context.Context, workspaceID string, cueNumber string, property string) (*Reply, error) {
 	addr := fmt.Sprintf("/workspace/%s/cue/%s/%s", workspaceID, cueNumber, property)
-	return c.request(addr)
+	return c.request(ctx, addr)
 }
 
-func (c *Client) CueSet(workspaceID string, cueID string, property string, value any) error {
+func (c *Client) CueSet(ctx context.Context, workspaceID string, cueID string, property string, value any) error {
 	addr := fmt.Sprintf("/workspace/%s/cue_id/%s/%s", workspaceID, cueID, property)
-	return c.send(addr, value)
+	return c.send(ctx, addr, value)
 }
 
-func (c *Client) CueSetByNumber(workspaceID string, cueNumber string, property string, value any) error {
+func (c *Client) CueSetByNumber(ctx context.Context, workspaceID string, cueNumber string, property string, value any) error {
 	addr := fmt.Sprintf("/workspace/%s/cue/%s/%s", workspaceID, cueNumber, property)
-	return c.send(addr, value)
+	return c.send(ctx, addr, value)
+}
+
+func (c *Client) CueStart(ctx context.Context, workspaceID string, cueID string) error {
+	return c.send(ctx, fmt.Sprintf("/workspace/%s/cue_id/%s/start", workspaceID, cueID))
 }
 
-func (c *Client) CueStart(workspaceID string, cueID string) error {
-	return c.send(fmt.Sprintf("/workspace/%s/cue_id/%s/start", workspaceID, cueID))
+func (c *Client) CueStop(ctx context.Context, workspaceID string, cueID string) error {
+	return c.send(ctx, fmt.Sprintf("/workspace/%s/cue_id/%s/stop", workspaceID, cueID))
 }
 
-func (c *Client) CueStop(workspaceID string, cueID string) error {
-	return c.send(fmt.Sprintf("/workspace/%s/cue_id/%s/stop", workspaceID, cueID))
+func (c *Client) CuePause(ctx context.Context, workspaceID string, cueID string) error {
+	return c.send(ctx, fmt.Sprintf("/workspace/%s/cue_id/%s/pause", workspaceID, cueID))
 }
 
-func (c *Client) CuePause(workspaceID string, cueID string) error {
-	return c.send(fmt.Sprintf("/workspace/%s/cue_id/%s/pause", workspaceID, cueID))
+func (c *Client) CueResume(ctx context.Context, workspaceID string, cueID string) error {
+	return c.send(ctx, fmt.Sprintf("/workspace/%s/cue_id/%s/resume", workspaceID, cueID))
 }
 
-func (c *Client) CueResume(workspaceID string, cueID string) error {
-	return c.send(fmt.Sprintf("/workspace/%s/cue_id/%s/resume", workspaceID, cueID))
+func (c *Client) CueLoad(ctx context.Context, workspaceID string, cueID string) error {
+	return c.send(ctx, fmt.Sprintf("/workspace/%s/cue_id/%s/load", workspaceID, cueID))
 }
 
-func (c *Client) CueLoad(workspaceID string, cueID string) error {
-	return c.send(fmt.Sprintf("/workspace/%s/cue_id/%s/load", workspaceID, cueID))
+// CuePanicInTime fades a cue out and stops it over fade, regardless of the
+// workspace's panic duration.
+func (c *Client) CuePanicInTime(ctx context.Context, workspaceID string, cueID string, fade time.Duration) error {
+	return c.send(ctx, fmt.Sprintf("/workspace/%s/cue_id/%s/panicInTime", workspaceID, cueID), float32(fade.Seconds()))
 }
 
-func (c *Client) CueReset(workspaceID string, cueID string) error {
-	return c.send(fmt.Sprintf("/workspace/%s/cue_id/%s/reset", workspaceID, cueID))
+func (c *Client) CueReset(ctx context.Context, workspaceID string, cueID string) error {
+	return c.send(ctx, fmt.Sprintf("/workspace/%s/cue_id/%s/reset", workspaceID, cueID))
 }
@@ -1,7 +1,11 @@
 package qlab
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 )
@@ -27,7 +31,7 @@ func TestVersion(t *testing.T) {
 	mock, client := setupTest(t)
 	mock.Version = "5.2.3"
 
-	v, err := client.Version()
+	v, err := client.Version(t.Context())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -43,7 +47,7 @@ func TestWorkspaces(t *testing.T) {
 		{DisplayName: "Show 2", UniqueID: "ws-2", HasPasscode: true},
 	}
 
-	ws, err := client.Workspaces()
+	ws, err := client.Workspaces(t.Context())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -64,7 +68,7 @@ func TestWorkspaces(t *testing.T) {
 func TestConnect(t *testing.T) {
 	_, client := setupTest(t)
 
-	if err := client.Connect("ws-1", ""); err != nil {
+	if _, err := client.Connect(t.Context(), "ws-1", ""); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -72,7 +76,7 @@ func TestConnect(t *testing.T) {
 func TestConnectWithPasscode(t *testing.T) {
 	_, client := setupTest(t)
 
-	if err := client.Connect("ws-1", "secret"); err != nil {
+	if _, err := client.Connect(t.Context(), "ws-1", "secret"); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -91,7 +95,7 @@ func TestCueLists(t *testing.T) {
 		},
 	}
 
-	lists, err := client.CueLists("ws-1")
+	lists, err := client.CueLists(t.Context(), "ws-1")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -121,7 +125,7 @@ func TestCueGet(t *testing.T) {
 		},
 	}
 
-	reply, err := client.CueGet("ws-1", "cue-1", "name")
+	reply, err := client.CueGet(t.Context(), "ws-1", "cue-1", "name")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -147,7 +151,7 @@ func TestCueGetByNumber(t *testing.T) {
 		},
 	}
 
-	reply, err := client.CueGetByNumber("ws-1", "2", "name")
+	reply, err := client.CueGetByNumber(t.Context(), "ws-1", "2", "name")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -164,10 +168,17 @@ func TestCueGetNotFound(t *testing.T) {
 	mock, client := setupTest(t)
 	mock.CueLists["ws-1"] = []Cue{}
 
-	_, err := client.CueGet("ws-1", "nonexistent", "name")
+	_, err := client.CueGet(t.Context(), "ws-1", "nonexistent", "name")
 	if err == nil {
 		t.Fatal("expected error for nonexistent cue")
 	}
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Status != "not found" {
+		t.Errorf("got %v, want StatusError with status %q", err, "not found")
+	}
 }
 
 func TestCueSet(t *testing.T) {
@@ -182,11 +193,11 @@ func TestCueSet(t *testing.T) {
 		},
 	}
 
-	if err := client.CueSet("ws-1", "cue-1", "name", "Blackout"); err != nil {
+	if err := client.CueSet(t.Context(), "ws-1", "cue-1", "name", "Blackout"); err != nil {
 		t.Fatal(err)
 	}
 
-	reply, err := client.CueGet("ws-1", "cue-1", "name")
+	reply, err := client.CueGet(t.Context(), "ws-1", "cue-1", "name")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -209,11 +220,11 @@ func TestCueSetByNumber(t *testing.T) {
 		},
 	}
 
-	if err := client.CueSetByNumber("ws-1", "1", "name", "Blackout"); err != nil {
+	if err := client.CueSetByNumber(t.Context(), "ws-1", "1", "name", "Blackout"); err != nil {
 		t.Fatal(err)
 	}
 
-	reply, err := client.CueGetByNumber("ws-1", "1", "name")
+	reply, err := client.CueGetByNumber(t.Context(), "ws-1", "1", "name")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -228,7 +239,7 @@ func TestUpdates(t *testing.T) {
 	mock, client := setupTest(t)
 
 	// Ensure connection is fully established
-	_, err := client.Version()
+	_, err := client.Version(t.Context())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -248,7 +259,7 @@ func TestUpdates(t *testing.T) {
 func TestTransport(t *testing.T) {
 	_, client := setupTest(t)
 
-	for _, fn := range []func(string) error{
+	for _, fn := range []func(context.Context, string) error{
 		client.Go,
 		client.Stop,
 		client.Pause,
@@ -256,7 +267,7 @@ func TestTransport(t *testing.T) {
 		client.Panic,
 		client.Reset,
 	} {
-		if err := fn("ws-1"); err != nil {
+		if err := fn(t.Context(), "ws-1"); err != nil {
 			t.Fatal(err)
 		}
 	}
@@ -265,7 +276,7 @@ func TestTransport(t *testing.T) {
 func TestSelectedCues(t *testing.T) {
 	_, client := setupTest(t)
 
-	cues, err := client.SelectedCues("ws-1")
+	cues, err := client.SelectedCues(t.Context(), "ws-1")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -277,7 +288,7 @@ func TestSelectedCues(t *testing.T) {
 func TestRunningCues(t *testing.T) {
 	_, client := setupTest(t)
 
-	cues, err := client.RunningCues("ws-1")
+	cues, err := client.RunningCues(t.Context(), "ws-1")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -306,7 +317,7 @@ func TestNestedCueGet(t *testing.T) {
 		},
 	}
 
-	reply, err := client.CueGet("ws-1", "nested-1", "name")
+	reply, err := client.CueGet(t.Context(), "ws-1", "nested-1", "name")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -316,3 +327,248 @@ func TestNestedCueGet(t *testing.T) {
 		t.Errorf("got %q, want %q", name, "Nested Cue")
 	}
 }
+
+func waitState(t *testing.T, client *Client, want ConnState) {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		if client.State() == want {
+			return
+		}
+		select {
+		case <-client.StateChanges():
+		case <-time.After(10 * time.Millisecond):
+		case <-deadline:
+			t.Fatalf("timeout waiting for state %s, have %s", want, client.State())
+		}
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	mock, client := setupTest(t)
+
+	if _, err := client.Connect(t.Context(), "ws-1", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.EnableUpdates(t.Context(), "ws-1", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.AlwaysReply(t.Context(), "ws-1", true); err != nil {
+		t.Fatal(err)
+	}
+
+	mock.Close()
+	waitState(t, client, StateReconnecting)
+
+	if _, err := client.Version(t.Context()); !errors.Is(err, ErrDisconnected) {
+		t.Errorf("got %v, want ErrDisconnected", err)
+	}
+
+	mock.ResetRequests()
+	if err := mock.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	waitState(t, client, StateConnected)
+
+	if _, err := client.Version(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string][]any{}
+	for _, req := range mock.Requests() {
+		got[req.Address] = req.Args
+	}
+	if args, ok := got["/workspace/ws-1/connect"]; !ok || len(args) != 1 || args[0] != "secret" {
+		t.Errorf("connect not restored with passcode: %v", args)
+	}
+	if args, ok := got["/workspace/ws-1/updates"]; !ok || len(args) != 1 || args[0] != int32(1) {
+		t.Errorf("updates not restored: %v", args)
+	}
+	if args, ok := got["/workspace/ws-1/alwaysReply"]; !ok || len(args) != 1 || args[0] != int32(1) {
+		t.Errorf("alwaysReply not restored: %v", args)
+	}
+
+	mock.SendUpdate("/update/workspace/ws-1/cue_id/cue-1")
+	select {
+	case <-client.Updates():
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for update after reconnect")
+	}
+}
+
+func TestReconnectPendingFails(t *testing.T) {
+	mock, client := setupTest(t)
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := client.request(context.Background(), "/unanswered")
+		errc <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	mock.DropConnections()
+
+	select {
+	case err := <-errc:
+		if !errors.Is(err, ErrDisconnected) {
+			t.Errorf("got %v, want ErrDisconnected", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("pending request did not fail on disconnect")
+	}
+}
+
+func TestCloseState(t *testing.T) {
+	_, client := setupTest(t)
+	client.Close()
+	if s := client.State(); s != StateClosed {
+		t.Errorf("got %s, want %s", s, StateClosed)
+	}
+}
+
+func TestConcurrentSameAddress(t *testing.T) {
+	mock, client := setupTest(t)
+	mock.CueLists["ws-1"] = []Cue{
+		{
+			UniqueID: "list-1",
+			Type:     "Cue List",
+			Cues: []Cue{
+				{UniqueID: "cue-1", Number: "1", Name: "Lights Up"},
+			},
+		},
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 32)
+	for range 32 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reply, err := client.CueGet(t.Context(), "ws-1", "cue-1", "name")
+			if err != nil {
+				errs <- err
+				return
+			}
+			var name string
+			json.Unmarshal(reply.Data, &name)
+			if name != "Lights Up" {
+				errs <- fmt.Errorf("got %q, want %q", name, "Lights Up")
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestCancelKeepsReplyOrder(t *testing.T) {
+	mock, client := setupTest(t)
+	mock.SetThumpDelay(50 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := client.request(ctx, thumpAddr("ws-1"))
+		errc <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+
+	start := time.Now()
+	if _, err := client.request(context.Background(), thumpAddr("ws-1")); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("second request took %v; it received the cancelled request's reply", elapsed)
+	}
+
+	client.mu.Lock()
+	n := len(client.pending)
+	client.mu.Unlock()
+	if n != 0 {
+		t.Errorf("got %d pending addresses, want 0", n)
+	}
+}
+
+func TestTimeoutKeepsReplyOrder(t *testing.T) {
+	mock, client := setupTest(t)
+	mock.SetThumpDelay(50 * time.Millisecond)
+
+	// Over TCP a late reply still comes, and belongs to the request that
+	// timed out, not the next one.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := client.request(ctx, thumpAddr("ws-1")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+	// Ask again just before the first reply is due.
+	time.Sleep(30 * time.Millisecond)
+	start := time.Now()
+	if _, err := client.request(context.Background(), thumpAddr("ws-1")); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("second request took %v; it received the timed out request's reply", elapsed)
+	}
+}
+
+func TestTimeoutReleasesSlot(t *testing.T) {
+	mock, client := setupUDPTest(t)
+	mock.SetDropThumps(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.request(ctx, thumpAddr("ws-1")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+
+	mock.SetDropThumps(false)
+	if err := client.Thump(t.Context(), "ws-1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestContextErrors(t *testing.T) {
+	mock, client := setupTest(t)
+	mock.SetDropThumps(true)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+	err := client.Thump(ctx, "ws-1")
+	if !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want ErrTimeout and context.DeadlineExceeded", err)
+	}
+	if errors.Is(err, ErrCanceled) {
+		t.Errorf("timeout %v must not match ErrCanceled", err)
+	}
+
+	ctx, cancel = context.WithCancel(t.Context())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	err = client.Thump(ctx, "ws-1")
+	if !errors.Is(err, ErrCanceled) || !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want ErrCanceled and context.Canceled", err)
+	}
+
+	err = client.Go(ctx, "ws-1")
+	if !errors.Is(err, ErrCanceled) {
+		t.Errorf("send on cancelled context: got %v, want ErrCanceled", err)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	mock, client := setupTest(t)
+	mock.SetDropThumps(true)
+	client.Timeout = 20 * time.Millisecond
+
+	if err := client.Thump(t.Context(), "ws-1"); !errors.Is(err, ErrTimeout) {
+		t.Errorf("got %v, want ErrTimeout", err)
+	}
+}
@@ -0,0 +1,738 @@
+package qlab
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"time"
+
+	"qrun/lib/osc"
+)
+
+// The simulator runs on a virtual clock that only moves when Advance is
+// called (or Run drives it in real time), so tests can step through pre
+// waits, durations and continues deterministically.
+
+const (
+	DefaultPanicDuration = 1 * time.Second
+
+	// DefaultRandomSeed seeds the choices of random groups until
+	// SetRandomSeed picks another.
+	DefaultRandomSeed = 1
+
+	maxSimCascade = 10000
+)
+
+type simPhase int
+
+const (
+	simPreWait simPhase = iota
+	simAction
+	simPanic
+)
+
+type simCue struct {
+	wsID    string
+	id      string
+	seq     int
+	phase   simPhase
+	paused  bool
+	group   bool
+	forever bool
+
+	preWait     time.Duration
+	preElapsed  time.Duration
+	action      time.Duration
+	actionLen   time.Duration
+	elapsed     time.Duration
+	postWait    time.Duration
+	waitingPost bool
+	panicLeft   time.Duration
+}
+
+// nextEvent returns the time until this cue's next transition, if any.
+func (s *simCue) nextEvent() (time.Duration, bool) {
+	if s.paused {
+		return 0, false
+	}
+	switch s.phase {
+	case simPreWait:
+		return s.preWait, true
+	case simPanic:
+		return s.panicLeft, true
+	}
+	if s.waitingPost && (s.group || s.forever || s.action <= 0 || s.postWait < s.action) {
+		return s.postWait, true
+	}
+	if s.group || s.forever {
+		return 0, false
+	}
+	return s.action, true
+}
+
+// actionDone reports whether a timed cue has finished its action and has no
+// post wait left to hold it open.
+func (s *simCue) actionDone() bool {
+	return !s.group && !s.forever && s.action <= 0 && !s.waitingPost
+}
+
+func (s *simCue) tick(d time.Duration) {
+	if s.paused {
+		return
+	}
+	switch s.phase {
+	case simPreWait:
+		s.preWait -= d
+		s.preElapsed += d
+	case simAction:
+		s.elapsed += d
+		if !s.group && !s.forever {
+			s.action -= d
+		}
+		if s.waitingPost {
+			s.postWait -= d
+		}
+	case simPanic:
+		s.panicLeft -= d
+	}
+}
+
+type simState struct {
+	clock    time.Duration
+	seq      int
+	running  map[string]*simCue
+	playhead map[string]string
+	loadedAt map[string]time.Duration
+	cascade  int
+	rand     *rand.Rand
+}
+
+func (m *MockServer) sim() *simState {
+	if m.simState == nil {
+		m.simState = &simState{
+			running:  make(map[string]*simCue),
+			playhead: make(map[string]string),
+			loadedAt: make(map[string]time.Duration),
+			rand:     rand.New(rand.NewPCG(DefaultRandomSeed, DefaultRandomSeed)),
+		}
+	}
+	return m.simState
+}
+
+// SetRandomSeed reseeds the choices of groups that start a random child, so
+// a test can pick the sequence it gets.
+func (m *MockServer) SetRandomSeed(seed uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sim().rand = rand.New(rand.NewPCG(seed, seed))
+}
+
+// Now returns the simulator's virtual clock.
+func (m *MockServer) Now() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sim().clock
+}
+
+// Advance moves the virtual clock forward, firing every wait, completion and
+// continue that falls due along the way.
+func (m *MockServer) Advance(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sim := m.sim()
+	target := sim.clock + d
+	for {
+		step, ok := m.nextEventLocked()
+		if !ok || sim.clock+step > target {
+			m.tickLocked(target - sim.clock)
+			sim.clock = target
+			return
+		}
+		m.tickLocked(step)
+		sim.clock += step
+		m.fireDueLocked()
+	}
+}
+
+// Run advances the virtual clock in real time until ctx is done.
+func (m *MockServer) Run(ctx context.Context, tick time.Duration) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.Advance(now.Sub(last))
+			last = now
+		}
+	}
+}
+
+// RunningCueIDs returns the unique IDs of running cues in start order.
+func (m *MockServer) RunningCueIDs(wsID string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []string
+	for _, s := range m.runningLocked(wsID, true) {
+		ids = append(ids, s.id)
+	}
+	return ids
+}
+
+func (m *MockServer) Playhead(wsID string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := m.activeListLocked(wsID)
+	if list == nil {
+		return ""
+	}
+	return m.playheadLocked(wsID, list)
+}
+
+func (m *MockServer) runningLocked(wsID string, includePaused bool) []*simCue {
+	var out []*simCue
+	for _, s := range m.sim().running {
+		if s.wsID == wsID && (includePaused || !s.paused) {
+			out = append(out, s)
+		}
+	}
+	slices.SortFunc(out, func(a, b *simCue) int { return a.seq - b.seq })
+	return out
+}
+
+func (m *MockServer) nextEventLocked() (time.Duration, bool) {
+	best, found := time.Duration(0), false
+	for _, s := range m.sim().running {
+		if d, ok := s.nextEvent(); ok && (!found || d < best) {
+			best, found = d, true
+		}
+	}
+	return max(best, 0), found
+}
+
+func (m *MockServer) tickLocked(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	for _, s := range m.sim().running {
+		s.tick(d)
+	}
+}
+
+func (m *MockServer) fireDueLocked() {
+	sim := m.sim()
+	sim.cascade = 0
+	var due []*simCue
+	for _, s := range sim.running {
+		if d, ok := s.nextEvent(); ok && d <= 0 {
+			due = append(due, s)
+		}
+	}
+	slices.SortFunc(due, func(a, b *simCue) int { return a.seq - b.seq })
+	for _, s := range due {
+		if sim.running[s.id] != s || s.paused {
+			continue
+		}
+		switch s.phase {
+		case simPreWait:
+			m.beginActionLocked(s)
+		case simPanic:
+			m.stopCueLocked(s.wsID, s.id)
+		case simAction:
+			m.settleLocked(s)
+		}
+	}
+}
+
+func (m *MockServer) propDuration(cueID, prop string) time.Duration {
+	secs := mockNumber(m.propLocked(cueID, prop))
+	return time.Duration(secs * float64(time.Second))
+}
+
+func (m *MockServer) propInt(cueID, prop string) int {
+	return int(mockNumber(m.propLocked(cueID, prop)))
+}
+
+func (m *MockServer) propLocked(cueID, prop string) any {
+	if v, ok := m.props[cueID][prop]; ok {
+		return v
+	}
+	return mockPropertyDefaults[prop]
+}
+
+func (m *MockServer) startCueLocked(wsID, cueID string) {
+	sim := m.sim()
+	cue := m.findCueByID(wsID, cueID)
+	if cue == nil || cue.Type == "Cue List" {
+		return
+	}
+	if sim.cascade++; sim.cascade > maxSimCascade {
+		return
+	}
+	if old := sim.running[cueID]; old != nil {
+		if old.paused {
+			m.setPausedLocked(wsID, cueID, false)
+		}
+		return
+	}
+
+	sim.seq++
+	s := &simCue{
+		wsID:    wsID,
+		id:      cueID,
+		seq:     sim.seq,
+		phase:   simPreWait,
+		preWait: m.propDuration(cueID, "preWait"),
+	}
+	if at, ok := sim.loadedAt[cueID]; ok {
+		delete(sim.loadedAt, cueID)
+		skip := min(at, s.preWait)
+		s.preWait -= skip
+		s.preElapsed = skip
+		s.elapsed = at - skip
+	}
+	sim.running[cueID] = s
+	m.cueUpdateLocked(wsID, cueID)
+	if s.preWait <= 0 {
+		m.beginActionLocked(s)
+	}
+}
+
+func (m *MockServer) beginActionLocked(s *simCue) {
+	cue := m.findCueByID(s.wsID, s.id)
+	if cue == nil {
+		m.stopCueLocked(s.wsID, s.id)
+		return
+	}
+	s.phase = simAction
+	s.preWait = 0
+	s.actionLen = m.propDuration(s.id, "duration")
+	s.action = max(s.actionLen-s.elapsed, 0)
+	if ContinueMode(m.propInt(s.id, "continueMode")) == AutoContinue {
+		s.waitingPost = true
+		s.postWait = max(m.propDuration(s.id, "postWait")-s.elapsed, 0)
+	}
+	m.cueUpdateLocked(s.wsID, s.id)
+
+	targetID, _ := m.propLocked(s.id, "cueTargetID").(string)
+	switch cue.Type {
+	case "Group":
+		s.group = true
+		children := childIDs(cue.Cues)
+		if len(children) == 0 {
+			break
+		}
+		switch GroupMode(m.propInt(s.id, "mode")) {
+		case GroupStartFirst, GroupStartFirstAndEnter:
+			m.startCueLocked(s.wsID, children[0])
+		case GroupStartRandom:
+			m.startCueLocked(s.wsID, children[m.sim().rand.IntN(len(children))])
+		default:
+			for _, id := range children {
+				m.startCueLocked(s.wsID, id)
+			}
+		}
+	case "Start":
+		s.action = 0
+		m.startCueLocked(s.wsID, targetID)
+	case "Stop":
+		s.action = 0
+		m.stopCueLocked(s.wsID, targetID)
+	case "Fade":
+	default:
+		if v, _ := m.propLocked(s.id, "infiniteLoop").(bool); v {
+			s.forever = true
+		}
+	}
+
+	m.settleLocked(s)
+}
+
+// settleLocked fires a due auto-continue and completes the cue if nothing
+// holds it open any more.
+func (m *MockServer) settleLocked(s *simCue) {
+	sim := m.sim()
+	if sim.running[s.id] != s {
+		return
+	}
+	if s.waitingPost && s.postWait <= 0 {
+		s.waitingPost = false
+		m.continueLocked(s.wsID, s.id)
+	}
+	if sim.running[s.id] != s {
+		return
+	}
+	if s.group {
+		m.checkGroupDoneLocked(s.wsID, s.id)
+	} else if s.actionDone() {
+		m.completeLocked(s)
+	}
+}
+
+func (m *MockServer) completeLocked(s *simCue) {
+	cue := m.findCueByID(s.wsID, s.id)
+	if cue != nil && cue.Type == "Fade" {
+		if stop, _ := m.propLocked(s.id, "stopTargetWhenDone").(bool); stop {
+			target, _ := m.propLocked(s.id, "cueTargetID").(string)
+			m.stopCueLocked(s.wsID, target)
+		}
+	}
+	follow := ContinueMode(m.propInt(s.id, "continueMode")) == AutoFollow
+	m.finishLocked(s.wsID, s.id)
+	if follow {
+		m.continueLocked(s.wsID, s.id)
+	}
+	m.checkGroupDoneLocked(s.wsID, m.parentID(s.wsID, s.id))
+}
+
+// continueLocked fires the cue after cueID, as auto-continue and auto-follow
+// do. Only children of groups that start their first child continue; the
+// others start everything at once, or one child at random.
+func (m *MockServer) continueLocked(wsID, cueID string) {
+	parentID := m.parentID(wsID, cueID)
+	parent := m.findCueByID(wsID, parentID)
+	if parent == nil {
+		return
+	}
+	if parent.Type == "Group" && !startsFirstChild(GroupMode(m.propInt(parentID, "mode"))) {
+		return
+	}
+	ids := childIDs(parent.Cues)
+	i := slices.Index(ids, cueID)
+	if i < 0 || i+1 >= len(ids) {
+		return
+	}
+	m.startCueLocked(wsID, ids[i+1])
+}
+
+func (m *MockServer) checkGroupDoneLocked(wsID, groupID string) {
+	s := m.sim().running[groupID]
+	if s == nil || !s.group || s.phase != simAction {
+		return
+	}
+	cue := m.findCueByID(wsID, groupID)
+	if cue != nil {
+		for _, id := range childIDs(cue.Cues) {
+			if m.sim().running[id] != nil {
+				return
+			}
+		}
+	}
+	if s.waitingPost {
+		// Like any cue, a group stays open until its post wait has elapsed.
+		return
+	}
+	m.completeLocked(s)
+}
+
+// finishLocked removes a cue from the running set without side effects on its
+// parent; stopCueLocked is the outward-facing variant.
+func (m *MockServer) finishLocked(wsID, cueID string) {
+	sim := m.sim()
+	s := sim.running[cueID]
+	if s == nil {
+		return
+	}
+	delete(sim.running, cueID)
+	if cue := m.findCueByID(wsID, cueID); cue != nil {
+		for _, id := range childIDs(cue.Cues) {
+			m.finishLocked(wsID, id)
+		}
+	}
+	m.cueUpdateLocked(wsID, cueID)
+}
+
+func (m *MockServer) stopCueLocked(wsID, cueID string) {
+	if m.sim().running[cueID] == nil {
+		return
+	}
+	m.finishLocked(wsID, cueID)
+	m.checkGroupDoneLocked(wsID, m.parentID(wsID, cueID))
+}
+
+func (m *MockServer) stopAllLocked(wsID string) {
+	for _, s := range m.runningLocked(wsID, true) {
+		m.finishLocked(wsID, s.id)
+	}
+}
+
+// panicLocked fades every running cue out over PanicDuration. A second panic
+// while fading stops everything immediately, as in Qlab.
+func (m *MockServer) panicLocked(wsID string) {
+	running := m.runningLocked(wsID, true)
+	for _, s := range running {
+		if s.phase == simPanic {
+			m.stopAllLocked(wsID)
+			return
+		}
+	}
+	for _, s := range running {
+		s.phase = simPanic
+		s.paused = false
+		s.panicLeft = m.PanicDuration
+		m.cueUpdateLocked(wsID, s.id)
+	}
+	if m.PanicDuration <= 0 {
+		m.stopAllLocked(wsID)
+	}
+}
+
+// panicCueLocked fades one running cue out over fade.
+func (m *MockServer) panicCueLocked(wsID, cueID string, fade time.Duration) {
+	s := m.sim().running[cueID]
+	if s == nil {
+		return
+	}
+	if fade <= 0 {
+		m.stopCueLocked(wsID, cueID)
+		return
+	}
+	s.phase = simPanic
+	s.paused = false
+	s.panicLeft = fade
+	m.cueUpdateLocked(wsID, cueID)
+}
+
+func (m *MockServer) setPausedLocked(wsID, cueID string, paused bool) {
+	s := m.sim().running[cueID]
+	if s == nil || s.phase == simPanic {
+		return
+	}
+	s.paused = paused
+	m.cueUpdateLocked(wsID, cueID)
+	if cue := m.findCueByID(wsID, cueID); cue != nil {
+		for _, id := range childIDs(cue.Cues) {
+			m.setPausedLocked(wsID, id, paused)
+		}
+	}
+}
+
+func (m *MockServer) activeListLocked(wsID string) *Cue {
+	lists := m.CueLists[wsID]
+	if len(lists) == 0 {
+		return nil
+	}
+	return &lists[0]
+}
+
+func (m *MockServer) playheadLocked(wsID string, list *Cue) string {
+	id, ok := m.sim().playhead[list.UniqueID]
+	if !ok && len(list.Cues) > 0 {
+		return list.Cues[0].UniqueID
+	}
+	return id
+}
+
+func (m *MockServer) setPlayheadLocked(wsID, listID, cueID string) {
+	m.sim().playhead[listID] = cueID
+	m.sendUpdateLocked(wsID, fmt.Sprintf("/update/workspace/%s/cueList/%s/playbackPosition", wsID, listID), cueID)
+}
+
+func startsFirstChild(mode GroupMode) bool {
+	return mode == GroupStartFirst || mode == GroupStartFirstAndEnter
+}
+
+// goLocked starts the cue at the playhead and moves the playhead on; see
+// playheadAfterLocked.
+func (m *MockServer) goLocked(wsID string) {
+	list := m.activeListLocked(wsID)
+	if list == nil {
+		return
+	}
+	m.sim().cascade = 0
+	current := m.playheadLocked(wsID, list)
+	if current == "" || m.findCueByID(wsID, current) == nil {
+		return
+	}
+	m.setPlayheadLocked(wsID, list.UniqueID, m.playheadAfterLocked(wsID, list, current))
+	m.startCueLocked(wsID, current)
+}
+
+// playheadAfterLocked is where GO on cueID leaves the playhead: past the cue
+// and every cue it will continue into. A group that starts its first child
+// and enters takes the playhead to the child after, and the playhead leaves
+// a group again after its last child.
+func (m *MockServer) playheadAfterLocked(wsID string, list *Cue, cueID string) string {
+	if cue := m.findCueByID(wsID, cueID); cue != nil && cue.Type == "Group" && len(cue.Cues) > 0 &&
+		GroupMode(m.propInt(cueID, "mode")) == GroupStartFirstAndEnter {
+		return m.playheadAfterLocked(wsID, list, cue.Cues[0].UniqueID)
+	}
+	for id := cueID; id != list.UniqueID; id = m.parentID(wsID, id) {
+		parent := m.findCueByID(wsID, m.parentID(wsID, id))
+		if parent == nil {
+			return ""
+		}
+		ids := childIDs(parent.Cues)
+		next := slices.Index(ids, id) + 1
+		for next < len(ids) && ContinueMode(m.propInt(ids[next-1], "continueMode")) != NoContinue {
+			next++
+		}
+		if next < len(ids) {
+			return ids[next]
+		}
+	}
+	return ""
+}
+
+func (m *MockServer) goToLocked(wsID, number string) {
+	cue := m.findCueByNumber(wsID, number)
+	list := m.activeListLocked(wsID)
+	if cue == nil || list == nil {
+		return
+	}
+	m.setPlayheadLocked(wsID, list.UniqueID, cue.UniqueID)
+	m.goLocked(wsID)
+}
+
+func (m *MockServer) resetLocked(wsID string) {
+	m.stopAllLocked(wsID)
+	if list := m.activeListLocked(wsID); list != nil && len(list.Cues) > 0 {
+		m.setPlayheadLocked(wsID, list.UniqueID, list.Cues[0].UniqueID)
+	}
+}
+
+// workspaceCommand handles transport messages addressed to a workspace.
+// It reports false if rest is not one.
+func (m *MockServer) workspaceCommand(wsID, rest string, args []any) bool {
+	m.sim().cascade = 0
+	switch rest {
+	case "go":
+		if len(args) > 0 {
+			number, _ := args[0].(string)
+			m.goToLocked(wsID, number)
+		} else {
+			m.goLocked(wsID)
+		}
+	case "stop":
+		m.stopAllLocked(wsID)
+	case "panic":
+		m.panicLocked(wsID)
+	case "pause":
+		for _, s := range m.runningLocked(wsID, true) {
+			m.setPausedLocked(wsID, s.id, true)
+		}
+	case "resume":
+		for _, s := range m.runningLocked(wsID, true) {
+			m.setPausedLocked(wsID, s.id, false)
+		}
+	case "reset":
+		m.resetLocked(wsID)
+	default:
+		return false
+	}
+	return true
+}
+
+// cueCommand handles per-cue transport messages. It reports false if action
+// is not one, so the caller can treat it as a property.
+func (m *MockServer) cueCommand(wsID string, cue *Cue, action string, args []any) bool {
+	m.sim().cascade = 0
+	switch action {
+	case "start", "go":
+		m.startCueLocked(wsID, cue.UniqueID)
+	case "stop", "reset":
+		m.stopCueLocked(wsID, cue.UniqueID)
+	case "pause":
+		m.setPausedLocked(wsID, cue.UniqueID, true)
+	case "resume":
+		m.setPausedLocked(wsID, cue.UniqueID, false)
+	case "load":
+		m.sim().loadedAt[cue.UniqueID] = 0
+	case "loadAt":
+		if len(args) > 0 {
+			m.sim().loadedAt[cue.UniqueID] = time.Duration(mockNumber(args[0]) * float64(time.Second))
+		}
+	case "panic":
+		m.panicCueLocked(wsID, cue.UniqueID, m.PanicDuration)
+	case "panicInTime":
+		if len(args) > 0 {
+			m.panicCueLocked(wsID, cue.UniqueID, time.Duration(mockNumber(args[0])*float64(time.Second)))
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// simProperty answers the read-only runtime properties of a cue.
+func (m *MockServer) simProperty(wsID string, cue *Cue, prop string) (any, bool) {
+	s := m.sim().running[cue.UniqueID]
+	switch prop {
+	case "isRunning":
+		return s != nil && !s.paused, true
+	case "isPaused":
+		return s != nil && s.paused, true
+	case "isPanicking":
+		return s != nil && s.phase == simPanic, true
+	case "preWaitElapsed":
+		if s == nil {
+			return 0.0, true
+		}
+		return s.preElapsed.Seconds(), true
+	case "actionElapsed":
+		if s == nil {
+			return 0.0, true
+		}
+		return s.elapsed.Seconds(), true
+	case "percentActionElapsed":
+		if s == nil || s.actionLen <= 0 {
+			return 0.0, true
+		}
+		return min(s.elapsed.Seconds()/s.actionLen.Seconds(), 1), true
+	case "playbackPositionId":
+		if cue.Type != "Cue List" {
+			return "", true
+		}
+		return m.playheadLocked(wsID, cue), true
+	}
+	return nil, false
+}
+
+func (m *MockServer) runningCuesLocked(wsID string, includePaused bool) []Cue {
+	cues := []Cue{}
+	for _, s := range m.runningLocked(wsID, includePaused) {
+		if cue := m.findCueByID(wsID, s.id); cue != nil {
+			c := *cue
+			c.Cues = nil
+			cues = append(cues, c)
+		}
+	}
+	return cues
+}
+
+func (m *MockServer) cueUpdateLocked(wsID, cueID string) {
+	m.sendUpdateLocked(wsID, fmt.Sprintf("/update/workspace/%s/cue_id/%s", wsID, cueID))
+}
+
+// sendUpdateLocked delivers an update to every connection that enabled
+// updates for the workspace.
+func (m *MockServer) sendUpdateLocked(wsID string, addr string, args ...any) {
+	msg := osc.Message{Address: addr, Args: args}
+	for conn, ws := range m.updateConns {
+		if ws[wsID] {
+			writePacket(conn, msg)
+		}
+	}
+}
+
+func (m *MockServer) setUpdatesLocked(conn net.Conn, wsID string, enable bool) {
+	if m.updateConns == nil {
+		m.updateConns = make(map[net.Conn]map[string]bool)
+	}
+	if m.updateConns[conn] == nil {
+		m.updateConns[conn] = make(map[string]bool)
+	}
+	m.updateConns[conn][wsID] = enable
+}
+
+func childIDs(cues []Cue) []string {
+	ids := make([]string, len(cues))
+	for i := range cues {
+		ids[i] = cues[i].UniqueID
+	}
+	return ids
+}
@@ -0,0 +1,365 @@
+package qlab
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupSim builds a workspace whose single cue list holds the given cues and
+// returns a client connected to it.
+func setupSim(t *testing.T, cues ...Cue) (*MockServer, *Client) {
+	t.Helper()
+	mock, client := setupTest(t)
+	mock.CueLists["ws-1"] = []Cue{{UniqueID: "list-1", Name: "Main", Type: "Cue List", Cues: cues}}
+	if _, err := client.Connect(t.Context(), "ws-1", ""); err != nil {
+		t.Fatal(err)
+	}
+	return mock, client
+}
+
+// roundTrip waits for the mock to process everything the client has sent.
+func roundTrip(t *testing.T, client *Client) {
+	t.Helper()
+	if _, err := client.Version(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertRunning(t *testing.T, mock *MockServer, want ...string) {
+	t.Helper()
+	got := mock.RunningCueIDs("ws-1")
+	if !slices.Equal(got, want) {
+		t.Errorf("at %v running %v, want %v", mock.Now(), got, want)
+	}
+}
+
+func setProps(mock *MockServer, id string, props map[string]any) {
+	for k, v := range props {
+		mock.SetCueProperty(id, k, v)
+	}
+}
+
+func TestSimGoPreWaitDuration(t *testing.T) {
+	mock, client := setupSim(t,
+		Cue{UniqueID: "a", Number: "1", Type: "Audio"},
+		Cue{UniqueID: "b", Number: "2", Type: "Audio"},
+	)
+	setProps(mock, "a", map[string]any{"preWait": 1.0, "duration": 2.0})
+
+	if got := mock.Playhead("ws-1"); got != "a" {
+		t.Fatalf("initial playhead %q, want a", got)
+	}
+	client.Go(t.Context(), "ws-1")
+	roundTrip(t, client)
+	assertRunning(t, mock, "a")
+	if got := mock.Playhead("ws-1"); got != "b" {
+		t.Errorf("playhead %q after go, want b", got)
+	}
+
+	mock.Advance(1500 * time.Millisecond)
+	elapsed, err := GetCueProperty(t.Context(), client, "ws-1", "a", PropActionElapsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed != 500*time.Millisecond {
+		t.Errorf("actionElapsed %v, want 500ms", elapsed)
+	}
+
+	mock.Advance(1400 * time.Millisecond)
+	assertRunning(t, mock, "a")
+	mock.Advance(100 * time.Millisecond)
+	assertRunning(t, mock)
+
+	running, err := client.RunningCues(t.Context(), "ws-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(running) != 0 {
+		t.Errorf("got %d running cues, want 0", len(running))
+	}
+}
+
+func TestSimAutoContinueAndFollow(t *testing.T) {
+	mock, client := setupSim(t,
+		Cue{UniqueID: "a", Type: "Audio"},
+		Cue{UniqueID: "b", Type: "Audio"},
+		Cue{UniqueID: "c", Type: "Audio"},
+		Cue{UniqueID: "d", Type: "Memo"},
+	)
+	setProps(mock, "a", map[string]any{"duration": 5.0, "postWait": 1.0, "continueMode": int(AutoContinue)})
+	setProps(mock, "b", map[string]any{"duration": 2.0, "continueMode": int(AutoFollow)})
+	setProps(mock, "c", map[string]any{"duration": 1.0})
+
+	client.Go(t.Context(), "ws-1")
+	roundTrip(t, client)
+	assertRunning(t, mock, "a")
+	if got := mock.Playhead("ws-1"); got != "d" {
+		t.Errorf("playhead %q, want d (skipping continued cues)", got)
+	}
+
+	mock.Advance(1 * time.Second)
+	assertRunning(t, mock, "a", "b")
+	mock.Advance(2 * time.Second)
+	assertRunning(t, mock, "a", "c")
+	mock.Advance(1 * time.Second)
+	assertRunning(t, mock, "a")
+	mock.Advance(1 * time.Second)
+	assertRunning(t, mock)
+}
+
+func TestSimPostWaitHoldsZeroDurationCue(t *testing.T) {
+	mock, client := setupSim(t,
+		Cue{UniqueID: "a", Type: "Memo"},
+		Cue{UniqueID: "b", Type: "Memo"},
+	)
+	setProps(mock, "a", map[string]any{"postWait": 2.0, "continueMode": int(AutoContinue)})
+
+	client.Go(t.Context(), "ws-1")
+	roundTrip(t, client)
+	assertRunning(t, mock, "a")
+	mock.Advance(2 * time.Second)
+	assertRunning(t, mock)
+
+	running, _ := client.RunningCues(t.Context(), "ws-1")
+	if len(running) != 0 {
+		t.Errorf("memo b should have completed instantly, got %d running", len(running))
+	}
+}
+
+func TestSimGroupModes(t *testing.T) {
+	mock, client := setupSim(t,
+		Cue{UniqueID: "all", Type: "Group", Cues: []Cue{
+			{UniqueID: "all-1", Type: "Audio"},
+			{UniqueID: "all-2", Type: "Audio"},
+		}},
+		Cue{UniqueID: "seq", Type: "Group", Cues: []Cue{
+			{UniqueID: "seq-1", Type: "Audio"},
+			{UniqueID: "seq-2", Type: "Audio"},
+		}},
+		Cue{UniqueID: "enter", Type: "Group", Cues: []Cue{
+			{UniqueID: "enter-1", Type: "Audio"},
+			{UniqueID: "enter-2", Type: "Audio"},
+		}},
+		Cue{UniqueID: "random", Type: "Group", Cues: []Cue{
+			{UniqueID: "random-1", Type: "Audio"},
+			{UniqueID: "random-2", Type: "Audio"},
+			{UniqueID: "random-3", Type: "Audio"},
+		}},
+	)
+	setProps(mock, "all", map[string]any{"mode": int(GroupStartAll)})
+	setProps(mock, "all-1", map[string]any{"duration": 1.0})
+	setProps(mock, "all-2", map[string]any{"duration": 3.0, "preWait": 1.0})
+	setProps(mock, "seq", map[string]any{"mode": int(GroupStartFirst)})
+	setProps(mock, "seq-1", map[string]any{"duration": 1.0, "continueMode": int(AutoFollow)})
+	setProps(mock, "seq-2", map[string]any{"duration": 1.0})
+	setProps(mock, "enter", map[string]any{"mode": int(GroupStartFirstAndEnter)})
+	setProps(mock, "enter-1", map[string]any{"duration": 1.0})
+	setProps(mock, "enter-2", map[string]any{"duration": 1.0})
+	setProps(mock, "random", map[string]any{"mode": int(GroupStartRandom)})
+	for _, id := range []string{"random-1", "random-2", "random-3"} {
+		setProps(mock, id, map[string]any{"duration": 1.0, "continueMode": int(AutoFollow)})
+	}
+	mock.SetRandomSeed(5)
+
+	client.Go(t.Context(), "ws-1")
+	roundTrip(t, client)
+	assertRunning(t, mock, "all", "all-1", "all-2")
+	mock.Advance(1 * time.Second)
+	assertRunning(t, mock, "all", "all-2")
+	mock.Advance(3 * time.Second)
+	assertRunning(t, mock)
+
+	client.Go(t.Context(), "ws-1")
+	roundTrip(t, client)
+	assertRunning(t, mock, "seq", "seq-1")
+	mock.Advance(1 * time.Second)
+	assertRunning(t, mock, "seq", "seq-2")
+	mock.Advance(1 * time.Second)
+	assertRunning(t, mock)
+
+	// Entering the group takes the playhead to its second child, and the
+	// next GO out again past the group.
+	client.Go(t.Context(), "ws-1")
+	roundTrip(t, client)
+	assertRunning(t, mock, "enter", "enter-1")
+	if got := mock.Playhead("ws-1"); got != "enter-2" {
+		t.Errorf("playhead at %q after entering the group, want enter-2", got)
+	}
+	mock.Advance(1 * time.Second)
+	assertRunning(t, mock)
+	client.Go(t.Context(), "ws-1")
+	roundTrip(t, client)
+	assertRunning(t, mock, "enter-2")
+	if got := mock.Playhead("ws-1"); got != "random" {
+		t.Errorf("playhead at %q after the group's last child, want random", got)
+	}
+	mock.Advance(1 * time.Second)
+	assertRunning(t, mock)
+
+	// One child, picked by the seed; children of a random group don't
+	// continue into each other.
+	want := fmt.Sprintf("random-%d", rand.New(rand.NewPCG(5, 5)).IntN(3)+1)
+	client.Go(t.Context(), "ws-1")
+	roundTrip(t, client)
+	assertRunning(t, mock, "random", want)
+	mock.Advance(1 * time.Second)
+	assertRunning(t, mock)
+}
+
+func TestSimTargetCues(t *testing.T) {
+	mock, client := setupSim(t,
+		Cue{UniqueID: "loop", Type: "Audio"},
+		Cue{UniqueID: "start", Type: "Start"},
+		Cue{UniqueID: "fade", Type: "Fade"},
+		Cue{UniqueID: "stop", Type: "Stop"},
+	)
+	setProps(mock, "loop", map[string]any{"infiniteLoop": true})
+	setProps(mock, "start", map[string]any{"cueTargetID": "loop"})
+	setProps(mock, "fade", map[string]any{"cueTargetID": "loop", "duration": 2.0, "stopTargetWhenDone": true})
+	setProps(mock, "stop", map[string]any{"cueTargetID": "loop"})
+
+	client.CueStart(t.Context(), "ws-1", "start")
+	roundTrip(t, client)
+	assertRunning(t, mock, "loop")
+	mock.Advance(time.Hour)
+	assertRunning(t, mock, "loop")
+
+	client.CueStart(t.Context(), "ws-1", "fade")
+	roundTrip(t, client)
+	assertRunning(t, mock, "loop", "fade")
+	mock.Advance(2 * time.Second)
+	assertRunning(t, mock)
+
+	client.CueStart(t.Context(), "ws-1", "start")
+	client.CueStart(t.Context(), "ws-1", "stop")
+	roundTrip(t, client)
+	assertRunning(t, mock)
+}
+
+func TestSimPanic(t *testing.T) {
+	mock, client := setupSim(t, Cue{UniqueID: "a", Type: "Audio"}, Cue{UniqueID: "b", Type: "Audio"})
+	mock.PanicDuration = 2 * time.Second
+	setProps(mock, "a", map[string]any{"infiniteLoop": true})
+	setProps(mock, "b", map[string]any{"infiniteLoop": true})
+
+	client.CueStart(t.Context(), "ws-1", "a")
+	client.CueStart(t.Context(), "ws-1", "b")
+	client.Panic(t.Context(), "ws-1")
+	roundTrip(t, client)
+	assertRunning(t, mock, "a", "b")
+	panicking, err := GetCueProperty(t.Context(), client, "ws-1", "a", PropIsPanicking)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !panicking {
+		t.Error("expected a to be panicking")
+	}
+	mock.Advance(2 * time.Second)
+	assertRunning(t, mock)
+
+	client.CueStart(t.Context(), "ws-1", "a")
+	client.Panic(t.Context(), "ws-1")
+	client.Panic(t.Context(), "ws-1")
+	roundTrip(t, client)
+	assertRunning(t, mock)
+}
+
+func TestSimCuePanicInTime(t *testing.T) {
+	mock, client := setupSim(t, Cue{UniqueID: "a", Type: "Audio"}, Cue{UniqueID: "b", Type: "Audio"})
+	setProps(mock, "a", map[string]any{"infiniteLoop": true})
+	setProps(mock, "b", map[string]any{"infiniteLoop": true})
+
+	client.CueStart(t.Context(), "ws-1", "a")
+	client.CueStart(t.Context(), "ws-1", "b")
+	client.CuePanicInTime(t.Context(), "ws-1", "a", 3*time.Second)
+	roundTrip(t, client)
+	mock.Advance(2 * time.Second)
+	assertRunning(t, mock, "a", "b")
+	mock.Advance(time.Second)
+	assertRunning(t, mock, "b")
+
+	client.CuePanicInTime(t.Context(), "ws-1", "b", 0)
+	roundTrip(t, client)
+	assertRunning(t, mock)
+}
+
+func TestSimPauseResumeLoadAt(t *testing.T) {
+	mock, client := setupSim(t, Cue{UniqueID: "a", Type: "Audio"})
+	setProps(mock, "a", map[string]any{"duration": 10.0})
+
+	client.send(t.Context(), "/workspace/ws-1/cue_id/a/loadAt", float32(7))
+	client.CueStart(t.Context(), "ws-1", "a")
+	client.Pause(t.Context(), "ws-1")
+	roundTrip(t, client)
+	mock.Advance(time.Minute)
+	assertRunning(t, mock, "a")
+
+	running, _ := client.RunningCues(t.Context(), "ws-1")
+	if len(running) != 0 {
+		t.Errorf("paused cue reported as running")
+	}
+
+	client.Resume(t.Context(), "ws-1")
+	roundTrip(t, client)
+	mock.Advance(2999 * time.Millisecond)
+	assertRunning(t, mock, "a")
+	mock.Advance(time.Millisecond)
+	assertRunning(t, mock)
+}
+
+func TestSimUpdates(t *testing.T) {
+	mock, client := setupSim(t, Cue{UniqueID: "a", Type: "Audio"}, Cue{UniqueID: "b", Type: "Audio"})
+	setProps(mock, "a", map[string]any{"duration": 1.0})
+	client.EnableUpdates(t.Context(), "ws-1", true)
+
+	client.Go(t.Context(), "ws-1")
+	roundTrip(t, client)
+	mock.Advance(time.Second)
+
+	want := []string{
+		"/update/workspace/ws-1/cueList/list-1/playbackPosition",
+		"/update/workspace/ws-1/cue_id/a",
+	}
+	seen := map[string]bool{}
+	deadline := time.After(2 * time.Second)
+	for len(seen) < len(want) {
+		select {
+		case u := <-client.Updates():
+			if slices.Contains(want, u.Address) {
+				seen[u.Address] = true
+			} else if !strings.HasPrefix(u.Address, "/update/workspace/ws-1/") {
+				t.Errorf("unexpected update %q", u.Address)
+			}
+		case <-deadline:
+			t.Fatalf("saw %v, want %v", seen, want)
+		}
+	}
+}
+
+func TestSimReset(t *testing.T) {
+	mock, client := setupSim(t, Cue{UniqueID: "a", Type: "Audio"}, Cue{UniqueID: "b", Type: "Audio"})
+	setProps(mock, "a", map[string]any{"infiniteLoop": true})
+
+	client.Go(t.Context(), "ws-1")
+	client.Reset(t.Context(), "ws-1")
+	roundTrip(t, client)
+	assertRunning(t, mock)
+	if got := mock.Playhead("ws-1"); got != "a" {
+		t.Errorf("playhead %q after reset, want a", got)
+	}
+
+	if err := client.CueSet(t.Context(), "ws-1", "list-1", "playbackPositionId", "b"); err != nil {
+		t.Fatal(err)
+	}
+	pos, err := GetCueProperty(t.Context(), client, "ws-1", "list-1", PropPlaybackPositionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos != "b" {
+		t.Errorf("playbackPositionId %q, want b", pos)
+	}
+}
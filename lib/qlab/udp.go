@@ -0,0 +1,96 @@
+package qlab
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	DefaultReplyPort = 53001
+
+	// maxDatagram is the largest UDP payload over IPv4. Qlab cannot fit a
+	// larger reply, such as cueLists for a big workspace, into one datagram.
+	maxDatagram = 65507
+)
+
+// DialUDP returns a client that sends unframed OSC datagrams to Qlab. It
+// listens on replyPort (0 for any free port) and sends from that socket, so
+// replies arrive whether Qlab answers the source port or the reply port.
+//
+// UDP has no connection to lose: the client stays StateConnected until it is
+// closed, and a Monitor is the only way to notice that Qlab went away.
+// Requests or replies that do not fit in a datagram fail with ErrTooLarge.
+func DialUDP(host string, port int, replyPort int) (*Client, error) {
+	addr := net.JoinHostPort(host, fmt.Sprint(port))
+	return start(addr, true, func() (net.Conn, error) {
+		remote, err := net.ResolveUDPAddr("udp", addr)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: replyPort})
+		if err != nil {
+			return nil, err
+		}
+		return &udpConn{UDPConn: conn, remote: remote}, nil
+	})
+}
+
+// udpConn is an unconnected UDP socket that writes to and reads from a
+// single remote host.
+type udpConn struct {
+	*net.UDPConn
+	remote *net.UDPAddr
+}
+
+func (u *udpConn) Write(b []byte) (int, error) {
+	return u.WriteToUDP(b, u.remote)
+}
+
+func (u *udpConn) Read(b []byte) (int, error) {
+	for {
+		n, from, err := u.ReadFromUDP(b)
+		if err != nil {
+			return 0, err
+		}
+		if from.IP.Equal(u.remote.IP) {
+			return n, nil
+		}
+	}
+}
+
+func (c *Client) readDatagrams(conn net.Conn) {
+	buf := make([]byte, 65536)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		c.handleFrame(bytes.Clone(buf[:n]))
+	}
+}
+
+// failTruncated fails the request a datagram was replying to when the
+// datagram was cut short and no longer parses as OSC.
+func (c *Client) failTruncated(frame []byte) {
+	i := bytes.IndexByte(frame, 0)
+	if i < 0 {
+		return
+	}
+	addr, ok := strings.CutPrefix(string(frame[:i]), "/reply")
+	if !ok {
+		return
+	}
+	c.failReply(addr, fmt.Errorf("qlab: %s: %w", addr, ErrTooLarge))
+}
+
+func (c *Client) failReply(addr string, err error) {
+	c.mu.Lock()
+	w := c.popWaiterLocked(addr)
+	c.mu.Unlock()
+	if w != nil {
+		w.err = err
+		close(w.ch)
+	}
+}
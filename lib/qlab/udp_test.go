@@ -0,0 +1,122 @@
+package qlab
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setupUDPTest(t *testing.T) (*MockServer, *Client) {
+	t.Helper()
+	mock, err := NewMockServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mock.Close() })
+
+	client, err := DialUDP("127.0.0.1", mock.UDPPort(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return mock, client
+}
+
+// configure edits the mock under its lock. Unlike TCP, UDP socket I/O gives
+// the race detector no ordering between the test and the serving goroutine.
+func configure(mock *MockServer, f func()) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	f()
+}
+
+func TestUDPRequests(t *testing.T) {
+	mock, client := setupUDPTest(t)
+	configure(mock, func() {
+		mock.Version = "5.2.3"
+		mock.Workspaces = []Workspace{{DisplayName: "Show", UniqueID: "ws-1"}}
+		mock.CueLists["ws-1"] = []Cue{{UniqueID: "list-1", Type: "Cue List", Cues: []Cue{{UniqueID: "cue-1", Name: "Blackout"}}}}
+	})
+
+	v, err := client.Version(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "5.2.3" {
+		t.Errorf("got version %q, want 5.2.3", v)
+	}
+	if _, err := client.Connect(t.Context(), "ws-1", ""); err != nil {
+		t.Fatal(err)
+	}
+	lists, err := client.CueLists(t.Context(), "ws-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 1 || len(lists[0].Cues) != 1 || lists[0].Cues[0].Name != "Blackout" {
+		t.Errorf("got cue lists %+v", lists)
+	}
+	if err := client.Go(t.Context(), "ws-1"); err != nil {
+		t.Fatal(err)
+	}
+	if client.State() != StateConnected {
+		t.Errorf("got state %v, want connected", client.State())
+	}
+}
+
+func TestUDPUpdates(t *testing.T) {
+	mock, client := setupUDPTest(t)
+	configure(mock, func() {
+		mock.CueLists["ws-1"] = []Cue{{UniqueID: "list-1", Type: "Cue List", Cues: []Cue{{UniqueID: "cue-1"}}}}
+	})
+	if _, err := client.Connect(t.Context(), "ws-1", ""); err != nil {
+		t.Fatal(err)
+	}
+	client.EnableUpdates(t.Context(), "ws-1", true)
+	client.Go(t.Context(), "ws-1")
+
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case u := <-client.Updates():
+			if u.Kind == UpdateCue && u.CueID == "cue-1" {
+				return
+			}
+		case <-deadline:
+			t.Fatal("timeout waiting for cue update over UDP")
+		}
+	}
+}
+
+func TestUDPReplyTooLarge(t *testing.T) {
+	mock, client := setupUDPTest(t)
+	var cues []Cue
+	for i := range 50 {
+		cues = append(cues, Cue{UniqueID: fmt.Sprintf("cue-%d", i), Name: strings.Repeat("x", 40)})
+	}
+	configure(mock, func() {
+		mock.MaxDatagram = 1024
+		mock.CueLists["ws-1"] = []Cue{{UniqueID: "list-1", Type: "Cue List", Cues: cues}}
+	})
+
+	_, err := client.CueLists(t.Context(), "ws-1")
+	if !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("got %v, want ErrTooLarge", err)
+	}
+
+	// Later requests to the same address are unaffected.
+	configure(mock, func() { mock.MaxDatagram = maxDatagram })
+	if _, err := client.CueLists(t.Context(), "ws-1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUDPRequestTooLarge(t *testing.T) {
+	_, client := setupUDPTest(t)
+	err := client.CueSet(t.Context(), "ws-1", "cue-1", "notes", strings.Repeat("x", maxDatagram))
+	if !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("got %v, want ErrTooLarge", err)
+	}
+}
@@ -0,0 +1,177 @@
+package qlab
+
+import (
+	"fmt"
+	"strings"
+)
+
+type UpdateKind int
+
+const (
+	UpdateUnknown UpdateKind = iota
+	UpdateWorkspace
+	UpdateCue
+	UpdatePlaybackPosition
+	UpdateCueList
+	UpdateDisconnect
+	UpdateLightDashboard
+
+	// UpdateOverflow is delivered instead of the updates a subscriber missed
+	// because its buffer was full. State derived from earlier updates may be
+	// stale and must be re-read from Qlab.
+	UpdateOverflow
+)
+
+func (k UpdateKind) String() string {
+	switch k {
+	case UpdateUnknown:
+		return "unknown"
+	case UpdateWorkspace:
+		return "workspace"
+	case UpdateCue:
+		return "cue"
+	case UpdatePlaybackPosition:
+		return "playbackPosition"
+	case UpdateCueList:
+		return "cueList"
+	case UpdateDisconnect:
+		return "disconnect"
+	case UpdateLightDashboard:
+		return "lightDashboard"
+	case UpdateOverflow:
+		return "overflow"
+	default:
+		return fmt.Sprintf("UpdateKind(%d)", int(k))
+	}
+}
+
+func (k UpdateKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// Update is a parsed /update message. CueID is set for cue updates and holds
+// the new playhead for playback position updates; it is empty when the
+// playhead is cleared.
+type Update struct {
+	Kind        UpdateKind
+	Address     string
+	WorkspaceID string
+	CueListID   string
+	CueID       string
+}
+
+// ParseUpdate classifies an /update address. Addresses it does not recognise
+// are returned as UpdateUnknown with the workspace filled in when present.
+func ParseUpdate(addr string, args []any) Update {
+	u := Update{Kind: UpdateUnknown, Address: addr}
+	parts := strings.Split(strings.TrimPrefix(addr, "/update/"), "/")
+	if len(parts) < 2 || parts[0] != "workspace" {
+		return u
+	}
+	u.WorkspaceID = parts[1]
+	rest := parts[2:]
+
+	switch {
+	case len(rest) == 0:
+		u.Kind = UpdateWorkspace
+	case len(rest) == 2 && rest[0] == "cue_id":
+		u.Kind = UpdateCue
+		u.CueID = rest[1]
+	case len(rest) == 3 && rest[0] == "cueList" && rest[2] == "playbackPosition":
+		u.Kind = UpdatePlaybackPosition
+		u.CueListID = rest[1]
+		if len(args) > 0 {
+			if id, ok := args[0].(string); ok && id != "none" {
+				u.CueID = id
+			}
+		}
+	case len(rest) >= 2 && rest[0] == "cueList":
+		u.Kind = UpdateCueList
+		u.CueListID = rest[1]
+	case len(rest) == 1 && rest[0] == "disconnect":
+		u.Kind = UpdateDisconnect
+	case len(rest) == 1 && rest[0] == "dashboard":
+		u.Kind = UpdateLightDashboard
+	}
+	return u
+}
+
+// Subscription receives every update the client reads. Each subscription has
+// its own buffer; a subscriber that falls behind gets a single UpdateOverflow
+// and nothing more until it has drained its channel.
+type Subscription struct {
+	C <-chan Update
+
+	client     *Client
+	ch         chan Update
+	buffer     int
+	overflowed bool
+	closed     bool
+}
+
+// Subscribe registers a subscription buffering up to buffer updates. C is
+// closed when the subscription or the client is closed.
+func (c *Client) Subscribe(buffer int) *Subscription {
+	if buffer < 1 {
+		buffer = 1
+	}
+	// One extra slot is reserved so the overflow marker always fits.
+	ch := make(chan Update, buffer+1)
+	s := &Subscription{C: ch, client: c, ch: ch, buffer: buffer}
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	if c.subs == nil {
+		close(ch)
+		s.closed = true
+		return s
+	}
+	c.subs[s] = struct{}{}
+	return s
+}
+
+func (s *Subscription) Close() {
+	c := s.client
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	if s.closed {
+		return
+	}
+	delete(c.subs, s)
+	s.closed = true
+	close(s.ch)
+}
+
+// deliver runs under subMu, so between the length checks and the send the
+// channel can only drain, never fill.
+func (s *Subscription) deliver(u Update) {
+	if s.overflowed {
+		if len(s.ch) > 0 {
+			return
+		}
+		s.overflowed = false
+	}
+	if len(s.ch) >= s.buffer {
+		s.overflowed = true
+		s.ch <- Update{Kind: UpdateOverflow}
+		return
+	}
+	s.ch <- u
+}
+
+func (c *Client) publish(u Update) {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	for s := range c.subs {
+		s.deliver(u)
+	}
+}
+
+func (c *Client) closeSubscriptions() {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	for s := range c.subs {
+		s.closed = true
+		close(s.ch)
+	}
+	c.subs = nil
+}
@@ -0,0 +1,132 @@
+package qlab
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseUpdate(t *testing.T) {
+	tests := []struct {
+		addr string
+		args []any
+		want Update
+	}{
+		{"/update/workspace/ws-1", nil,
+			Update{Kind: UpdateWorkspace, WorkspaceID: "ws-1"}},
+		{"/update/workspace/ws-1/cue_id/cue-1", nil,
+			Update{Kind: UpdateCue, WorkspaceID: "ws-1", CueID: "cue-1"}},
+		{"/update/workspace/ws-1/cueList/list-1/playbackPosition", []any{"cue-2"},
+			Update{Kind: UpdatePlaybackPosition, WorkspaceID: "ws-1", CueListID: "list-1", CueID: "cue-2"}},
+		{"/update/workspace/ws-1/cueList/list-1/playbackPosition", []any{"none"},
+			Update{Kind: UpdatePlaybackPosition, WorkspaceID: "ws-1", CueListID: "list-1"}},
+		{"/update/workspace/ws-1/cueList/list-1/playbackPosition", nil,
+			Update{Kind: UpdatePlaybackPosition, WorkspaceID: "ws-1", CueListID: "list-1"}},
+		{"/update/workspace/ws-1/cueList/list-1/selectedCues", nil,
+			Update{Kind: UpdateCueList, WorkspaceID: "ws-1", CueListID: "list-1"}},
+		{"/update/workspace/ws-1/disconnect", nil,
+			Update{Kind: UpdateDisconnect, WorkspaceID: "ws-1"}},
+		{"/update/workspace/ws-1/dashboard", nil,
+			Update{Kind: UpdateLightDashboard, WorkspaceID: "ws-1"}},
+		{"/update/workspace/ws-1/settings/audio", nil,
+			Update{Kind: UpdateUnknown, WorkspaceID: "ws-1"}},
+		{"/update/application", nil,
+			Update{Kind: UpdateUnknown}},
+	}
+	for _, tt := range tests {
+		tt.want.Address = tt.addr
+		if got := ParseUpdate(tt.addr, tt.args); got != tt.want {
+			t.Errorf("ParseUpdate(%q, %v) = %+v, want %+v", tt.addr, tt.args, got, tt.want)
+		}
+	}
+}
+
+func waitUpdate(t *testing.T, ch <-chan Update) Update {
+	t.Helper()
+	select {
+	case u, ok := <-ch:
+		if !ok {
+			t.Fatal("subscription closed")
+		}
+		return u
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for update")
+		return Update{}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	mock, client := setupTest(t)
+	a := client.Subscribe(4)
+	b := client.Subscribe(4)
+	defer a.Close()
+
+	mock.SendUpdate("/update/workspace/ws-1/cueList/list-1/playbackPosition", "cue-1")
+	for _, s := range []*Subscription{a, b} {
+		u := waitUpdate(t, s.C)
+		if u.Kind != UpdatePlaybackPosition || u.CueID != "cue-1" {
+			t.Errorf("got %+v", u)
+		}
+	}
+
+	b.Close()
+	b.Close()
+	if _, ok := <-b.C; ok {
+		t.Error("closed subscription still delivering")
+	}
+
+	mock.SendUpdate("/update/workspace/ws-1")
+	if u := waitUpdate(t, a.C); u.Kind != UpdateWorkspace {
+		t.Errorf("got %+v, want workspace update", u)
+	}
+}
+
+func TestSubscribeOverflow(t *testing.T) {
+	mock, client := setupTest(t)
+	s := client.Subscribe(2)
+
+	for range 5 {
+		mock.SendUpdate("/update/workspace/ws-1/cue_id/cue-1")
+	}
+	// A round trip guarantees the client has read every update.
+	if _, err := client.Version(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	var kinds []UpdateKind
+	for range 3 {
+		kinds = append(kinds, waitUpdate(t, s.C).Kind)
+	}
+	want := []UpdateKind{UpdateCue, UpdateCue, UpdateOverflow}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("got %v, want %v", kinds, want)
+		}
+	}
+	select {
+	case u := <-s.C:
+		t.Fatalf("update %+v delivered after overflow", u)
+	default:
+	}
+
+	mock.SendUpdate("/update/workspace/ws-1/disconnect")
+	if u := waitUpdate(t, s.C); u.Kind != UpdateDisconnect {
+		t.Errorf("got %+v after drain, want disconnect", u)
+	}
+}
+
+func TestSubscriptionClosedWithClient(t *testing.T) {
+	_, client := setupTest(t)
+	s := client.Subscribe(1)
+	client.Close()
+	select {
+	case _, ok := <-s.C:
+		if ok {
+			t.Error("expected closed channel")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("subscription not closed")
+	}
+	if _, ok := <-client.Subscribe(1).C; ok {
+		t.Error("subscribe after close should return a closed subscription")
+	}
+}
@@ -0,0 +1,302 @@
+package qlab
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// WorkspaceHandle is an open Qlab workspace, obtained from Client.Connect.
+// Calls through it are scoped to the workspace and fail with
+// ErrWorkspaceClosed once it is closed: by Disconnect, by Qlab reporting the
+// workspace closed, or by the client closing.
+type WorkspaceHandle struct {
+	ID string
+
+	client *Client
+
+	// Guarded by client.mu.
+	passcode    string
+	permissions Permissions
+	updates     bool
+	alwaysReply bool
+	connected   bool
+	closed      chan struct{}
+}
+
+func (c *Client) closeWorkspaceLocked(w *WorkspaceHandle) {
+	if c.spaces[w.ID] == w {
+		delete(c.spaces, w.ID)
+	}
+	w.connected = false
+	select {
+	case <-w.closed:
+	default:
+		close(w.closed)
+	}
+}
+
+func (w *WorkspaceHandle) Client() *Client {
+	return w.client
+}
+
+// Connected reports whether the workspace is open and its session is live.
+// It is false while the client reconnects.
+func (w *WorkspaceHandle) Connected() bool {
+	w.client.mu.Lock()
+	defer w.client.mu.Unlock()
+	return w.connected
+}
+
+// Permissions returns the access Qlab granted on the last connect. Calls
+// needing more fail with ErrPermissionDenied without being sent.
+func (w *WorkspaceHandle) Permissions() Permissions {
+	w.client.mu.Lock()
+	defer w.client.mu.Unlock()
+	return w.permissions
+}
+
+// Closed is closed when the workspace is.
+func (w *WorkspaceHandle) Closed() <-chan struct{} {
+	return w.closed
+}
+
+func (w *WorkspaceHandle) check() error {
+	select {
+	case <-w.closed:
+		return fmt.Errorf("qlab: workspace %s: %w", w.ID, ErrWorkspaceClosed)
+	default:
+		return nil
+	}
+}
+
+func (w *WorkspaceHandle) Disconnect(ctx context.Context) error {
+	if err := w.check(); err != nil {
+		return err
+	}
+	return w.client.Disconnect(ctx, w.ID)
+}
+
+func (w *WorkspaceHandle) AlwaysReply(ctx context.Context, enable bool) error {
+	if err := w.check(); err != nil {
+		return err
+	}
+	return w.client.AlwaysReply(ctx, w.ID, enable)
+}
+
+func (w *WorkspaceHandle) EnableUpdates(ctx context.Context, enable bool) error {
+	if err := w.check(); err != nil {
+		return err
+	}
+	return w.client.EnableUpdates(ctx, w.ID, enable)
+}
+
+func (w *WorkspaceHandle) Thump(ctx context.Context) error {
+	if err := w.check(); err != nil {
+		return err
+	}
+	return w.client.Thump(ctx, w.ID)
+}
+
+func (w *WorkspaceHandle) Go(ctx context.Context) error {
+	if err := w.check(); err != nil {
+		return err
+	}
+	return w.client.Go(ctx, w.ID)
+}
+
+func (w *WorkspaceHandle) GoTo(ctx context.Context, cueNumber string) error {
+	if err := w.check(); err != nil {
+		return err
+	}
+	return w.client.GoTo(ctx, w.ID, cueNumber)
+}
+
+func (w *WorkspaceHandle) Stop(ctx context.Context) error {
+	if err := w.check(); err != nil {
+		return err
+	}
+	return w.client.Stop(ctx, w.ID)
+}
+
+func (w *WorkspaceHandle) Pause(ctx context.Context) error {
+	if err := w.check(); err != nil {
+		return err
+	}
+	return w.client.Pause(ctx, w.ID)
+}
+
+func (w *WorkspaceHandle) Resume(ctx context.Context) error {
+	if err := w.check(); err != nil {
+		return err
+	}
+	return w.client.Resume(ctx, w.ID)
+}
+
+func (w *WorkspaceHandle) Panic(ctx context.Context) error {
+	if err := w.check(); err != nil {
+		return err
+	}
+	return w.client.Panic(ctx, w.ID)
+}
+
+func (w *WorkspaceHandle) Reset(ctx context.Context) error {
+	if err := w.check(); err != nil {
+		return err
+	}
+	return w.client.Reset(ctx, w.ID)
+}
+
+func (w *WorkspaceHandle) CueLists(ctx context.Context) ([]Cue, error) {
+	if err := w.check(); err != nil {
+		return nil, err
+	}
+	return w.client.CueLists(ctx, w.ID)
+}
+
+func (w *WorkspaceHandle) SelectedCues(ctx context.Context) ([]Cue, error) {
+	if err := w.check(); err != nil {
+		return nil, err
+	}
+	return w.client.SelectedCues(ctx, w.ID)
+}
+
+func (w *WorkspaceHandle) RunningCues(ctx context.Context) ([]Cue, error) {
+	if err := w.check(); err != nil {
+		return nil, err
+	}
+	return w.client.RunningCues(ctx, w.ID)
+}
+
+func (w *WorkspaceHandle) CueGet(ctx context.Context, cueID string, property string) (*Reply, error) {
+	if err := w.check(); err != nil {
+		return nil, err
+	}
+	return w.client.CueGet(ctx, w.ID, cueID, property)
+}
+
+func (w *WorkspaceHandle) CueGetByNumber(ctx context.Context, cueNumber string, property string) (*Reply, error) {
+	if err := w.check(); err != nil {
+		return nil, err
+	}
+	return w.client.CueGetByNumber(ctx, w.ID, cueNumber, property)
+}
+
+func (w *WorkspaceHandle) CueSet(ctx context.Context, cueID string, property string, value any) error {
+	if err := w.check(); err != nil {
+		return err
+	}
+	return w.client.CueSet(ctx, w.ID, cueID, property, value)
+}
+
+func (w *WorkspaceHandle) CueSetByNumber(ctx context.Context, cueNumber string, property string, value any) error {
+	if err := w.check(); err != nil {
+		return err
+	}
+	return w.client.CueSetByNumber(ctx, w.ID, cueNumber, property, value)
+}
+
+func (w *WorkspaceHandle) CueStart(ctx context.Context, cueID string) error {
+	if err := w.check(); err != nil {
+		return err
+	}
+	return w.client.CueStart(ctx, w.ID, cueID)
+}
+
+func (w *WorkspaceHandle) CueStop(ctx context.Context, cueID string) error {
+	if err := w.check(); err != nil {
+		return err
+	}
+	return w.client.CueStop(ctx, w.ID, cueID)
+}
+
+func (w *WorkspaceHandle) CuePause(ctx context.Context, cueID string) error {
+	if err := w.check(); err != nil {
+		return err
+	}
+	return w.client.CuePause(ctx, w.ID, cueID)
+}
+
+func (w *WorkspaceHandle) CueResume(ctx context.Context, cueID string) error {
+	if err := w.check(); err != nil {
+		return err
+	}
+	return w.client.CueResume(ctx, w.ID, cueID)
+}
+
+func (w *WorkspaceHandle) CueLoad(ctx context.Context, cueID string) error {
+	if err := w.check(); err != nil {
+		return err
+	}
+	return w.client.CueLoad(ctx, w.ID, cueID)
+}
+
+func (w *WorkspaceHandle) CuePanicInTime(ctx context.Context, cueID string, fade time.Duration) error {
+	if err := w.check(); err != nil {
+		return err
+	}
+	return w.client.CuePanicInTime(ctx, w.ID, cueID, fade)
+}
+
+func (w *WorkspaceHandle) CueReset(ctx context.Context, cueID string) error {
+	if err := w.check(); err != nil {
+		return err
+	}
+	return w.client.CueReset(ctx, w.ID, cueID)
+}
+
+func (w *WorkspaceHandle) NewCue(ctx context.Context, cueType string, afterCueID string) (string, error) {
+	if err := w.check(); err != nil {
+		return "", err
+	}
+	return w.client.NewCue(ctx, w.ID, cueType, afterCueID)
+}
+
+func (w *WorkspaceHandle) SelectCue(ctx context.Context, cueID string) error {
+	if err := w.check(); err != nil {
+		return err
+	}
+	return w.client.SelectCue(ctx, w.ID, cueID)
+}
+
+func (w *WorkspaceHandle) DeleteCue(ctx context.Context, cueID string) error {
+	if err := w.check(); err != nil {
+		return err
+	}
+	return w.client.DeleteCue(ctx, w.ID, cueID)
+}
+
+func (w *WorkspaceHandle) MoveCue(ctx context.Context, cueID string, parentID string, index int) error {
+	if err := w.check(); err != nil {
+		return err
+	}
+	return w.client.MoveCue(ctx, w.ID, cueID, parentID, index)
+}
+
+func (w *WorkspaceHandle) CueChildren(ctx context.Context, cueID string) ([]Cue, error) {
+	if err := w.check(); err != nil {
+		return nil, err
+	}
+	return w.client.CueChildren(ctx, w.ID, cueID)
+}
+
+func (w *WorkspaceHandle) CueParent(ctx context.Context, cueID string) (string, error) {
+	if err := w.check(); err != nil {
+		return "", err
+	}
+	return w.client.CueParent(ctx, w.ID, cueID)
+}
+
+func (w *WorkspaceHandle) GroupCues(ctx context.Context, cueIDs []string) (string, error) {
+	if err := w.check(); err != nil {
+		return "", err
+	}
+	return w.client.GroupCues(ctx, w.ID, cueIDs)
+}
+
+func (w *WorkspaceHandle) UngroupCue(ctx context.Context, groupID string) error {
+	if err := w.check(); err != nil {
+		return err
+	}
+	return w.client.UngroupCue(ctx, w.ID, groupID)
+}
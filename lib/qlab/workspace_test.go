@@ -0,0 +1,130 @@
+package qlab
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWorkspaceScopesCalls(t *testing.T) {
+	mock, client := setupTest(t)
+
+	ws, err := client.Connect(t.Context(), "ws-1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ws.Connected() {
+		t.Error("expected workspace to be connected")
+	}
+	if client.Workspace("ws-1") != ws {
+		t.Error("Workspace did not return the connected handle")
+	}
+	again, err := client.Connect(t.Context(), "ws-1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != ws {
+		t.Error("reconnecting returned a new handle")
+	}
+
+	mock.ResetRequests()
+	if err := ws.Go(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	roundTrip(t, client)
+	reqs := mock.Requests()
+	if len(reqs) != 2 || reqs[0].Address != "/workspace/ws-1/go" {
+		t.Errorf("got %v, want /workspace/ws-1/go", reqs)
+	}
+}
+
+func TestWorkspaceDisconnect(t *testing.T) {
+	_, client := setupTest(t)
+
+	ws, err := client.Connect(t.Context(), "ws-1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.Disconnect(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-ws.Closed():
+	default:
+		t.Fatal("expected workspace to be closed")
+	}
+	if ws.Connected() {
+		t.Error("expected closed workspace not to be connected")
+	}
+	if client.Workspace("ws-1") != nil {
+		t.Error("closed workspace still registered")
+	}
+	if err := ws.Go(t.Context()); !errors.Is(err, ErrWorkspaceClosed) {
+		t.Errorf("got %v, want ErrWorkspaceClosed", err)
+	}
+}
+
+func TestWorkspaceClosedByQlab(t *testing.T) {
+	mock, client := setupTest(t)
+
+	ws, err := client.Connect(t.Context(), "ws-1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.EnableUpdates(t.Context(), true); err != nil {
+		t.Fatal(err)
+	}
+
+	mock.SendUpdate("/update/workspace/ws-1/disconnect")
+	select {
+	case <-ws.Closed():
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for workspace to close")
+	}
+	if _, err := ws.CueLists(t.Context()); !errors.Is(err, ErrWorkspaceClosed) {
+		t.Errorf("got %v, want ErrWorkspaceClosed", err)
+	}
+}
+
+func TestWorkspaceClosedWithClient(t *testing.T) {
+	_, client := setupTest(t)
+
+	ws, err := client.Connect(t.Context(), "ws-1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+
+	select {
+	case <-ws.Closed():
+	default:
+		t.Fatal("expected workspace to close with the client")
+	}
+}
+
+func TestWorkspaceReconnect(t *testing.T) {
+	mock, client := setupTest(t)
+
+	ws, err := client.Connect(t.Context(), "ws-1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.Close()
+	waitState(t, client, StateReconnecting)
+	if ws.Connected() {
+		t.Error("expected workspace not to be connected while reconnecting")
+	}
+
+	if err := mock.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	waitState(t, client, StateConnected)
+	if !ws.Connected() {
+		t.Error("expected workspace to be connected after reconnect")
+	}
+	if err := ws.Go(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+}